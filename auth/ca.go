@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TLS names are not host names: daemons move between lab computers, so
+// certificates name the login they serve instead, and clients set
+// tls.Config.ServerName accordingly.
+const CoordinatorServerName = "coordinator.42fs"
+
+// PeerServerName is the name in the certificate of login's daemon.
+func PeerServerName(login string) string {
+	return login + ".users.42fs"
+}
+
+const (
+	caValidity   = 10 * 365 * 24 * time.Hour
+	certValidity = 24 * time.Hour
+)
+
+// CA is the coordinator's certificate authority. It signs a certificate for
+// every daemon at registration time.
+type CA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// LoadOrCreateCA reads the CA certificate and key, or creates a self-signed
+// pair at those paths if neither exists yet.
+func LoadOrCreateCA(certPath, keyPath string) (*CA, error) {
+	certPEM, err := ioutil.ReadFile(certPath)
+	if os.IsNotExist(err) {
+		if _, err := os.Stat(keyPath); err == nil {
+			return nil, errors.New("42fs: CA key exists but CA certificate does not: " + certPath)
+		}
+		return createCA(certPath, keyPath)
+	} else if err != nil {
+		return nil, err
+	}
+	keyPEM, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParseKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := parseCertPEM(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &CA{cert: cert, key: key}, nil
+}
+
+func createCA(certPath, keyPath string) (*CA, error) {
+	key, err := LoadOrCreateKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "42fs coordinator CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(filepath.Dir(certPath), 0755)
+	if err != nil {
+		return nil, err
+	}
+	err = ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &CA{cert: cert, key: key}, nil
+}
+
+func newSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+func parseCertPEM(b []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("42fs: no CERTIFICATE block in certificate file")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// LoadCertPool reads the CA certificate daemons pin for the coordinator.
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, errors.New("42fs: no certificates in " + path)
+	}
+	return pool, nil
+}
+
+// Pool returns a pool containing only this CA.
+func (ca *CA) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+// CertDER returns the CA certificate.
+func (ca *CA) CertDER() []byte {
+	return ca.cert.Raw
+}
+
+func (ca *CA) issue(pub *ecdsa.PublicKey, name string) ([]byte, error) {
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(certValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	return x509.CreateCertificate(rand.Reader, tmpl, ca.cert, pub, ca.key)
+}
+
+// SignCSR issues a certificate for login's daemon. Whatever names the request
+// asks for are ignored; the certificate always names PeerServerName(login).
+func (ca *CA) SignCSR(csrDER []byte, login string) ([]byte, error) {
+	csr, err := x509.ParseCertificateRequest(csrDER)
+	if err != nil {
+		return nil, err
+	}
+	err = csr.CheckSignature()
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := csr.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("42fs: certificate request key is not ECDSA")
+	}
+	return ca.issue(pub, PeerServerName(login))
+}
+
+// ServerCertificate issues the coordinator's own serving certificate.
+func (ca *CA) ServerCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	der, err := ca.issue(&key.PublicKey, CoordinatorServerName)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{der, ca.cert.Raw},
+		PrivateKey:  key,
+	}, nil
+}
+
+// NewCSR creates a certificate request for a daemon's host key.
+func NewCSR(key *ecdsa.PrivateKey) ([]byte, error) {
+	tmpl := &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "42fs daemon"},
+	}
+	return x509.CreateCertificateRequest(rand.Reader, tmpl, key)
+}
@@ -0,0 +1,116 @@
+// Package auth identifies 42fs daemons to each other.
+//
+// Every daemon holds a login key, kept in the owner's home directory so only
+// the owner can read it. The coordinator pins the public half the first time
+// a login registers, and from then on answers each signed registration with
+// a short-lived token, signed by the coordinator's own key, that names the
+// login and the address it registered from. Daemons attach their token to
+// every call they make to a peer, and peers verify it before serving
+// anything.
+//
+// When TLS is configured the coordinator is also a certificate authority:
+// each registration may carry a certificate request, and the certificate it
+// gets back names the login, so peers can check both ends of every
+// connection against the CA certificate they were given.
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+)
+
+var ErrBadSignature = errors.New("42fs: bad signature")
+
+// LoadOrCreateKey reads an ECDSA private key from a PEM file, generating and
+// saving a new one (mode 0600) if the file does not exist.
+func LoadOrCreateKey(path string) (*ecdsa.PrivateKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if err == nil {
+		return ParseKey(b)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return nil, err
+	}
+	b = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	err = ioutil.WriteFile(path, b, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// ParseKey decodes a PEM-encoded ECDSA private key.
+func ParseKey(b []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "EC PRIVATE KEY" {
+		return nil, errors.New("42fs: no EC PRIVATE KEY block in key file")
+	}
+	return x509.ParseECPrivateKey(block.Bytes)
+}
+
+// MarshalPublicKey returns the DER (PKIX) encoding of a public key.
+func MarshalPublicKey(pub *ecdsa.PublicKey) ([]byte, error) {
+	return x509.MarshalPKIXPublicKey(pub)
+}
+
+// ParsePublicKey is the inverse of MarshalPublicKey.
+func ParsePublicKey(der []byte) (*ecdsa.PublicKey, error) {
+	k, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := k.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("42fs: public key is not ECDSA")
+	}
+	return pub, nil
+}
+
+type ecdsaSig struct {
+	R, S *big.Int
+}
+
+func sign(key *ecdsa.PrivateKey, msg []byte) ([]byte, error) {
+	h := sha256.Sum256(msg)
+	r, s, err := ecdsa.Sign(rand.Reader, key, h[:])
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(ecdsaSig{r, s})
+}
+
+func verify(pub *ecdsa.PublicKey, msg []byte, sig []byte) error {
+	var es ecdsaSig
+	rest, err := asn1.Unmarshal(sig, &es)
+	if err != nil || len(rest) != 0 {
+		return ErrBadSignature
+	}
+	h := sha256.Sum256(msg)
+	if !ecdsa.Verify(pub, h[:], es.R, es.S) {
+		return ErrBadSignature
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// metadataKey carries the caller's token on every call.
+const metadataKey = "x-42fs-token"
+
+// Session holds a daemon's current token and the identity verified from it.
+// It is used as gRPC per-call credentials for outgoing calls to peers, and
+// its interceptor verifies the tokens of incoming calls.
+type Session struct {
+	lock     sync.Mutex
+	verifier *Verifier
+	token    string
+	id       *Identity
+
+	// set only with UseTLS
+	roots    *x509.CertPool
+	cert     *tls.Certificate
+	certLeaf *x509.Certificate
+}
+
+func NewSession() *Session {
+	return &Session{}
+}
+
+// NewUnverifiedSession is for running without a coordinator. It reports
+// login as the identity, but has no token, so peers refuse its calls.
+func NewUnverifiedSession(login string) *Session {
+	return &Session{id: &Identity{Login: login}}
+}
+
+// SetToken installs a token returned by the coordinator. issuerKey is pinned
+// the first time; a token signed by a different key is rejected.
+func (s *Session) SetToken(token string, issuerKey []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.verifier == nil {
+		v, err := NewVerifier(issuerKey)
+		if err != nil {
+			return err
+		}
+		s.verifier = v
+	} else if !s.verifier.sameKey(issuerKey) {
+		return errors.New("42fs: coordinator signing key changed")
+	}
+	id, err := s.verifier.Verify(token)
+	if err != nil {
+		return err
+	}
+	if s.id != nil && s.id.Login != id.Login {
+		return errors.New("42fs: coordinator issued a token for a different login")
+	}
+	s.token = token
+	s.id = id
+	return nil
+}
+
+// Identity returns who this daemon is, or nil before the first token.
+func (s *Session) Identity() *Identity {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.id
+}
+
+// GetRequestMetadata implements credentials.PerRPCCredentials. Before the
+// first registration calls go out without a token, which is enough to
+// register but not to talk to peers.
+func (s *Session) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.token == "" {
+		return map[string]string{}, nil
+	}
+	return map[string]string{metadataKey: s.token}, nil
+}
+
+// RequireTransportSecurity implements credentials.PerRPCCredentials.
+func (s *Session) RequireTransportSecurity() bool {
+	return false
+}
+
+type identityKey struct{}
+
+// FromContext returns the verified identity of the caller of an incoming
+// call, as established by the server interceptors.
+func FromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(*Identity)
+	return id, ok
+}
+
+// NewContext returns a context carrying id, as if it had been verified.
+func NewContext(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// PeerHost returns the IP address an incoming call came from.
+func PeerHost(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return ""
+	}
+	return host
+}
+
+// peerTLS returns the TLS state of the connection an incoming call came in
+// on, or nil if it isn't using TLS.
+func peerTLS(ctx context.Context) *tls.ConnectionState {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil
+	}
+	return &info.State
+}
+
+// verifyIncoming checks the token of an incoming call against v and the
+// address the call came from. It returns a nil Identity if there is no token.
+func verifyIncoming(ctx context.Context, v *Verifier) (*Identity, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	tokens := md[metadataKey]
+	if len(tokens) == 0 {
+		return nil, nil
+	} else if len(tokens) != 1 {
+		return nil, status.Errorf(codes.Unauthenticated, "more than one token")
+	}
+	id, err := v.Verify(tokens[0])
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "%v", err)
+	}
+	if id.Host != PeerHost(ctx) {
+		return nil, status.Errorf(codes.Unauthenticated, "token was not issued to this host")
+	}
+	return id, nil
+}
+
+// authenticate returns the context to handle an incoming call with. It
+// rejects calls that do not carry a valid token issued to the host they come
+// from, or, with TLS, whose client certificate names a different login than
+// the token.
+func (s *Session) authenticate(ctx context.Context) (context.Context, error) {
+	s.lock.Lock()
+	v := s.verifier
+	s.lock.Unlock()
+	if v == nil {
+		return nil, status.Errorf(codes.Unavailable, "not registered with a coordinator yet")
+	}
+
+	id, err := verifyIncoming(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	if id == nil {
+		return nil, status.Errorf(codes.Unauthenticated, "no token")
+	}
+	err = s.checkTLSLogin(peerTLS(ctx), id.Login)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "%v", err)
+	}
+	return NewContext(ctx, id), nil
+}
+
+// UnaryServerInterceptor authenticates unary calls, see authenticate.
+func (s *Session) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := s.authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor authenticates streaming calls, see authenticate.
+func (s *Session) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := s.authenticate(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// serverStream replaces the context of a stream with one carrying the
+// caller's identity.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (ss *serverStream) Context() context.Context {
+	return ss.ctx
+}
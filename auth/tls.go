@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"time"
+)
+
+// renewCertBefore is how long before expiry a daemon asks for a new
+// certificate.
+const renewCertBefore = time.Hour
+
+var errNoCertificate = errors.New("42fs: no certificate from the coordinator yet")
+
+// UseTLS switches the session to TLS for peer traffic. roots is the pinned
+// coordinator CA, which signs every daemon's certificate.
+func (s *Session) UseTLS(roots *x509.CertPool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.roots = roots
+}
+
+// TLSEnabled reports whether UseTLS was called.
+func (s *Session) TLSEnabled() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.roots != nil
+}
+
+// NeedsCertificate reports whether the next registration should include a
+// certificate request.
+func (s *Session) NeedsCertificate() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.roots == nil {
+		return false
+	}
+	return s.certLeaf == nil || time.Until(s.certLeaf.NotAfter) < renewCertBefore
+}
+
+// SetCertificate installs the certificate the coordinator issued for key.
+func (s *Session) SetCertificate(certDER []byte, key *ecdsa.PrivateKey) error {
+	leaf, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:     s.roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	if err != nil {
+		return err
+	}
+	s.cert = &tls.Certificate{
+		Certificate: [][]byte{certDER},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+	s.certLeaf = leaf
+	return nil
+}
+
+func (s *Session) certificate() (*tls.Certificate, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.cert == nil {
+		return nil, errNoCertificate
+	}
+	return s.cert, nil
+}
+
+// PeerServerTLS is the TLS configuration for serving peers. Callers must
+// present a certificate from the same CA.
+func (s *Session) PeerServerTLS() *tls.Config {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return s.certificate()
+		},
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  s.roots,
+		MinVersion: tls.VersionTLS12,
+	}
+}
+
+// PeerClientTLS is the TLS configuration for dialing login's daemon.
+func (s *Session) PeerClientTLS(login string) *tls.Config {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return &tls.Config{
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return s.certificate()
+		},
+		RootCAs:    s.roots,
+		ServerName: PeerServerName(login),
+		MinVersion: tls.VersionTLS12,
+	}
+}
+
+// CoordinatorTLS is the TLS configuration for dialing the coordinator.
+func CoordinatorTLS(roots *x509.CertPool) *tls.Config {
+	return &tls.Config{
+		RootCAs:    roots,
+		ServerName: CoordinatorServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+}
+
+// checkTLSLogin checks that the client certificate of a TLS connection names
+// login. Connections without TLS are accepted when the session doesn't use
+// it.
+func (s *Session) checkTLSLogin(state *tls.ConnectionState, login string) error {
+	if state == nil {
+		if s.TLSEnabled() {
+			return errors.New("42fs: peer connection is not using TLS")
+		}
+		return nil
+	}
+	if len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return errors.New("42fs: no verified client certificate")
+	}
+	return state.VerifiedChains[0][0].VerifyHostname(PeerServerName(login))
+}
@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// DefaultTokenTTL is how long a token issued by the coordinator stays valid.
+// Daemons renew theirs well before that when they re-register.
+const DefaultTokenTTL = 10 * time.Minute
+
+// MaxClockSkew bounds how far the timestamp of a signed registration may be
+// from the coordinator's clock.
+const MaxClockSkew = 5 * time.Minute
+
+var (
+	ErrMalformedToken = errors.New("42fs: malformed token")
+	ErrExpiredToken   = errors.New("42fs: token expired")
+	ErrStaleLogin     = errors.New("42fs: login signature too old or in the future")
+)
+
+// Identity is what a verified token says about whoever presented it.
+type Identity struct {
+	Login string
+	// Host is the IP address the coordinator saw the registration come from.
+	Host    string
+	Expires time.Time
+}
+
+// Issuer signs tokens. Only the coordinator has one.
+type Issuer struct {
+	TTL time.Duration
+
+	key *ecdsa.PrivateKey
+}
+
+func NewIssuer(key *ecdsa.PrivateKey) *Issuer {
+	return &Issuer{TTL: DefaultTokenTTL, key: key}
+}
+
+// PublicKey returns the key daemons need to verify issued tokens.
+func (is *Issuer) PublicKey() ([]byte, error) {
+	return MarshalPublicKey(&is.key.PublicKey)
+}
+
+// Verifier returns a verifier for the tokens this issuer signs.
+func (is *Issuer) Verifier() *Verifier {
+	der, _ := MarshalPublicKey(&is.key.PublicKey)
+	return &Verifier{pub: &is.key.PublicKey, der: der}
+}
+
+// UnaryServerInterceptor verifies the token of incoming calls that carry
+// one, making the caller's identity available through FromContext. Calls
+// without a token are let through; handlers that need an identity must
+// check for it.
+func (is *Issuer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	v := is.Verifier()
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		id, err := verifyIncoming(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+		if id != nil {
+			ctx = NewContext(ctx, id)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of
+// UnaryServerInterceptor.
+func (is *Issuer) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	v := is.Verifier()
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		id, err := verifyIncoming(ss.Context(), v)
+		if err != nil {
+			return err
+		}
+		if id != nil {
+			ss = &serverStream{ServerStream: ss, ctx: NewContext(ss.Context(), id)}
+		}
+		return handler(srv, ss)
+	}
+}
+
+// Issue creates a token for login registered from host.
+func (is *Issuer) Issue(login, host string) (string, *Identity, error) {
+	id := &Identity{
+		Login:   login,
+		Host:    host,
+		Expires: time.Now().Add(is.TTL),
+	}
+	claims, err := json.Marshal(id)
+	if err != nil {
+		return "", nil, err
+	}
+	sig, err := sign(is.key, claims)
+	if err != nil {
+		return "", nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(claims) + "." + base64.RawURLEncoding.EncodeToString(sig)
+	return token, id, nil
+}
+
+// Verifier checks tokens issued by the coordinator.
+type Verifier struct {
+	pub *ecdsa.PublicKey
+	der []byte
+}
+
+// NewVerifier creates a verifier from the DER public key returned by
+// Issuer.PublicKey.
+func NewVerifier(der []byte) (*Verifier, error) {
+	pub, err := ParsePublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	return &Verifier{pub: pub, der: der}, nil
+}
+
+// Verify checks the signature and expiry of a token.
+func (v *Verifier) Verify(token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, ErrMalformedToken
+	}
+	claims, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	err = verify(v.pub, claims, sig)
+	if err != nil {
+		return nil, err
+	}
+	var id Identity
+	err = json.Unmarshal(claims, &id)
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	if time.Now().After(id.Expires) {
+		return nil, ErrExpiredToken
+	}
+	return &id, nil
+}
+
+func (v *Verifier) sameKey(der []byte) bool {
+	return bytes.Equal(v.der, der)
+}
+
+// loginMessage is what a registration signs. It covers the CSR, by hash, so
+// that a registration replayed within MaxClockSkew can't get a certificate
+// for a key of the replayer's choosing.
+func loginMessage(login, addr string, rootINode uint64, csr []byte, t time.Time) []byte {
+	csrHash := sha256.Sum256(csr)
+	return []byte(login + "\x00" + addr + "\x00" + strconv.FormatUint(rootINode, 10) + "\x00" +
+		base64.RawURLEncoding.EncodeToString(csrHash[:]) + "\x00" + t.UTC().Format(time.RFC3339Nano))
+}
+
+// SignLogin produces the proof of identity a daemon sends when registering
+// with the coordinator. csr may be nil.
+func SignLogin(key *ecdsa.PrivateKey, login, addr string, rootINode uint64, csr []byte, t time.Time) ([]byte, error) {
+	return sign(key, loginMessage(login, addr, rootINode, csr, t))
+}
+
+// VerifyLogin checks a registration signed with SignLogin against the key
+// pinned for that login.
+func VerifyLogin(pubDER []byte, login, addr string, rootINode uint64, csr []byte, t time.Time, sig []byte) error {
+	pub, err := ParsePublicKey(pubDER)
+	if err != nil {
+		return err
+	}
+	skew := time.Since(t)
+	if skew > MaxClockSkew || skew < -MaxClockSkew {
+		return ErrStaleLogin
+	}
+	return verify(pub, loginMessage(login, addr, rootINode, csr, t), sig)
+}
@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestIssueVerify(t *testing.T) {
+	is := NewIssuer(newKey(t))
+	token, issued, err := is.Issue("alice", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := is.Verifier().Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Login != "alice" || id.Host != "10.0.0.1" || !id.Expires.Equal(issued.Expires) {
+		t.Errorf("verified %+v, issued %+v", id, issued)
+	}
+	if d := time.Until(id.Expires); d <= 0 || d > DefaultTokenTTL {
+		t.Errorf("token expires in %v", d)
+	}
+
+	// through the key the coordinator hands out
+	der, err := is.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewVerifier(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Verify(token); err != nil {
+		t.Errorf("verifier from the public key: %v", err)
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	is := NewIssuer(newKey(t))
+	is.TTL = -time.Second
+	token, _, err := is.Issue("alice", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := is.Verifier().Verify(token); err != ErrExpiredToken {
+		t.Errorf("expired token: got %v, want %v", err, ErrExpiredToken)
+	}
+}
+
+func TestTokenForged(t *testing.T) {
+	is := NewIssuer(newKey(t))
+	token, _, err := is.Issue("alice", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := NewIssuer(newKey(t)).Issue("alice", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+	mallory, _, err := is.Issue("mallory", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// mallory's claims with alice's signature
+	swapped := strings.Split(mallory, ".")[0] + "." + parts[1]
+
+	for _, c := range []struct {
+		name, token string
+		want        error
+	}{
+		{"other issuer", other, ErrBadSignature},
+		{"swapped claims", swapped, ErrBadSignature},
+		{"no signature", parts[0], ErrMalformedToken},
+		{"not base64", "!." + parts[1], ErrMalformedToken},
+		{"empty", "", ErrMalformedToken},
+	} {
+		if _, err := is.Verifier().Verify(c.token); err != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.want)
+		}
+	}
+}
+
+// incoming returns the context of a call from host carrying token.
+func incoming(host, token string) context.Context {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP(host), Port: 4243},
+	})
+	if token == "" {
+		return ctx
+	}
+	return metadata.NewIncomingContext(ctx, metadata.Pairs(metadataKey, token))
+}
+
+func TestTokenHostBinding(t *testing.T) {
+	is := NewIssuer(newKey(t))
+	token, _, err := is.Issue("alice", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := is.Verifier()
+
+	id, err := verifyIncoming(incoming("10.0.0.1", token), v)
+	if err != nil || id == nil || id.Login != "alice" {
+		t.Errorf("call from the registered host: %+v, %v", id, err)
+	}
+	_, err = verifyIncoming(incoming("10.0.0.2", token), v)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("token used from another host: got %v, want Unauthenticated", err)
+	}
+	id, err = verifyIncoming(incoming("10.0.0.2", ""), v)
+	if id != nil || err != nil {
+		t.Errorf("call without a token: %+v, %v", id, err)
+	}
+}
+
+func TestSessionPinsIssuer(t *testing.T) {
+	is := NewIssuer(newKey(t))
+	der, _ := is.PublicKey()
+	token, _, _ := is.Issue("alice", "10.0.0.1")
+	s := NewSession()
+	if err := s.SetToken(token, der); err != nil {
+		t.Fatal(err)
+	}
+	if id := s.Identity(); id == nil || id.Login != "alice" {
+		t.Errorf("session identity is %+v", id)
+	}
+
+	other := NewIssuer(newKey(t))
+	otherDER, _ := other.PublicKey()
+	otherToken, _, _ := other.Issue("alice", "10.0.0.1")
+	if err := s.SetToken(otherToken, otherDER); err == nil {
+		t.Error("session accepted a token from a different coordinator key")
+	}
+}
+
+func TestSignLogin(t *testing.T) {
+	key := newKey(t)
+	pub, err := MarshalPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey := newKey(t)
+	otherPub, _ := MarshalPublicKey(&otherKey.PublicKey)
+	now := time.Now()
+	csr := []byte("csr")
+	sig, err := SignLogin(key, "alice", "10.0.0.1:4243", 42, csr, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyLogin(pub, "alice", "10.0.0.1:4243", 42, csr, now, sig); err != nil {
+		t.Errorf("valid registration: %v", err)
+	}
+
+	for _, c := range []struct {
+		name  string
+		pub   []byte
+		login string
+		addr  string
+		csr   []byte
+	}{
+		{"other key", otherPub, "alice", "10.0.0.1:4243", csr},
+		{"other login", pub, "bob", "10.0.0.1:4243", csr},
+		{"other address", pub, "alice", "10.0.0.2:4243", csr},
+		{"other CSR", pub, "alice", "10.0.0.1:4243", []byte("mallory's csr")},
+	} {
+		if err := VerifyLogin(c.pub, c.login, c.addr, 42, c.csr, now, sig); err != ErrBadSignature {
+			t.Errorf("%s: got %v, want %v", c.name, err, ErrBadSignature)
+		}
+	}
+
+	for _, at := range []time.Time{now.Add(-MaxClockSkew - time.Minute), now.Add(MaxClockSkew + time.Minute)} {
+		sig, err := SignLogin(key, "alice", "10.0.0.1:4243", 42, nil, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyLogin(pub, "alice", "10.0.0.1:4243", 42, nil, at, sig); err != ErrStaleLogin {
+			t.Errorf("signed %v from now: got %v, want %v", at.Sub(now), err, ErrStaleLogin)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"log"
+	"net"
+	"time"
+
+	"github.com/riking/42fs/auth"
+	"github.com/riking/42fs/coordserver"
+	fgrpc "github.com/riking/42fs/grpc"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+var (
+	flagListen = flag.String("listen", ":4242", "address to serve the coordinator on")
+	flagState  = flag.String("state", "", "file to persist known logins in (empty: memory only)")
+	flagKey    = flag.String("key", "coordinator_key.pem", "token signing key, created if missing")
+	flagTTL    = flag.Duration("token-ttl", auth.DefaultTokenTTL, "lifetime of issued tokens")
+
+	flagSnapshots       = flag.String("snapshot-dir", "", "directory to persist offline snapshots in (empty: memory only)")
+	flagMaxManifestSize = flag.Int("max-manifest-size", 16<<20, "largest snapshot manifest a daemon may send, in bytes")
+	flagGCInterval      = flag.Duration("gc-interval", time.Hour, "how often to delete file contents no snapshot uses any more")
+
+	flagInsecure = flag.Bool("insecure", false, "serve without TLS")
+	flagCACert   = flag.String("ca-cert", "ca.pem", "CA certificate daemons pin; a self-signed one is created if missing")
+	flagCAKey    = flag.String("ca-key", "ca_key.pem", "CA private key")
+)
+
+func collectGarbage(snaps *coordserver.SnapshotStore, interval time.Duration) {
+	for range time.Tick(interval) {
+		n, err := snaps.CollectGarbage(coordserver.DefaultGCGrace)
+		if err != nil {
+			log.Println("snapshot garbage collection:", err)
+		} else if n > 0 {
+			log.Printf("removed %d unused snapshot blobs", n)
+		}
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	reg, err := coordserver.NewRegistry(*flagState)
+	if err != nil {
+		log.Fatal(err)
+	}
+	key, err := auth.LoadOrCreateKey(*flagKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	issuer := auth.NewIssuer(key)
+	issuer.TTL = *flagTTL
+	snaps, err := coordserver.NewSnapshotStore(*flagSnapshots)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go collectGarbage(snaps, *flagGCInterval)
+
+	lis, err := net.Listen("tcp", *flagListen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(issuer.UnaryServerInterceptor()),
+		grpc.StreamInterceptor(issuer.StreamServerInterceptor()),
+		grpc.MaxRecvMsgSize(*flagMaxManifestSize),
+	}
+	var ca *auth.CA
+	if !*flagInsecure {
+		ca, err = auth.LoadOrCreateCA(*flagCACert, *flagCAKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cert, err := ca.ServerCertificate()
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(&tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		})))
+		log.Println("daemons must pin", *flagCACert)
+	}
+	s := grpc.NewServer(opts...)
+	fgrpc.RegisterCoordinatorService(s, coordserver.NewServer(reg, snaps, issuer, ca))
+	fgrpc.RegisterUserConnection(s, coordserver.NewSnapshotServer(snaps))
+
+	log.Println("coordinator listening on", lis.Addr())
+	err = s.Serve(lis)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,77 @@
+// Command 42fs is the client utility of 42fs. It sets up the FUSE daemon
+// for the current user, reports whether it works, and removes it again.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/riking/42fs/config"
+)
+
+const usage = `usage: 42fs <command> [flags]
+
+commands:
+  setup      create ~/public, write the config and start the daemon at login
+  status     check the daemon, the mount and the coordinator
+  uninstall  stop the daemon and remove what setup installed
+
+Run 42fs <command> -h for the flags of a command.
+`
+
+// daemonName is the FUSE daemon's binary, looked for next to this one and
+// in $PATH.
+const daemonName = "42fsd"
+
+func fatal(cmd string, err error) {
+	fmt.Fprintf(os.Stderr, "42fs %s: %v\n", cmd, err)
+	os.Exit(1)
+}
+
+// configFlag adds the -config flag every command takes.
+func configFlag(fs *flag.FlagSet) *string {
+	def, err := config.DefaultPath()
+	if err != nil {
+		def = ""
+	}
+	return fs.String("config", def, "config file of the daemon")
+}
+
+// findDaemon returns the absolute path of the daemon binary.
+func findDaemon() (string, error) {
+	if exe, err := os.Executable(); err == nil {
+		p := filepath.Join(filepath.Dir(exe), daemonName)
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	p, err := exec.LookPath(daemonName)
+	if err != nil {
+		return "", fmt.Errorf("%s not found next to 42fs or in $PATH; pass its location with -daemon", daemonName)
+	}
+	return filepath.Abs(p)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	cmd, args := os.Args[1], os.Args[2:]
+	switch cmd {
+	case "setup":
+		setup(args)
+	case "status":
+		os.Exit(status(args))
+	case "uninstall":
+		uninstall(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "42fs: unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// service starts the daemon with the user's session, through systemd on
+// Linux and launchd on macOS. The platform files provide newService,
+// serviceFile, start, stop, forget and running.
+type service struct {
+	// path of the unit or property list file
+	path string
+}
+
+func (s *service) installed() bool {
+	_, err := os.Stat(s.path)
+	return err == nil
+}
+
+// install writes the file that starts daemon with the config at
+// configPath.
+func (s *service) install(daemon, configPath string) error {
+	err := os.MkdirAll(filepath.Dir(s.path), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.path, serviceFile(daemon, configPath), 0644)
+}
+
+func (s *service) remove() error {
+	err := os.Remove(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// run runs a service manager command, returning its output as the error
+// if it fails.
+func run(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil && len(out) > 0 {
+		return &commandError{cmd: name + " " + strings.Join(args, " "), out: strings.TrimSpace(string(out))}
+	}
+	return err
+}
+
+type commandError struct {
+	cmd, out string
+}
+
+func (e *commandError) Error() string {
+	return e.cmd + ": " + e.out
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+const agentLabel = "org.42fs.daemon"
+
+func newService() (*service, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	return &service{path: filepath.Join(home, "Library", "LaunchAgents", agentLabel+".plist")}, nil
+}
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func serviceFile(daemon, configPath string) []byte {
+	logPath := filepath.Join(filepath.Dir(configPath), "daemon.log")
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>%s</string>
+		<string>-config</string>
+		<string>%s</string>
+	</array>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+	<key>StandardErrorPath</key>
+	<string>%s</string>
+</dict>
+</plist>
+`, agentLabel, xmlEscape(daemon), xmlEscape(configPath), xmlEscape(logPath)))
+}
+
+func (s *service) start() error {
+	return run("launchctl", "load", "-w", s.path)
+}
+
+func (s *service) stop() error {
+	return run("launchctl", "unload", "-w", s.path)
+}
+
+// forget does nothing: unloading the agent already made launchd drop it.
+func (s *service) forget() error {
+	return nil
+}
+
+func (s *service) running() (bool, error) {
+	out, err := exec.Command("launchctl", "list", agentLabel).Output()
+	if _, ok := err.(*exec.ExitError); ok {
+		// not loaded
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return bytes.Contains(out, []byte(`"PID" =`)), nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+const unitName = "42fsd.service"
+
+func newService() (*service, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return &service{path: filepath.Join(dir, "systemd", "user", unitName)}, nil
+}
+
+func serviceFile(daemon, configPath string) []byte {
+	return []byte(fmt.Sprintf(`[Unit]
+Description=42fs FUSE daemon
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+ExecStart=%q -config %q
+Restart=on-failure
+RestartSec=10
+
+[Install]
+WantedBy=default.target
+`, daemon, configPath))
+}
+
+func (s *service) start() error {
+	err := run("systemctl", "--user", "daemon-reload")
+	if err != nil {
+		return err
+	}
+	return run("systemctl", "--user", "enable", "--now", unitName)
+}
+
+func (s *service) stop() error {
+	return run("systemctl", "--user", "disable", "--now", unitName)
+}
+
+// forget makes systemd drop the unit once its file is removed.
+func (s *service) forget() error {
+	return run("systemctl", "--user", "daemon-reload")
+}
+
+func (s *service) running() (bool, error) {
+	err := exec.Command("systemctl", "--user", "is-active", "--quiet", unitName).Run()
+	if _, ok := err.(*exec.ExitError); ok {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/riking/42fs/config"
+	"github.com/riking/42fs/libfuse"
+)
+
+// absPath makes a path given on the command line absolute, so that the
+// daemon finds it whatever directory it is started in.
+func absPath(p string) (string, error) {
+	if p == "" {
+		return "", nil
+	}
+	if p == "~" || len(p) > 1 && p[:2] == "~/" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		p = filepath.Join(home, p[1:])
+	}
+	return filepath.Abs(p)
+}
+
+// checkMountDir makes sure dir can be mounted on: it must be an empty
+// directory, unless the daemon already has it mounted.
+func checkMountDir(dir string) error {
+	mounted, err := libfuse.Mounted(libfuse.NewDefaultMounter(dir))
+	if err == libfuse.ErrStaleMount {
+		return fmt.Errorf("%s: %v; run 42fs uninstall first", dir, err)
+	} else if mounted {
+		return nil
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(fis) != 0 {
+		return fmt.Errorf("mount point %s is not empty; pick another one with -mount", dir)
+	}
+	return nil
+}
+
+// checkPublicDir creates the public directory, and warns if peers couldn't
+// enter it.
+func checkPublicDir(dir string) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	if fi.Mode().Perm()&0001 == 0 {
+		fmt.Printf("warning: other users can't enter %s; share it with chmod o+x %s\n", dir, dir)
+	}
+	return nil
+}
+
+func setup(args []string) {
+	fs := flag.NewFlagSet("42fs setup", flag.ExitOnError)
+	configPath := configFlag(fs)
+	login := fs.String("login", "", "your 42 login (default: the current user)")
+	public := fs.String("public", "", "directory to share with other users (default: ~/public)")
+	mount := fs.String("mount", "", "where to mount everyone's public directories (default: ~/42fs)")
+	coordinator := fs.String("coordinator", "", "host:port of the coordination server")
+	coordCA := fs.String("coordinator-ca", "", "CA certificate of the coordinator")
+	insecure := fs.Bool("insecure", false, "talk to the coordinator and peers without TLS")
+	listen := fs.String("listen", "", "address to serve files to other daemons on (default: :4243)")
+	daemon := fs.String("daemon", "", "path of the "+daemonName+" binary (default: next to 42fs or in $PATH)")
+	noService := fs.Bool("no-service", false, "only write the config, don't start the daemon at login")
+	fs.Parse(args)
+
+	// running setup again keeps what isn't changed
+	cfg, err := config.Load(*configPath)
+	if os.IsNotExist(err) {
+		cfg, err = config.Default()
+	}
+	if err != nil {
+		fatal("setup", err)
+	}
+	set := func(dst *string, val string, path bool) {
+		if val == "" {
+			return
+		}
+		if path {
+			val, err = absPath(val)
+			if err != nil {
+				fatal("setup", err)
+			}
+		}
+		*dst = val
+	}
+	set(&cfg.Login, *login, false)
+	set(&cfg.PublicDir, *public, true)
+	set(&cfg.MountDir, *mount, true)
+	set(&cfg.Coordinator, *coordinator, false)
+	set(&cfg.CoordinatorCA, *coordCA, true)
+	set(&cfg.Listen, *listen, false)
+	if *insecure {
+		cfg.Insecure = true
+	}
+
+	// the daemon can run without one, but then there is nothing to set up
+	if cfg.Coordinator == "" {
+		fatal("setup", errors.New("-coordinator is required: ask your staff for the address of the coordination server"))
+	}
+	err = checkPublicDir(cfg.PublicDir)
+	if err != nil {
+		fatal("setup", err)
+	}
+	err = checkMountDir(cfg.MountDir)
+	if err != nil {
+		fatal("setup", err)
+	}
+	err = cfg.Validate()
+	if err != nil {
+		fatal("setup", err)
+	}
+	err = cfg.Save(*configPath)
+	if err != nil {
+		fatal("setup", err)
+	}
+	fmt.Println("wrote", *configPath)
+
+	if *noService {
+		return
+	}
+	if *daemon == "" {
+		*daemon, err = findDaemon()
+	} else {
+		*daemon, err = absPath(*daemon)
+	}
+	if err != nil {
+		fatal("setup", err)
+	}
+	svc, err := newService()
+	if err != nil {
+		fatal("setup", err)
+	}
+	err = svc.install(*daemon, *configPath)
+	if err != nil {
+		fatal("setup", err)
+	}
+	fmt.Println("installed", svc.path)
+	err = svc.start()
+	if err != nil {
+		fatal("setup", fmt.Errorf("starting the daemon: %v", err))
+	}
+	fmt.Printf("started %s; your files are shared from %s and everyone's appear in %s\n",
+		daemonName, cfg.PublicDir, cfg.MountDir)
+}
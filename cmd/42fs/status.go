@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/riking/42fs/auth"
+	"github.com/riking/42fs/config"
+	fgrpc "github.com/riking/42fs/grpc"
+	"github.com/riking/42fs/libfuse"
+
+	"golang.org/x/net/context"
+)
+
+// statusTimeout bounds each network check.
+const statusTimeout = 5 * time.Second
+
+// localAddr turns a listen address into one to dial on this machine.
+func localAddr(listen string) string {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return listen
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// checkCoordinator lists the online users, which needs no token.
+func checkCoordinator(cfg *config.Config) ([]fgrpc.LoginInfo, error) {
+	var tlsConfig *tls.Config
+	if !cfg.Insecure {
+		roots, err := auth.LoadCertPool(cfg.CoordinatorCA)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig = auth.CoordinatorTLS(roots)
+	}
+	cc, err := fgrpc.Dial(cfg.Coordinator, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	coord := fgrpc.NewCoordinatorClient(cc)
+	defer coord.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), statusTimeout)
+	defer cancel()
+	return coord.ListUsers(ctx, true)
+}
+
+// status prints the state of each part of the setup and returns the exit
+// code: 0 if everything works.
+func status(args []string) int {
+	fs := flag.NewFlagSet("42fs status", flag.ExitOnError)
+	configPath := configFlag(fs)
+	fs.Parse(args)
+
+	cfg, err := config.Load(*configPath)
+	if os.IsNotExist(err) {
+		fmt.Println("not set up: run 42fs setup")
+		return 1
+	} else if err != nil {
+		fmt.Println("config:", err)
+		return 1
+	}
+	code := 0
+	report := func(what string, err error, ok string) {
+		if err != nil {
+			fmt.Printf("%-12s %v\n", what+":", err)
+			code = 1
+		} else {
+			fmt.Printf("%-12s %s\n", what+":", ok)
+		}
+	}
+	fmt.Printf("%-12s %s\n", "login:", cfg.Login)
+
+	svc, err := newService()
+	if err == nil {
+		var running bool
+		running, err = svc.running()
+		if err == nil && !running && svc.installed() {
+			err = fmt.Errorf("%s is not running", daemonName)
+		} else if err == nil && !running {
+			err = fmt.Errorf("%s is not installed: run 42fs setup", svc.path)
+		}
+	}
+	report("daemon", err, "running")
+
+	mounted, err := libfuse.Mounted(libfuse.NewDefaultMounter(cfg.MountDir))
+	if err == nil && !mounted {
+		err = fmt.Errorf("%s is not mounted", cfg.MountDir)
+	}
+	report("mount", err, cfg.MountDir)
+
+	addr := localAddr(cfg.Listen)
+	conn, err := net.DialTimeout("tcp", addr, statusTimeout)
+	if err == nil {
+		conn.Close()
+	}
+	report("peer server", err, "listening on "+addr)
+
+	if cfg.Coordinator == "" {
+		report("coordinator", nil, "no coordinator configured")
+		return code
+	}
+	users, err := checkCoordinator(cfg)
+	report("coordinator", err, "reachable at "+cfg.Coordinator)
+	if err != nil {
+		return code
+	}
+	var logins []string
+	registered := false
+	for _, u := range users {
+		logins = append(logins, u.Login)
+		registered = registered || u.Login == cfg.Login
+	}
+	if !registered {
+		report("registered", fmt.Errorf("the coordinator doesn't list %s as online", cfg.Login), "")
+	} else {
+		report("registered", nil, "online")
+	}
+	fmt.Printf("%-12s %d: %s\n", "online:", len(logins), strings.Join(logins, " "))
+	return code
+}
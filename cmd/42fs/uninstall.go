@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/riking/42fs/config"
+	"github.com/riking/42fs/libfuse"
+)
+
+// uninstall undoes setup. The public directory and the login key are kept:
+// the first holds the user's files, and the coordinator refuses any other
+// key for the login.
+func uninstall(args []string) {
+	fs := flag.NewFlagSet("42fs uninstall", flag.ExitOnError)
+	configPath := configFlag(fs)
+	fs.Parse(args)
+
+	svc, err := newService()
+	if err != nil {
+		fatal("uninstall", err)
+	}
+	if svc.installed() {
+		err = svc.stop()
+		if err != nil {
+			fmt.Println("stopping the daemon:", err)
+		}
+		err = svc.remove()
+		if err != nil {
+			fatal("uninstall", err)
+		}
+		fmt.Println("removed", svc.path)
+		err = svc.forget()
+		if err != nil {
+			fmt.Println("reloading the service manager:", err)
+		}
+	}
+
+	cfg, err := config.Load(*configPath)
+	if os.IsNotExist(err) {
+		fmt.Println("no config at", *configPath)
+		return
+	} else if err != nil {
+		fatal("uninstall", err)
+	}
+	mounter := libfuse.NewForceMounter(cfg.MountDir)
+	if mounted, _ := libfuse.Mounted(mounter); mounted {
+		err = mounter.Unmount()
+		if err != nil {
+			fatal("uninstall", fmt.Errorf("unmounting %s: %v", cfg.MountDir, err))
+		}
+		fmt.Println("unmounted", cfg.MountDir)
+	}
+	// only succeeds if it is empty, as it should be once unmounted
+	if os.Remove(cfg.MountDir) == nil {
+		fmt.Println("removed", cfg.MountDir)
+	}
+	err = os.Remove(*configPath)
+	if err != nil {
+		fatal("uninstall", err)
+	}
+	fmt.Println("removed", *configPath)
+	fmt.Printf("kept %s and %s\n", cfg.PublicDir, cfg.LoginKey)
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/riking/42fs/libfuse"
+
+	"log"
+
+	"bazil.org/fuse"
+	"github.com/riking/42fs/auth"
+	"github.com/riking/42fs/config"
+	"github.com/riking/42fs/fscore"
+	fgrpc "github.com/riking/42fs/grpc"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+var flagConfig = flag.String("config", "", "config file written by 42fs setup (default: ~/.42fs/config.json if it exists)")
+
+// groupFlags collects -group flags, which look like name=login,login.
+type groupFlags map[string][]string
+
+func (g groupFlags) String() string {
+	return ""
+}
+
+func (g groupFlags) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return fmt.Errorf("expected name=login,login, got %q", s)
+	}
+	g[s[:i]] = append(g[s[:i]], strings.Split(s[i+1:], ",")...)
+	return nil
+}
+
+var flagGroups = groupFlags{}
+
+func init() {
+	flag.Var(flagGroups, "group", "`name=login,login` defines a group that .42fs-acl files can share with as @name (repeatable, adds to Groups in the config)")
+}
+
+// loadConfig combines, in increasing order of precedence, the defaults, the
+// config file, the environment and the command line.
+func loadConfig(flags *config.Flags) (*config.Config, error) {
+	path := *flagConfig
+	explicit := path != ""
+	if !explicit {
+		var err error
+		path, err = config.DefaultPath()
+		if err != nil {
+			return nil, err
+		}
+	}
+	cfg, err := config.Load(path)
+	if os.IsNotExist(err) && !explicit {
+		cfg, err = config.Default()
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.ApplyEnv()
+	if err != nil {
+		return nil, err
+	}
+	err = flags.Apply(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for name, logins := range flagGroups {
+		if cfg.Groups == nil {
+			cfg.Groups = make(map[string][]string)
+		}
+		cfg.Groups[name] = append(cfg.Groups[name], logins...)
+	}
+	return cfg, cfg.Validate()
+}
+
+// advertiseAddr is the address other daemons should dial to reach lis.
+func advertiseAddr(lis net.Listener) (string, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		return "", err
+	}
+	port := lis.Addr().(*net.TCPAddr).Port
+	return net.JoinHostPort(host, strconv.Itoa(port)), nil
+}
+
+// newRegisterRequest builds a registration signed with the login key. csr
+// may be nil.
+func newRegisterRequest(key *ecdsa.PrivateKey, login, addr string, rootINode uint64, csr []byte) (*fgrpc.RegisterRequest, error) {
+	pub, err := auth.MarshalPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	sig, err := auth.SignLogin(key, login, addr, rootINode, csr, now)
+	if err != nil {
+		return nil, err
+	}
+	return &fgrpc.RegisterRequest{
+		Login:     login,
+		Addr:      addr,
+		RootINode: rootINode,
+		PublicKey: pub,
+		Time:      now,
+		Signature: sig,
+		CSR:       csr,
+	}, nil
+}
+
+func main() {
+	flags := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	cfg, err := loadConfig(flags)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "42fsd:", err)
+		os.Exit(2)
+	}
+
+	var coord *fgrpc.CoordinatorClient
+	var coordServer fgrpc.CoordinatorServer
+	session := auth.NewUnverifiedSession(cfg.Login)
+	if cfg.Coordinator == "" {
+		log.Println("no coordinator configured: only your own files are served")
+	} else {
+		session = auth.NewSession()
+		var coordTLS *tls.Config
+		if !cfg.Insecure {
+			roots, err := auth.LoadCertPool(cfg.CoordinatorCA)
+			if err != nil {
+				log.Fatal(err)
+			}
+			session.UseTLS(roots)
+			coordTLS = auth.CoordinatorTLS(roots)
+		}
+		cc, err := fgrpc.Dial(cfg.Coordinator, coordTLS, grpc.WithPerRPCCredentials(session))
+		if err != nil {
+			log.Fatal(err)
+		}
+		coord = fgrpc.NewCoordinatorClient(cc)
+		coordServer = coord
+	}
+	fs42 := fscore.NewFS42(coordServer, session, cfg.PublicDir)
+	fs42.OnlineOnly = cfg.OnlineOnly
+	fs42.Dropbox = cfg.Dropbox
+	fs42.Groups = cfg.Groups
+	fs42.Symlinks = cfg.Symlinks
+	fs42.Cache = cfg.Cache()
+
+	lis, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stopBackground := context.WithCancel(context.Background())
+	if coord != nil {
+		key, err := auth.LoadOrCreateKey(cfg.LoginKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		addr, err := advertiseAddr(lis)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rootINode, err := fs42.LocalINode(context.Background())
+		if err != nil {
+			log.Fatal(err)
+		}
+		hostKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			log.Fatal(err)
+		}
+		newReq := func() (*fgrpc.RegisterRequest, error) {
+			var csr []byte
+			if session.NeedsCertificate() {
+				var err error
+				csr, err = auth.NewCSR(hostKey)
+				if err != nil {
+					return nil, err
+				}
+			}
+			return newRegisterRequest(key, cfg.Login, addr, rootINode, csr)
+		}
+		onRegistered := func(resp *fgrpc.RegisterResponse) error {
+			if resp.Certificate != nil {
+				err := session.SetCertificate(resp.Certificate, hostKey)
+				if err != nil {
+					return err
+				}
+			}
+			return session.SetToken(resp.Token, resp.IssuerKey)
+		}
+		req, err := newReq()
+		if err != nil {
+			log.Fatal(err)
+		}
+		resp, err := coord.Register(context.Background(), req)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = onRegistered(resp)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go coord.KeepRegistered(ctx, newReq, time.Minute, onRegistered)
+		go fscore.NewSnapshotSyncer(fs42, coord).Run(ctx, 10*time.Minute)
+	}
+
+	// from here on a signal must not kill the daemon before it unmounts;
+	// one that arrives early is handled once serving starts
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+	// mount once nothing else can fail, as exiting leaves the mount behind
+	mounter := libfuse.NewForceMounter(cfg.MountDir,
+		fuse.FSName("42fs"),
+		fuse.VolumeName("42fs"),
+		fuse.NoAppleDouble(),
+		//fuse.DefaultPermissions(),
+	)
+	conn, err := mounter.Mount()
+	if err != nil {
+		log.Fatalf("mounting %s: %v", cfg.MountDir, err)
+	}
+
+	peerOpts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(recoverUnary, session.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(recoverStream, session.StreamServerInterceptor()),
+	}
+	if session.TLSEnabled() {
+		peerOpts = append(peerOpts, grpc.Creds(credentials.NewTLS(session.PeerServerTLS())))
+	}
+	peerServer := grpc.NewServer(peerOpts...)
+	peers := fscore.NewPeerServer(fs42)
+	fgrpc.RegisterUserConnection(peerServer, peers)
+	go peerServer.Serve(lis)
+
+	d := &daemon{
+		mounter:        mounter,
+		conn:           conn,
+		served:         make(chan error, 1),
+		fs42:           fs42,
+		peers:          peers,
+		peerServer:     peerServer,
+		coord:          coord,
+		stopBackground: stopBackground,
+	}
+	go func() {
+		d.served <- fs42.Serve(conn)
+	}()
+	<-conn.Ready
+	if conn.MountError != nil {
+		log.Fatal(conn.MountError)
+	}
+	log.Printf("serving %s on %s, protocol %v", cfg.PublicDir, cfg.MountDir, conn.Protocol())
+
+	select {
+	case sig := <-sigs:
+		log.Printf("%v: shutting down", sig)
+		go func() {
+			<-sigs
+			log.Fatal("interrupted again: exiting without cleaning up")
+		}()
+		d.shutdown(true)
+	case err := <-d.served:
+		// unmounted from outside, with fusermount -u or umount
+		if err != nil {
+			log.Print(err)
+		}
+		log.Printf("%s was unmounted: shutting down", cfg.MountDir)
+		d.shutdown(false)
+	}
+}
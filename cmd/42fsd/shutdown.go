@@ -0,0 +1,91 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"bazil.org/fuse"
+	"github.com/riking/42fs/fscore"
+	fgrpc "github.com/riking/42fs/grpc"
+	"github.com/riking/42fs/libfuse"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+const (
+	// drainTimeout bounds how long peer calls in flight may take to finish.
+	drainTimeout = 10 * time.Second
+	// unregisterTimeout bounds telling the coordinator we're going offline.
+	unregisterTimeout = 5 * time.Second
+	// unmountTimeout bounds how long a lazy unmount may wait for processes
+	// still using the filesystem.
+	unmountTimeout = 10 * time.Second
+)
+
+// daemon holds what has to be stopped when 42fsd exits.
+type daemon struct {
+	mounter libfuse.Mounter
+	conn    *fuse.Conn
+	// served receives the result of fs42.Serve
+	served chan error
+
+	fs42       *fscore.FS42
+	peers      *fscore.PeerServer
+	peerServer *grpc.Server
+
+	// coord is nil when running without a coordinator
+	coord *fgrpc.CoordinatorClient
+	// stopBackground stops renewing the registration and syncing snapshots
+	stopBackground context.CancelFunc
+}
+
+// shutdown stops serving peers, tells the coordinator so that readers fall
+// back to the snapshot, unmounts and closes the owner's files. mounted is
+// false if the filesystem was already unmounted from outside.
+func (d *daemon) shutdown(mounted bool) {
+	d.stopBackground()
+
+	// refuse new peer calls and let the ones in flight finish
+	d.peers.Drain()
+	stopped := make(chan struct{})
+	go func() {
+		d.peerServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(drainTimeout):
+		log.Printf("peer calls still running after %v, cutting them off", drainTimeout)
+		d.peerServer.Stop()
+	}
+
+	if d.coord != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), unregisterTimeout)
+		err := d.coord.Unregister(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("telling the coordinator we're going offline: %v", err)
+		}
+	}
+
+	if mounted {
+		// falls back to a lazy unmount if files are still open
+		err := d.mounter.Unmount()
+		if err != nil {
+			log.Printf("unmounting %s: %v", d.mounter.Dir(), err)
+		}
+		select {
+		case <-d.served:
+		case <-time.After(unmountTimeout):
+			log.Printf("%s is still in use, aborting the connection", d.mounter.Dir())
+			d.conn.Close()
+			<-d.served
+		}
+	}
+
+	// nothing can reach the owner's files any more
+	d.fs42.Close()
+	if d.coord != nil {
+		d.coord.Close()
+	}
+}
@@ -0,0 +1,177 @@
+// Package config holds the settings of the 42fs FUSE daemon, which the
+// 42fs client utility writes during setup.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/riking/42fs/fscore"
+)
+
+// Config is saved as JSON, with the field names as keys. Paths are
+// absolute.
+type Config struct {
+	// Login is the 42 login the daemon registers as.
+	Login string
+	// PublicDir is the directory shared with other users.
+	PublicDir string
+	// MountDir is where the filesystem is mounted.
+	MountDir string
+
+	// Coordinator is the host:port of the coordination server.
+	Coordinator string
+	// CoordinatorCA is the CA certificate of the coordinator, which is
+	// pinned for all TLS traffic.
+	CoordinatorCA string
+	// Insecure turns TLS off, for testing.
+	Insecure bool
+	// LoginKey identifies the daemon to the coordinator. It is created if
+	// missing and must be kept: the coordinator pins it on first use.
+	LoginKey string
+
+	// Listen is the address other daemons reach this one on.
+	Listen string
+
+	// OnlineOnly leaves users whose daemon isn't running out of listings.
+	OnlineOnly bool
+	// Dropbox is the directory under PublicDir others may create files in.
+	Dropbox string
+	// Groups names sets of logins that .42fs-acl files can share with.
+	Groups map[string][]string
+	// Symlinks is how symlinks in PublicDir are shown: "hide", "resolve"
+	// (only those leading inside it) or "serve" (as they are). A
+	// .42fs-symlinks file in PublicDir overrides it.
+	Symlinks fscore.SymlinkPolicy
+
+	// How long metadata of other users' files is cached.
+	AttrTTL     Duration
+	NegativeTTL Duration
+	DirTTL      Duration
+	// MiB of other users' file contents kept in memory, and KiB fetched
+	// ahead of sequential reads.
+	BlockCacheMB int64
+	ReadaheadKB  int64
+	// DiskCacheDir keeps other users' files between runs if set, up to
+	// DiskCacheMB MiB.
+	DiskCacheDir string
+	DiskCacheMB  int64
+}
+
+// Duration is a time.Duration written as a string like "5s" in the file.
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return fmt.Errorf("durations are strings like \"5s\", got %s", b)
+	}
+	d.Duration, err = time.ParseDuration(s)
+	return err
+}
+
+// Dir returns the directory 42fs keeps its settings and keys in.
+func Dir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".42fs"), nil
+}
+
+// DefaultPath returns where the config file is looked for when none is
+// named.
+func DefaultPath() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.json"), nil
+}
+
+// Default returns the settings for the current user before any are chosen.
+// The coordinator has to be set by the caller.
+func Default() (*Config, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	u, err := user.Current()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(home, ".42fs")
+	return &Config{
+		Login:     u.Username,
+		PublicDir: filepath.Join(home, "public"),
+		MountDir:  filepath.Join(home, "42fs"),
+		LoginKey:  filepath.Join(dir, "login_key.pem"),
+		Listen:    ":4243",
+
+		AttrTTL:      Duration{fscore.DefaultCacheConfig.AttrTTL},
+		NegativeTTL:  Duration{fscore.DefaultCacheConfig.NegativeTTL},
+		DirTTL:       Duration{fscore.DefaultCacheConfig.DirTTL},
+		BlockCacheMB: fscore.DefaultCacheConfig.BlockCacheSize >> 20,
+		ReadaheadKB:  fscore.DefaultCacheConfig.Readahead >> 10,
+		DiskCacheMB:  fscore.DefaultCacheConfig.DiskCacheSize >> 20,
+	}, nil
+}
+
+// Load reads the config file at path. Settings missing from it keep their
+// Default values.
+func Load(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Default()
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, c)
+	if err != nil {
+		return nil, &os.PathError{Op: "parse", Path: path, Err: err}
+	}
+	return c, nil
+}
+
+// Save writes c to path, replacing it atomically. The parent directory is
+// created if needed.
+func (c *Config) Save(path string) error {
+	b, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(append(b, '\n'))
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err2 := tmp.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// setting is a Config field that can also be set from the environment and
+// the command line. The environment variable is EnvPrefix followed by the
+// flag name in upper case, with dashes turned into underscores.
+type setting struct {
+	flag   string
+	field  string // in the config file, for error messages
+	usage  string
+	isBool bool
+	set    func(c *Config, v string) error
+}
+
+const EnvPrefix = "FS42_"
+
+func envName(flag string) string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(flag, "-", "_", -1))
+}
+
+func setString(p func(c *Config) *string) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		*p(c) = v
+		return nil
+	}
+}
+
+// setPath expands environment variables in a path and makes it absolute.
+func setPath(p func(c *Config) *string) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		v, err := filepath.Abs(os.ExpandEnv(v))
+		if err != nil {
+			return err
+		}
+		*p(c) = v
+		return nil
+	}
+}
+
+func setBool(p func(c *Config) *bool) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("expected true or false, got %q", v)
+		}
+		*p(c) = b
+		return nil
+	}
+}
+
+func setInt(p func(c *Config) *int64) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("expected a whole number, got %q", v)
+		}
+		*p(c) = n
+		return nil
+	}
+}
+
+func setDuration(p func(c *Config) *Duration) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("expected a duration like 5s or 1m, got %q", v)
+		}
+		p(c).Duration = d
+		return nil
+	}
+}
+
+var settings = []setting{
+	{flag: "login", field: "Login", usage: "42 login to register as (default: the current user)",
+		set: setString(func(c *Config) *string { return &c.Login })},
+	{flag: "public", field: "PublicDir", usage: "directory to share with other users",
+		set: setPath(func(c *Config) *string { return &c.PublicDir })},
+	{flag: "mount", field: "MountDir", usage: "where to mount the filesystem",
+		set: setPath(func(c *Config) *string { return &c.MountDir })},
+	{flag: "coordinator", field: "Coordinator", usage: "host:port of the coordination server (empty: run alone)",
+		set: setString(func(c *Config) *string { return &c.Coordinator })},
+	{flag: "coordinator-ca", field: "CoordinatorCA", usage: "CA certificate of the coordinator (pinned for all TLS traffic)",
+		set: setPath(func(c *Config) *string { return &c.CoordinatorCA })},
+	{flag: "insecure", field: "Insecure", usage: "talk to the coordinator and peers without TLS", isBool: true,
+		set: setBool(func(c *Config) *bool { return &c.Insecure })},
+	{flag: "login-key", field: "LoginKey", usage: "key identifying you to the coordinator, created if missing",
+		set: setPath(func(c *Config) *string { return &c.LoginKey })},
+	{flag: "listen", field: "Listen", usage: "address to serve files to other daemons on",
+		set: setString(func(c *Config) *string { return &c.Listen })},
+	{flag: "online-only", field: "OnlineOnly", usage: "only list users whose computer is on", isBool: true,
+		set: setBool(func(c *Config) *bool { return &c.OnlineOnly })},
+	{flag: "dropbox", field: "Dropbox", usage: "directory in your public folder other users may create files in",
+		set: setString(func(c *Config) *string { return &c.Dropbox })},
+	{flag: "symlinks", field: "Symlinks", usage: "how symlinks in your public folder are shown: hide, resolve (only those leading inside it) or serve (as they are)",
+		set: func(c *Config, v string) error { return c.Symlinks.UnmarshalText([]byte(v)) }},
+	{flag: "attr-ttl", field: "AttrTTL", usage: "how long to cache attributes of other users' files (0 to disable)",
+		set: setDuration(func(c *Config) *Duration { return &c.AttrTTL })},
+	{flag: "negative-ttl", field: "NegativeTTL", usage: "how long to remember that a file doesn't exist (0 to disable)",
+		set: setDuration(func(c *Config) *Duration { return &c.NegativeTTL })},
+	{flag: "dir-ttl", field: "DirTTL", usage: "how long to cache directory listings (0 to disable)",
+		set: setDuration(func(c *Config) *Duration { return &c.DirTTL })},
+	{flag: "block-cache-mb", field: "BlockCacheMB", usage: "MiB of other users' file contents to keep in memory (0 to disable)",
+		set: setInt(func(c *Config) *int64 { return &c.BlockCacheMB })},
+	{flag: "readahead-kb", field: "ReadaheadKB", usage: "KiB to fetch ahead of sequential reads",
+		set: setInt(func(c *Config) *int64 { return &c.ReadaheadKB })},
+	{flag: "disk-cache", field: "DiskCacheDir", usage: "directory to keep other users' files in between runs, such as $XDG_CACHE_HOME/42fs",
+		set: setPath(func(c *Config) *string { return &c.DiskCacheDir })},
+	{flag: "disk-cache-mb", field: "DiskCacheMB", usage: "MiB the disk cache may use",
+		set: setInt(func(c *Config) *int64 { return &c.DiskCacheMB })},
+}
+
+// ApplyEnv sets the settings whose environment variable is not empty.
+func (c *Config) ApplyEnv() error {
+	for _, s := range settings {
+		v := os.Getenv(envName(s.flag))
+		if v == "" {
+			continue
+		}
+		err := s.set(c, v)
+		if err != nil {
+			return fmt.Errorf("%s: %v", envName(s.flag), err)
+		}
+	}
+	return nil
+}
+
+// flagValue remembers a flag until Flags.Apply, so flags can be parsed
+// before the config file they override is read.
+type flagValue struct {
+	s     *setting
+	value string
+	set   bool
+}
+
+func (f *flagValue) String() string {
+	return f.value
+}
+
+func (f *flagValue) Set(v string) error {
+	// report bad values while parsing, with the flag's usage
+	err := f.s.set(new(Config), v)
+	if err != nil {
+		return err
+	}
+	f.value = v
+	f.set = true
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return f.s.isBool
+}
+
+// Flags are the command line versions of the settings.
+type Flags struct {
+	values []*flagValue
+}
+
+// RegisterFlags adds a flag for every setting to fs.
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := new(Flags)
+	for i := range settings {
+		s := &settings[i]
+		v := &flagValue{s: s}
+		fs.Var(v, s.flag, fmt.Sprintf("%s (config %s, env %s)", s.usage, s.field, envName(s.flag)))
+		f.values = append(f.values, v)
+	}
+	return f
+}
+
+// Apply sets the settings that were given on the command line.
+func (f *Flags) Apply(c *Config) error {
+	for _, v := range f.values {
+		if !v.set {
+			continue
+		}
+		err := v.s.set(c, v.value)
+		if err != nil {
+			return fmt.Errorf("-%s: %v", v.s.flag, err)
+		}
+	}
+	return nil
+}
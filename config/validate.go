@@ -0,0 +1,146 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/riking/42fs/auth"
+	"github.com/riking/42fs/fscore"
+)
+
+// fieldHint names the ways of setting a field, for error messages.
+func fieldHint(field string) string {
+	for _, s := range settings {
+		if s.field == field {
+			return fmt.Sprintf("%s (-%s, %s)", field, s.flag, envName(s.flag))
+		}
+	}
+	return field
+}
+
+// ValidationError lists everything wrong with a Config.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid settings:\n\t" + strings.Join(e.Problems, "\n\t")
+}
+
+// Validate checks that the settings can be used to start the daemon,
+// reporting every problem at once.
+func (c *Config) Validate() error {
+	var problems []string
+	bad := func(field string, format string, args ...interface{}) {
+		problems = append(problems, fieldHint(field)+": "+fmt.Sprintf(format, args...))
+	}
+	checkDir := func(field, dir string) {
+		if dir == "" {
+			bad(field, "not set")
+			return
+		}
+		if !filepath.IsAbs(dir) {
+			bad(field, "%q is not an absolute path", dir)
+			return
+		}
+		fi, err := os.Stat(dir)
+		if os.IsNotExist(err) {
+			bad(field, "%s does not exist; create it or run 42fs setup", dir)
+		} else if err != nil {
+			bad(field, "%v", err)
+		} else if !fi.IsDir() {
+			bad(field, "%s is not a directory", dir)
+		}
+	}
+
+	if c.Login == "" || strings.ContainsAny(c.Login, "/\x00") {
+		bad("Login", "%q is not a login", c.Login)
+	}
+	checkDir("PublicDir", c.PublicDir)
+	checkDir("MountDir", c.MountDir)
+	if c.PublicDir != "" && c.MountDir != "" {
+		pub, mnt := filepath.Clean(c.PublicDir), filepath.Clean(c.MountDir)
+		if pub == mnt || strings.HasPrefix(mnt, pub+"/") || strings.HasPrefix(pub, mnt+"/") {
+			bad("MountDir", "%s must not be inside PublicDir %s or contain it", mnt, pub)
+		}
+	}
+
+	if c.Coordinator != "" {
+		if _, _, err := net.SplitHostPort(c.Coordinator); err != nil {
+			bad("Coordinator", "%q is not host:port", c.Coordinator)
+		}
+		if c.LoginKey == "" {
+			bad("LoginKey", "not set")
+		}
+		if !c.Insecure {
+			if c.CoordinatorCA == "" {
+				bad("CoordinatorCA", "required to talk to the coordinator over TLS; use Insecure to turn TLS off for testing")
+			} else if _, err := auth.LoadCertPool(c.CoordinatorCA); err != nil {
+				bad("CoordinatorCA", "%v", err)
+			}
+		}
+	}
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		bad("Listen", "%q is not host:port or :port", c.Listen)
+	}
+
+	if c.Dropbox != "" {
+		clean := path.Clean(c.Dropbox)
+		if path.IsAbs(c.Dropbox) || clean == ".." || strings.HasPrefix(clean, "../") {
+			bad("Dropbox", "%q must be a path relative to PublicDir and inside it", c.Dropbox)
+		} else if clean == "." {
+			bad("Dropbox", "%q would let others write anywhere in PublicDir; name a directory inside it", c.Dropbox)
+		} else if c.PublicDir != "" {
+			checkDir("Dropbox", filepath.Join(c.PublicDir, c.Dropbox))
+		}
+	}
+	for group, members := range c.Groups {
+		if group == "" || len(members) == 0 {
+			problems = append(problems, fmt.Sprintf("Groups: group %q needs a name and at least one login", group))
+		}
+	}
+
+	for _, d := range []struct {
+		field string
+		v     Duration
+	}{{"AttrTTL", c.AttrTTL}, {"NegativeTTL", c.NegativeTTL}, {"DirTTL", c.DirTTL}} {
+		if d.v.Duration < 0 {
+			bad(d.field, "%v is negative", d.v)
+		}
+	}
+	for _, n := range []struct {
+		field string
+		v     int64
+	}{{"BlockCacheMB", c.BlockCacheMB}, {"ReadaheadKB", c.ReadaheadKB}, {"DiskCacheMB", c.DiskCacheMB}} {
+		if n.v < 0 {
+			bad(n.field, "%d is negative", n.v)
+		}
+	}
+	if c.DiskCacheDir != "" && !filepath.IsAbs(c.DiskCacheDir) {
+		bad("DiskCacheDir", "%q is not an absolute path", c.DiskCacheDir)
+	}
+
+	if problems != nil {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// Cache returns the cache settings in the form FS42 takes them.
+func (c *Config) Cache() fscore.CacheConfig {
+	return fscore.CacheConfig{
+		AttrTTL:     c.AttrTTL.Duration,
+		NegativeTTL: c.NegativeTTL.Duration,
+		DirTTL:      c.DirTTL.Duration,
+
+		BlockCacheSize: c.BlockCacheMB << 20,
+		Readahead:      c.ReadaheadKB << 10,
+
+		DiskCacheDir:  c.DiskCacheDir,
+		DiskCacheSize: c.DiskCacheMB << 20,
+	}
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig returns settings that pass Validate, in directories removed
+// when the test ends.
+func validConfig(t *testing.T) *Config {
+	dir, err := ioutil.TempDir("", "42fs-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	for _, d := range []string{"public", "public/box", "mnt"} {
+		if err := os.Mkdir(filepath.Join(dir, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return &Config{
+		Login:       "alice",
+		PublicDir:   filepath.Join(dir, "public"),
+		MountDir:    filepath.Join(dir, "mnt"),
+		Coordinator: "coord.example:4242",
+		Insecure:    true,
+		LoginKey:    filepath.Join(dir, "login_key.pem"),
+		Listen:      ":4243",
+		Dropbox:     "box",
+		AttrTTL:     Duration{time.Second},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := validConfig(t).Validate(); err != nil {
+		t.Fatalf("valid settings rejected: %v", err)
+	}
+
+	// dir is the parent of PublicDir and MountDir
+	cases := []struct {
+		name   string
+		change func(c *Config, dir string)
+		// want is the problem reported, with the directory replaced by $D
+		want string
+	}{
+		{"no login", func(c *Config, dir string) { c.Login = "" },
+			`Login (-login, FS42_LOGIN): "" is not a login`},
+		{"login with slash", func(c *Config, dir string) { c.Login = "a/b" },
+			`Login (-login, FS42_LOGIN): "a/b" is not a login`},
+		{"no public dir", func(c *Config, dir string) { c.PublicDir = ""; c.Dropbox = "" },
+			`PublicDir (-public, FS42_PUBLIC): not set`},
+		{"relative public dir", func(c *Config, dir string) { c.PublicDir = "public"; c.Dropbox = "" },
+			`PublicDir (-public, FS42_PUBLIC): "public" is not an absolute path`},
+		{"missing mount dir", func(c *Config, dir string) { c.MountDir = dir + "/nope" },
+			`MountDir (-mount, FS42_MOUNT): $D/nope does not exist; create it or run 42fs setup`},
+		{"mount dir is a file", func(c *Config, dir string) { c.MountDir = dir + "/file" },
+			`MountDir (-mount, FS42_MOUNT): $D/file is not a directory`},
+		{"mount inside public", func(c *Config, dir string) { c.MountDir = dir + "/public/box" },
+			`MountDir (-mount, FS42_MOUNT): $D/public/box must not be inside PublicDir $D/public or contain it`},
+		{"public inside mount", func(c *Config, dir string) { c.MountDir = dir },
+			`MountDir (-mount, FS42_MOUNT): $D must not be inside PublicDir $D/public or contain it`},
+		{"coordinator without port", func(c *Config, dir string) { c.Coordinator = "coord.example" },
+			`Coordinator (-coordinator, FS42_COORDINATOR): "coord.example" is not host:port`},
+		{"no login key", func(c *Config, dir string) { c.LoginKey = "" },
+			`LoginKey (-login-key, FS42_LOGIN_KEY): not set`},
+		{"TLS without CA", func(c *Config, dir string) { c.Insecure = false },
+			`CoordinatorCA (-coordinator-ca, FS42_COORDINATOR_CA): required to talk to the coordinator over TLS; use Insecure to turn TLS off for testing`},
+		{"CA without certificates", func(c *Config, dir string) { c.Insecure = false; c.CoordinatorCA = dir + "/file" },
+			`CoordinatorCA (-coordinator-ca, FS42_COORDINATOR_CA): 42fs: no certificates in $D/file`},
+		{"bad listen address", func(c *Config, dir string) { c.Listen = "4243" },
+			`Listen (-listen, FS42_LISTEN): "4243" is not host:port or :port`},
+		{"absolute dropbox", func(c *Config, dir string) { c.Dropbox = "/box" },
+			`Dropbox (-dropbox, FS42_DROPBOX): "/box" must be a path relative to PublicDir and inside it`},
+		{"dropbox outside", func(c *Config, dir string) { c.Dropbox = "box/../../mnt" },
+			`Dropbox (-dropbox, FS42_DROPBOX): "box/../../mnt" must be a path relative to PublicDir and inside it`},
+		{"dropbox is the public dir", func(c *Config, dir string) { c.Dropbox = "." },
+			`Dropbox (-dropbox, FS42_DROPBOX): "." would let others write anywhere in PublicDir; name a directory inside it`},
+		{"dropbox is the public dir, spelled out", func(c *Config, dir string) { c.Dropbox = "box/.." },
+			`Dropbox (-dropbox, FS42_DROPBOX): "box/.." would let others write anywhere in PublicDir; name a directory inside it`},
+		{"missing dropbox", func(c *Config, dir string) { c.Dropbox = "nope" },
+			`Dropbox (-dropbox, FS42_DROPBOX): $D/public/nope does not exist; create it or run 42fs setup`},
+		{"unnamed group", func(c *Config, dir string) { c.Groups = map[string][]string{"": {"bob"}} },
+			`Groups: group "" needs a name and at least one login`},
+		{"empty group", func(c *Config, dir string) { c.Groups = map[string][]string{"staff": nil} },
+			`Groups: group "staff" needs a name and at least one login`},
+		{"negative TTL", func(c *Config, dir string) { c.DirTTL = Duration{-time.Second} },
+			`DirTTL (-dir-ttl, FS42_DIR_TTL): -1s is negative`},
+		{"negative cache size", func(c *Config, dir string) { c.DiskCacheMB = -1 },
+			`DiskCacheMB (-disk-cache-mb, FS42_DISK_CACHE_MB): -1 is negative`},
+		{"relative disk cache", func(c *Config, dir string) { c.DiskCacheDir = "cache" },
+			`DiskCacheDir (-disk-cache, FS42_DISK_CACHE): "cache" is not an absolute path`},
+	}
+	for _, tc := range cases {
+		c := validConfig(t)
+		dir := filepath.Dir(c.PublicDir)
+		tc.change(c, dir)
+		err := c.Validate()
+		verr, ok := err.(*ValidationError)
+		if !ok {
+			t.Errorf("%s: Validate returned %v", tc.name, err)
+			continue
+		}
+		want := strings.Replace(tc.want, "$D", dir, -1)
+		if len(verr.Problems) != 1 || verr.Problems[0] != want {
+			t.Errorf("%s: Validate reported %q, want %q", tc.name, verr.Problems, want)
+		}
+	}
+}
+
+func TestValidateReportsEverything(t *testing.T) {
+	c := validConfig(t)
+	c.Login = ""
+	c.Listen = ""
+	c.BlockCacheMB = -1
+	err, ok := c.Validate().(*ValidationError)
+	if !ok || len(err.Problems) != 3 {
+		t.Errorf("Validate returned %v, want three problems", err)
+	}
+}
@@ -0,0 +1,201 @@
+package coordserver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"bazil.org/fuse"
+	fgrpc "github.com/riking/42fs/grpc"
+	"golang.org/x/sys/unix"
+)
+
+// BlobStore holds chunks of file contents named by their fgrpc.ChunkHash,
+// so a file that appears in many snapshots (say, the same project skeleton
+// in every student's folder) is only stored once.
+type BlobStore struct {
+	dir string
+
+	// used instead of dir when it is empty
+	lock sync.Mutex
+	mem  map[string]*memBlob
+}
+
+type memBlob struct {
+	data  []byte
+	added time.Time
+}
+
+// NewBlobStore creates a blob store in dir, or in memory if dir is empty.
+func NewBlobStore(dir string) (*BlobStore, error) {
+	bs := &BlobStore{dir: dir}
+	if dir == "" {
+		bs.mem = make(map[string]*memBlob)
+		return bs, nil
+	}
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	return bs, nil
+}
+
+func validHash(h string) bool {
+	if len(h) != 64 {
+		return false
+	}
+	for _, c := range h {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// path spreads the blobs over 256 subdirectories.
+func (bs *BlobStore) path(h string) string {
+	return filepath.Join(bs.dir, h[:2], h)
+}
+
+// Put stores b and returns its hash. Storing a blob that is already present
+// only marks it as recently used.
+func (bs *BlobStore) Put(b []byte) (string, error) {
+	h := fgrpc.ChunkHash(b)
+	if bs.dir == "" {
+		bs.lock.Lock()
+		defer bs.lock.Unlock()
+		mb, ok := bs.mem[h]
+		if !ok {
+			mb = &memBlob{data: append([]byte(nil), b...)}
+			bs.mem[h] = mb
+		}
+		mb.added = time.Now()
+		return h, nil
+	}
+	p := bs.path(h)
+	now := time.Now()
+	err := os.Chtimes(p, now, now)
+	if err == nil {
+		return h, nil
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+	err = os.MkdirAll(filepath.Dir(p), 0700)
+	if err != nil {
+		return "", err
+	}
+	return h, writeFileAtomic(p, b)
+}
+
+// Touch reports whether the blob named h is stored, and if so marks it as
+// recently used.
+func (bs *BlobStore) Touch(h string) bool {
+	if !validHash(h) {
+		return false
+	}
+	now := time.Now()
+	if bs.dir == "" {
+		bs.lock.Lock()
+		defer bs.lock.Unlock()
+		mb, ok := bs.mem[h]
+		if ok {
+			mb.added = now
+		}
+		return ok
+	}
+	return os.Chtimes(bs.path(h), now, now) == nil
+}
+
+// Get returns the blob named h.
+func (bs *BlobStore) Get(h string) ([]byte, error) {
+	if !validHash(h) {
+		return nil, fuse.ENOENT
+	}
+	if bs.dir == "" {
+		bs.lock.Lock()
+		defer bs.lock.Unlock()
+		mb, ok := bs.mem[h]
+		if !ok {
+			return nil, fuse.ENOENT
+		}
+		return mb.data, nil
+	}
+	b, err := ioutil.ReadFile(bs.path(h))
+	if err != nil {
+		return nil, err
+	}
+	if fgrpc.ChunkHash(b) != h {
+		// corrupted on disk
+		return nil, fuse.Errno(unix.EIO)
+	}
+	return b, nil
+}
+
+// Size returns the length of the blob named h.
+func (bs *BlobStore) Size(h string) (int64, error) {
+	if !validHash(h) {
+		return 0, fuse.ENOENT
+	}
+	if bs.dir == "" {
+		bs.lock.Lock()
+		defer bs.lock.Unlock()
+		mb, ok := bs.mem[h]
+		if !ok {
+			return 0, fuse.ENOENT
+		}
+		return int64(len(mb.data)), nil
+	}
+	fi, err := os.Stat(bs.path(h))
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// GC removes every blob that is not in live and was last stored more than
+// grace ago. The grace period protects blobs that were stored for a
+// manifest that hasn't been saved yet.
+func (bs *BlobStore) GC(live map[string]bool, grace time.Duration) (int, error) {
+	cutoff := time.Now().Add(-grace)
+	removed := 0
+	if bs.dir == "" {
+		bs.lock.Lock()
+		defer bs.lock.Unlock()
+		for h, mb := range bs.mem {
+			if !live[h] && mb.added.Before(cutoff) {
+				delete(bs.mem, h)
+				removed++
+			}
+		}
+		return removed, nil
+	}
+	subdirs, err := ioutil.ReadDir(bs.dir)
+	if err != nil {
+		return 0, err
+	}
+	for _, sub := range subdirs {
+		if !sub.IsDir() {
+			continue
+		}
+		files, err := ioutil.ReadDir(filepath.Join(bs.dir, sub.Name()))
+		if err != nil {
+			return removed, err
+		}
+		for _, fi := range files {
+			h := fi.Name()
+			if !fi.ModTime().Before(cutoff) || validHash(h) && live[h] {
+				continue
+			}
+			// an unreferenced blob, or a temporary file left behind by a
+			// crash in writeFileAtomic
+			err = os.Remove(filepath.Join(bs.dir, sub.Name(), h))
+			if err != nil && !os.IsNotExist(err) {
+				return removed, err
+			}
+			removed++
+		}
+	}
+	return removed, nil
+}
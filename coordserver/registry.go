@@ -0,0 +1,270 @@
+package coordserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	fgrpc "github.com/riking/42fs/grpc"
+)
+
+var (
+	ErrKeyMismatch   = errors.New("42fs: login registered with a different key")
+	ErrReplayedLogin = errors.New("42fs: registration signed no later than the last one")
+)
+
+// Inode numbers below this are reserved for the static entries of the
+// daemon's root directory.
+const firstUserINode = 16
+
+// DefaultOnlineTimeout is how long a registration counts as online without
+// being renewed.
+const DefaultOnlineTimeout = 2 * time.Minute
+
+type userRecord struct {
+	Login     string
+	INode     uint64
+	FirstSeen time.Time
+	LastSeen  time.Time
+	// PublicKey is the login key pinned at the first registration.
+	PublicKey []byte
+
+	// not persisted: only meaningful while the daemon is running
+	Addr      string `json:"-"`
+	RootINode uint64 `json:"-"`
+	// Signed is when the last registration accepted was signed. Only those
+	// signed within auth.MaxClockSkew before a restart could be replayed
+	// after it.
+	Signed time.Time `json:"-"`
+}
+
+// Registry remembers every login that has ever registered a public folder,
+// and where the ones that are currently online can be reached.
+type Registry struct {
+	OnlineTimeout time.Duration
+
+	lock      sync.Mutex
+	users     map[string]*userRecord
+	nextINode uint64
+	statePath string
+}
+
+// NewRegistry creates a registry. If statePath is not empty, previously
+// known logins are loaded from it and every new login is saved to it.
+func NewRegistry(statePath string) (*Registry, error) {
+	r := &Registry{
+		OnlineTimeout: DefaultOnlineTimeout,
+		users:         make(map[string]*userRecord),
+		nextINode:     firstUserINode,
+		statePath:     statePath,
+	}
+	if statePath == "" {
+		return r, nil
+	}
+	err := r.load()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return r, nil
+}
+
+type registryState struct {
+	Users []*userRecord
+}
+
+func (r *Registry) load() error {
+	b, err := ioutil.ReadFile(r.statePath)
+	if err != nil {
+		return err
+	}
+	var st registryState
+	err = json.Unmarshal(b, &st)
+	if err != nil {
+		return err
+	}
+	for _, u := range st.Users {
+		r.users[u.Login] = u
+		if u.INode >= r.nextINode {
+			r.nextINode = u.INode + 1
+		}
+	}
+	return nil
+}
+
+// save must be called with the lock held.
+func (r *Registry) save() error {
+	if r.statePath == "" {
+		return nil
+	}
+	var st registryState
+	for _, u := range r.users {
+		st.Users = append(st.Users, u)
+	}
+	b, err := json.MarshalIndent(&st, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(r.statePath, b)
+}
+
+// writeFileAtomic replaces the file at path with b, so that a crash leaves
+// either the old or the new contents behind.
+func writeFileAtomic(path string, b []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err2 := tmp.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// PublicKey returns the login key pinned for login, or nil if it has never
+// registered.
+func (r *Registry) PublicKey(login string) []byte {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	u, ok := r.users[login]
+	if !ok {
+		return nil
+	}
+	return u.PublicKey
+}
+
+// Register records that login is online at addr and returns the inode
+// number assigned to its directory. The caller must already have checked
+// that the registration was signed with pubKey at signed, which must be
+// later than that of the previous registration so that none can be
+// replayed. The first key seen for a login is pinned; to reset it, remove
+// the login from the state file.
+func (r *Registry) Register(login, addr string, rootINode uint64, pubKey []byte, signed time.Time) (uint64, error) {
+	now := time.Now()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	u, ok := r.users[login]
+	if ok && u.PublicKey != nil && !bytes.Equal(u.PublicKey, pubKey) {
+		return 0, ErrKeyMismatch
+	}
+	if ok && !signed.After(u.Signed) {
+		return 0, ErrReplayedLogin
+	}
+	changed := !ok || u.PublicKey == nil
+	if !ok {
+		u = &userRecord{
+			Login:     login,
+			INode:     r.nextINode,
+			FirstSeen: now,
+		}
+		r.nextINode++
+		r.users[login] = u
+	}
+	u.Addr = addr
+	u.RootINode = rootINode
+	u.PublicKey = pubKey
+	u.LastSeen = now
+	u.Signed = signed
+	if changed {
+		err := r.save()
+		if err != nil {
+			return 0, err
+		}
+	}
+	return u.INode, nil
+}
+
+// Unregister records that login is no longer reachable. It stays offline
+// until it registers again.
+func (r *Registry) Unregister(login string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	u, ok := r.users[login]
+	if ok {
+		u.Addr = ""
+	}
+}
+
+// online must be called with the lock held.
+func (r *Registry) online(u *userRecord) bool {
+	return u.Addr != "" && time.Since(u.LastSeen) < r.OnlineTimeout
+}
+
+// Info answers whether login has a public folder and whether it can be
+// reached right now.
+func (r *Registry) Info(login string) *fgrpc.LoginInfo {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	info := &fgrpc.LoginInfo{Login: login}
+	u, ok := r.users[login]
+	if !ok {
+		return info
+	}
+	info.Exists = true
+	info.INode = u.INode
+	if r.online(u) {
+		info.WasOnline = true
+		info.Addr = u.Addr
+	}
+	return info
+}
+
+// List returns every known login, sorted, or only the ones that are online
+// right now.
+func (r *Registry) List(onlineOnly bool) []fgrpc.LoginInfo {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	list := make([]fgrpc.LoginInfo, 0, len(r.users))
+	for _, u := range r.users {
+		online := r.online(u)
+		if onlineOnly && !online {
+			continue
+		}
+		info := fgrpc.LoginInfo{Login: u.Login, Exists: true, INode: u.INode}
+		if online {
+			info.WasOnline = true
+			info.Addr = u.Addr
+		}
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Login < list[j].Login })
+	return list
+}
+
+// Stat returns the attributes of the directory standing in for login in
+// every daemon's root, or nil if the login is unknown.
+func (r *Registry) Stat(login string) *fgrpc.FileAttr {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	u, ok := r.users[login]
+	if !ok {
+		return nil
+	}
+	return &fgrpc.FileAttr{
+		INode:     u.INode,
+		Mtime:     u.LastSeen,
+		Ctime:     u.LastSeen,
+		BirthTime: u.FirstSeen,
+		Nlink:     2,
+		Mode:      os.ModeDir | 0555,
+	}
+}
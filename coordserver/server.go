@@ -0,0 +1,190 @@
+package coordserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"syscall"
+
+	"bazil.org/fuse"
+	"github.com/riking/42fs/auth"
+	fgrpc "github.com/riking/42fs/grpc"
+)
+
+// Server exposes a Registry as the Coordinator gRPC service, and issues
+// tokens to the daemons that register with it. Snapshots pushed by daemons
+// are kept in snaps and served by a SnapshotServer.
+type Server struct {
+	reg    *Registry
+	snaps  *SnapshotStore
+	issuer *auth.Issuer
+	// ca is nil when running without TLS
+	ca *auth.CA
+	// lookupHost resolves the host daemons advertise
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+}
+
+var _ fgrpc.CoordinatorService = &Server{}
+
+func NewServer(reg *Registry, snaps *SnapshotStore, issuer *auth.Issuer, ca *auth.CA) *Server {
+	return &Server{reg: reg, snaps: snaps, issuer: issuer, ca: ca, lookupHost: net.DefaultResolver.LookupHost}
+}
+
+// checkAddrHost verifies that the host in addr, an address a daemon
+// advertises, is the one its call came from, so that a login can't be
+// pointed at somebody else's machine. The host may be a name that resolves
+// to it.
+func (s *Server) checkAddrHost(ctx context.Context, addr, from string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	fromIP := net.ParseIP(from)
+	if fromIP == nil {
+		return fmt.Errorf("unknown caller address %q", from)
+	}
+	addrs := []string{host}
+	if net.ParseIP(host) == nil {
+		addrs, err = s.lookupHost(ctx, host)
+		if err != nil {
+			return err
+		}
+	}
+	for _, a := range addrs {
+		if fromIP.Equal(net.ParseIP(a)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not %s", host, from)
+}
+
+func (s *Server) Register(ctx context.Context, req *fgrpc.RegisterRequest) (*fgrpc.RegisterResponse, error) {
+	if req.Login == "" || req.Addr == "" {
+		return nil, fuse.Errno(syscall.EINVAL)
+	}
+	pubKey := s.reg.PublicKey(req.Login)
+	if pubKey == nil {
+		pubKey = req.PublicKey
+	}
+	from := auth.PeerHost(ctx)
+	err := auth.VerifyLogin(pubKey, req.Login, req.Addr, req.RootINode, req.CSR, req.Time, req.Signature)
+	if err == nil {
+		err = s.checkAddrHost(ctx, req.Addr, from)
+	}
+	if err != nil {
+		log.Printf("rejected registration for %s from %s: %v", req.Login, from, err)
+		return nil, fuse.EPERM
+	}
+	inode, err := s.reg.Register(req.Login, req.Addr, req.RootINode, pubKey, req.Time)
+	if err == ErrReplayedLogin {
+		log.Printf("rejected registration for %s from %s: %v", req.Login, from, err)
+		return nil, fuse.EPERM
+	} else if err != nil {
+		return nil, err
+	}
+	token, _, err := s.issuer.Issue(req.Login, from)
+	if err != nil {
+		return nil, err
+	}
+	issuerKey, err := s.issuer.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	resp := &fgrpc.RegisterResponse{
+		INode:     inode,
+		Token:     token,
+		IssuerKey: issuerKey,
+	}
+	if len(req.CSR) != 0 {
+		if s.ca == nil {
+			return nil, fuse.Errno(syscall.EPROTONOSUPPORT)
+		}
+		resp.Certificate, err = s.ca.SignCSR(req.CSR, req.Login)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// Unregister marks the caller offline, for daemons that are shutting down.
+func (s *Server) Unregister(ctx context.Context, _ *fgrpc.Empty) (*fgrpc.Empty, error) {
+	id, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, fuse.Errno(syscall.EACCES)
+	}
+	s.reg.Unregister(id.Login)
+	log.Printf("%s went offline", id.Login)
+	return &fgrpc.Empty{}, nil
+}
+
+// markOffline points readers at the snapshot of a user who is not online.
+func (s *Server) markOffline(info *fgrpc.LoginInfo) {
+	if info.Exists && !info.WasOnline && s.snaps.Has(info.Login) {
+		info.Offline = true
+	}
+}
+
+func (s *Server) UserDirInfo(ctx context.Context, req *fgrpc.UserDirRequest) (*fgrpc.LoginInfo, error) {
+	info := s.reg.Info(req.Login)
+	s.markOffline(info)
+	return info, nil
+}
+
+func (s *Server) ListUsers(ctx context.Context, req *fgrpc.ListUsersRequest) (*fgrpc.UserList, error) {
+	users := s.reg.List(req.OnlineOnly)
+	for i := range users {
+		s.markOffline(&users[i])
+	}
+	return &fgrpc.UserList{Users: users}, nil
+}
+
+func (s *Server) UserDirStat(ctx context.Context, req *fgrpc.UserDirRequest) (*fgrpc.FileAttr, error) {
+	attr := s.reg.Stat(req.Login)
+	if attr == nil {
+		return nil, fuse.ENOENT
+	}
+	return attr, nil
+}
+
+// SyncManifest stores a snapshot manifest of the caller's own directory,
+// or reports which chunks must be uploaded first. Chunks that were uploaded
+// before an interrupted sync are kept for DefaultGCGrace, so a retry only
+// needs to send the rest.
+func (s *Server) SyncManifest(ctx context.Context, m *fgrpc.Manifest) (*fgrpc.SyncResponse, error) {
+	id, ok := auth.FromContext(ctx)
+	if !ok || id.Login != m.Login {
+		return nil, fuse.Errno(syscall.EACCES)
+	}
+	_, err := checkManifest(m)
+	if err != nil {
+		return nil, err
+	}
+	missing := s.snaps.Missing(id.Login, m)
+	if len(missing) != 0 {
+		return &fgrpc.SyncResponse{Missing: missing}, nil
+	}
+	err = s.snaps.PutManifest(m)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("stored snapshot of %s: %d entries", m.Login, len(m.Entries))
+	return &fgrpc.SyncResponse{}, nil
+}
+
+// PutChunks stores file contents for a manifest the caller is about to
+// sync.
+func (s *Server) PutChunks(ctx context.Context, chunks *fgrpc.ChunkList) (*fgrpc.Empty, error) {
+	id, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, fuse.Errno(syscall.EACCES)
+	}
+	for _, b := range chunks.Chunks {
+		err := s.snaps.PutChunk(id.Login, b)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &fgrpc.Empty{}, nil
+}
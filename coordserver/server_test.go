@@ -0,0 +1,200 @@
+package coordserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bazil.org/fuse"
+	"github.com/riking/42fs/auth"
+	fgrpc "github.com/riking/42fs/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// newTestServer returns a coordinator without TLS that keeps its state in a
+// temporary directory. The host names it resolves are those in hosts.
+func newTestServer(t *testing.T, hosts map[string][]string) (*Server, string) {
+	dir, err := ioutil.TempDir("", "42fs-coordserver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	s := newServerIn(t, dir)
+	s.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		addrs, ok := hosts[host]
+		if !ok {
+			return nil, errors.New("no such host")
+		}
+		return addrs, nil
+	}
+	return s, dir
+}
+
+func newServerIn(t *testing.T, dir string) *Server {
+	reg, err := NewRegistry(filepath.Join(dir, "registry.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	snaps, err := NewSnapshotStore(filepath.Join(dir, "snapshots"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewServer(reg, snaps, auth.NewIssuer(newKey(t)), nil)
+}
+
+// from returns the context of a call from host.
+func from(host string) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP(host), Port: 50000},
+	})
+}
+
+func registerRequest(t *testing.T, key *ecdsa.PrivateKey, login, addr string, at time.Time) *fgrpc.RegisterRequest {
+	pub, err := auth.MarshalPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := auth.SignLogin(key, login, addr, 7, nil, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fgrpc.RegisterRequest{
+		Login:     login,
+		Addr:      addr,
+		RootINode: 7,
+		PublicKey: pub,
+		Time:      at,
+		Signature: sig,
+	}
+}
+
+func TestRegisterIssuesToken(t *testing.T) {
+	s, _ := newTestServer(t, nil)
+	resp, err := s.Register(from("10.0.0.1"), registerRequest(t, newKey(t), "alice", "10.0.0.1:4243", time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := auth.NewVerifier(resp.IssuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := v.Verify(resp.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Login != "alice" || id.Host != "10.0.0.1" {
+		t.Errorf("token is for %s at %s", id.Login, id.Host)
+	}
+	info := s.reg.Info("alice")
+	if !info.WasOnline || info.Addr != "10.0.0.1:4243" || info.INode != resp.INode {
+		t.Errorf("registry has %+v", info)
+	}
+}
+
+func TestRegisterHostBinding(t *testing.T) {
+	s, _ := newTestServer(t, map[string][]string{
+		"e1r1p1.42.fr": {"10.0.0.1"},
+		"e1r1p2.42.fr": {"10.0.0.2"},
+	})
+	key := newKey(t)
+	for _, c := range []struct {
+		caller, addr string
+		ok           bool
+	}{
+		{"10.0.0.1", "10.0.0.1:4243", true},
+		{"10.0.0.1", "e1r1p1.42.fr:4243", true},
+		{"10.0.0.1", "10.0.0.2:4243", false},
+		{"10.0.0.1", "e1r1p2.42.fr:4243", false},
+		{"10.0.0.1", "unknown.42.fr:4243", false},
+		{"10.0.0.1", "10.0.0.1", false},
+	} {
+		_, err := s.Register(from(c.caller), registerRequest(t, key, "alice", c.addr, time.Now()))
+		if c.ok && err != nil {
+			t.Errorf("registering %s from %s: %v", c.addr, c.caller, err)
+		} else if !c.ok && err != fuse.EPERM {
+			t.Errorf("registering %s from %s: got %v, want EPERM", c.addr, c.caller, err)
+		}
+	}
+	if info := s.reg.Info("alice"); info.Addr != "e1r1p1.42.fr:4243" {
+		t.Errorf("alice is at %q", info.Addr)
+	}
+}
+
+func TestRegisterReplay(t *testing.T) {
+	s, _ := newTestServer(t, nil)
+	key := newKey(t)
+	now := time.Now()
+	req := registerRequest(t, key, "alice", "10.0.0.1:4243", now)
+	if _, err := s.Register(from("10.0.0.1"), req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Register(from("10.0.0.1"), req); err != fuse.EPERM {
+		t.Errorf("replayed registration: got %v, want EPERM", err)
+	}
+	older := registerRequest(t, key, "alice", "10.0.0.1:4243", now.Add(-time.Second))
+	if _, err := s.Register(from("10.0.0.1"), older); err != fuse.EPERM {
+		t.Errorf("registration signed before the last one: got %v, want EPERM", err)
+	}
+	newer := registerRequest(t, key, "alice", "10.0.0.1:4243", now.Add(time.Second))
+	if _, err := s.Register(from("10.0.0.1"), newer); err != nil {
+		t.Errorf("renewal: %v", err)
+	}
+}
+
+func TestRegisterPinsKey(t *testing.T) {
+	s, dir := newTestServer(t, nil)
+	alice := newKey(t)
+	mallory := newKey(t)
+	if _, err := s.Register(from("10.0.0.1"), registerRequest(t, alice, "alice", "10.0.0.1:4243", time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	// signed with mallory's key, which is sent along but not the one pinned
+	_, err := s.Register(from("10.0.0.9"), registerRequest(t, mallory, "alice", "10.0.0.9:4243", time.Now()))
+	if err != fuse.EPERM {
+		t.Errorf("registration with another key: got %v, want EPERM", err)
+	}
+
+	// the key stays pinned across restarts
+	s = newServerIn(t, dir)
+	_, err = s.Register(from("10.0.0.9"), registerRequest(t, mallory, "alice", "10.0.0.9:4243", time.Now()))
+	if err != fuse.EPERM {
+		t.Errorf("registration with another key after a restart: got %v, want EPERM", err)
+	}
+	if _, err := s.Register(from("10.0.0.1"), registerRequest(t, alice, "alice", "10.0.0.1:4243", time.Now())); err != nil {
+		t.Errorf("registration with the pinned key after a restart: %v", err)
+	}
+	if info := s.reg.Info("alice"); info.Addr != "10.0.0.1:4243" {
+		t.Errorf("alice is at %q", info.Addr)
+	}
+}
+
+func TestRegistryRejectsOtherKey(t *testing.T) {
+	reg, err := NewRegistry("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if _, err := reg.Register("alice", "10.0.0.1:4243", 7, []byte("key 1"), now); err != nil {
+		t.Fatal(err)
+	}
+	_, err = reg.Register("alice", "10.0.0.1:4243", 7, []byte("key 2"), now.Add(time.Second))
+	if err != ErrKeyMismatch {
+		t.Errorf("got %v, want %v", err, ErrKeyMismatch)
+	}
+}
@@ -0,0 +1,334 @@
+package coordserver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"bazil.org/fuse"
+	fgrpc "github.com/riking/42fs/grpc"
+	"golang.org/x/sys/unix"
+)
+
+// DefaultGCGrace is how long an unreferenced blob is kept before garbage
+// collection may remove it.
+const DefaultGCGrace = time.Hour
+
+// snapshotTree indexes one user's manifest by path.
+type snapshotTree struct {
+	m        *fgrpc.Manifest
+	entries  map[string]*fgrpc.ManifestEntry
+	children map[string][]*fgrpc.ManifestEntry
+	// chunks holds every chunk the manifest refers to
+	chunks map[string]bool
+}
+
+func newSnapshotTree(m *fgrpc.Manifest) (*snapshotTree, error) {
+	t := &snapshotTree{
+		m:        m,
+		entries:  make(map[string]*fgrpc.ManifestEntry),
+		children: make(map[string][]*fgrpc.ManifestEntry),
+		chunks:   make(map[string]bool),
+	}
+	for i := range m.Entries {
+		e := &m.Entries[i]
+		for _, h := range e.Chunks {
+			t.chunks[h] = true
+		}
+		if e.Path == "" || path.Clean(e.Path) != e.Path || e.Path[0] != '/' {
+			return nil, fuse.Errno(unix.EINVAL)
+		}
+		if _, dup := t.entries[e.Path]; dup {
+			return nil, fuse.Errno(unix.EINVAL)
+		}
+		t.entries[e.Path] = e
+	}
+	root, ok := t.entries["/"]
+	if !ok || !root.Attr.Mode.IsDir() {
+		return nil, fuse.Errno(unix.EINVAL)
+	}
+	for p, e := range t.entries {
+		if p == "/" {
+			continue
+		}
+		dir := path.Dir(p)
+		parent, ok := t.entries[dir]
+		if !ok || !parent.Attr.Mode.IsDir() {
+			return nil, fuse.Errno(unix.EINVAL)
+		}
+		t.children[dir] = append(t.children[dir], e)
+	}
+	return t, nil
+}
+
+// chunkCount is how many chunks a file of size bytes is split into.
+func chunkCount(size uint64) int {
+	return int((size + fgrpc.ChunkSize - 1) / fgrpc.ChunkSize)
+}
+
+// SnapshotStore keeps the latest snapshot pushed by each user. Snapshots
+// are kept as manifests, with the file contents in a BlobStore shared by
+// all users. Which chunks are stored is only revealed to users who uploaded
+// them, or whose snapshot refers to them: a chunk is proof of having the
+// file it is part of.
+type SnapshotStore struct {
+	blobs *BlobStore
+
+	lock  sync.Mutex
+	trees map[string]*snapshotTree
+	// uploads holds, for each login, the chunks it stored since its last
+	// manifest, and when
+	uploads map[string]map[string]time.Time
+	// manifests are persisted here, one file per login, unless it is empty
+	dir string
+}
+
+// NewSnapshotStore creates a snapshot store. If dir is not empty, manifests
+// and blobs are kept under it and loaded again on startup.
+func NewSnapshotStore(dir string) (*SnapshotStore, error) {
+	st := &SnapshotStore{
+		trees:   make(map[string]*snapshotTree),
+		uploads: make(map[string]map[string]time.Time),
+	}
+	blobDir := ""
+	if dir != "" {
+		st.dir = filepath.Join(dir, "manifests")
+		blobDir = filepath.Join(dir, "blobs")
+	}
+	var err error
+	st.blobs, err = NewBlobStore(blobDir)
+	if err != nil {
+		return nil, err
+	}
+	if st.dir == "" {
+		return st, nil
+	}
+	err = os.MkdirAll(st.dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	names, err := filepath.Glob(filepath.Join(st.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		var m fgrpc.Manifest
+		err = json.Unmarshal(b, &m)
+		if err != nil {
+			return nil, err
+		}
+		t, err := newSnapshotTree(&m)
+		if err != nil {
+			return nil, err
+		}
+		st.trees[m.Login] = t
+	}
+	return st, nil
+}
+
+// checkManifest validates the structure of m. Regular files must have one
+// well-formed chunk hash per ChunkSize of their size.
+func checkManifest(m *fgrpc.Manifest) (*snapshotTree, error) {
+	if m.Login == "" || strings.HasPrefix(m.Login, ".") || strings.Contains(m.Login, "/") {
+		return nil, fuse.Errno(unix.EINVAL)
+	}
+	for _, e := range m.Entries {
+		want := 0
+		if e.Attr.Mode.IsRegular() && !e.Omitted {
+			want = chunkCount(e.Attr.Size)
+		}
+		if len(e.Chunks) != want {
+			return nil, fuse.Errno(unix.EINVAL)
+		}
+		for _, h := range e.Chunks {
+			if !validHash(h) {
+				return nil, fuse.Errno(unix.EINVAL)
+			}
+		}
+	}
+	return newSnapshotTree(m)
+}
+
+// checkChunkSizes verifies that the stored chunks of every file in m add
+// up to its size: all but the last are ChunkSize long.
+func (st *SnapshotStore) checkChunkSizes(m *fgrpc.Manifest) error {
+	for _, e := range m.Entries {
+		left := e.Attr.Size
+		for _, h := range e.Chunks {
+			want := left
+			if want > fgrpc.ChunkSize {
+				want = fgrpc.ChunkSize
+			}
+			size, err := st.blobs.Size(h)
+			if err != nil {
+				return err
+			}
+			if uint64(size) != want {
+				return fuse.Errno(unix.EINVAL)
+			}
+			left -= want
+		}
+	}
+	return nil
+}
+
+// known reports whether login may learn that the chunk h is stored.
+func (st *SnapshotStore) known(login, h string) bool {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	if t := st.trees[login]; t != nil && t.chunks[h] {
+		return true
+	}
+	_, ok := st.uploads[login][h]
+	return ok
+}
+
+// Missing returns the chunks m refers to that login still has to upload:
+// those not in the blob store, and those that only other users stored. The
+// ones that are present are protected from garbage collection for another
+// grace period, so they are still there when m is stored.
+func (st *SnapshotStore) Missing(login string, m *fgrpc.Manifest) []string {
+	seen := make(map[string]bool)
+	var missing []string
+	for _, e := range m.Entries {
+		for _, h := range e.Chunks {
+			if seen[h] {
+				continue
+			}
+			seen[h] = true
+			if !st.known(login, h) || !st.blobs.Touch(h) {
+				missing = append(missing, h)
+			}
+		}
+	}
+	return missing
+}
+
+// PutChunk stores one chunk of file contents, uploaded by login for a
+// manifest that is yet to be stored.
+func (st *SnapshotStore) PutChunk(login string, b []byte) error {
+	if len(b) == 0 || len(b) > fgrpc.ChunkSize {
+		return fuse.Errno(unix.EINVAL)
+	}
+	h, err := st.blobs.Put(b)
+	if err != nil {
+		return err
+	}
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	if st.uploads[login] == nil {
+		st.uploads[login] = make(map[string]time.Time)
+	}
+	st.uploads[login][h] = time.Now()
+	return nil
+}
+
+// PutManifest replaces the manifest stored for m.Login. Every chunk it
+// refers to must already have been uploaded by that login, or be in its
+// previous manifest.
+func (st *SnapshotStore) PutManifest(m *fgrpc.Manifest) error {
+	t, err := checkManifest(m)
+	if err != nil {
+		return err
+	}
+	if len(st.Missing(m.Login, m)) != 0 {
+		return fuse.Errno(unix.EINVAL)
+	}
+	err = st.checkChunkSizes(m)
+	if err != nil {
+		return err
+	}
+	st.lock.Lock()
+	defer st.lock.Unlock()
+
+	if st.dir != "" {
+		b, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		err = writeFileAtomic(filepath.Join(st.dir, m.Login+".json"), b)
+		if err != nil {
+			return err
+		}
+	}
+	st.trees[m.Login] = t
+	delete(st.uploads, m.Login)
+	return nil
+}
+
+// Has reports whether a snapshot of login's directory is available.
+func (st *SnapshotStore) Has(login string) bool {
+	return st.tree(login) != nil
+}
+
+func (st *SnapshotStore) tree(login string) *snapshotTree {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	return st.trees[login]
+}
+
+// readAt reads from e, a regular file in one of the stored manifests.
+func (st *SnapshotStore) readAt(e *fgrpc.ManifestEntry, off int64, size int) ([]byte, error) {
+	if off < 0 || size < 0 {
+		return nil, fuse.Errno(unix.EINVAL)
+	}
+	var data []byte
+	for len(data) < size {
+		i := off / fgrpc.ChunkSize
+		if i >= int64(len(e.Chunks)) {
+			break
+		}
+		chunk, err := st.blobs.Get(e.Chunks[i])
+		if err != nil {
+			return nil, err
+		}
+		if off-i*fgrpc.ChunkSize >= int64(len(chunk)) {
+			break
+		}
+		chunk = chunk[off-i*fgrpc.ChunkSize:]
+		if len(chunk) > size-len(data) {
+			chunk = chunk[:size-len(data)]
+		}
+		data = append(data, chunk...)
+		off += int64(len(chunk))
+	}
+	return data, nil
+}
+
+// CollectGarbage removes the blobs that no stored manifest refers to any
+// more and that were last stored more than grace ago. It returns how many
+// were removed.
+func (st *SnapshotStore) CollectGarbage(grace time.Duration) (int, error) {
+	live := make(map[string]bool)
+	cutoff := time.Now().Add(-grace)
+	st.lock.Lock()
+	for _, t := range st.trees {
+		for h := range t.chunks {
+			live[h] = true
+		}
+	}
+	// uploads for a manifest that never came may be collected like any
+	// other blob
+	for login, hashes := range st.uploads {
+		for h, at := range hashes {
+			if at.Before(cutoff) {
+				delete(hashes, h)
+			}
+		}
+		if len(hashes) == 0 {
+			delete(st.uploads, login)
+		}
+	}
+	st.lock.Unlock()
+
+	return st.blobs.GC(live, grace)
+}
@@ -0,0 +1,342 @@
+package coordserver
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"bazil.org/fuse"
+	"github.com/riking/42fs/auth"
+	fgrpc "github.com/riking/42fs/grpc"
+	"golang.org/x/sys/unix"
+)
+
+// SnapshotServer serves every stored snapshot as a single read-only
+// UserConnection, with one top-level directory per login (see
+// fgrpc.NewSnapshotConnectionClient). Callers are held to the same "other"
+// permission bits the owner's daemon would check.
+type SnapshotServer struct {
+	store *SnapshotStore
+
+	lock   sync.Mutex
+	nextFD uint64
+	files  map[uint64]*snapshotFile
+	// swept is when idle files were last dropped
+	swept time.Time
+}
+
+type snapshotFile struct {
+	tree  *snapshotTree
+	e     *fgrpc.ManifestEntry
+	owner string
+	// used is when the file was last read
+	used time.Time
+}
+
+// Handles keep the snapshot they were opened in alive, and readers can go
+// away without closing them, so the ones unused for snapshotFileIdle are
+// dropped, and no login may have more than maxSnapshotFiles open at once.
+const (
+	snapshotFileIdle = time.Hour
+	maxSnapshotFiles = 256
+)
+
+var _ fgrpc.UserConnection = &SnapshotServer{}
+
+func NewSnapshotServer(store *SnapshotStore) *SnapshotServer {
+	return &SnapshotServer{
+		store:  store,
+		nextFD: 1,
+		files:  make(map[uint64]*snapshotFile),
+	}
+}
+
+const (
+	otherRead   = 04
+	otherWrite  = 02
+	otherSearch = 01
+)
+
+func otherBits(mask uint32) os.FileMode {
+	var bits os.FileMode
+	if mask&unix.R_OK != 0 {
+		bits |= otherRead
+	}
+	if mask&unix.W_OK != 0 {
+		bits |= otherWrite
+	}
+	if mask&unix.X_OK != 0 {
+		bits |= otherSearch
+	}
+	return bits
+}
+
+func caller(ctx context.Context) (string, error) {
+	id, ok := auth.FromContext(ctx)
+	if !ok {
+		return "", fuse.Errno(unix.EACCES)
+	}
+	return id.Login, nil
+}
+
+// lookup resolves a "/login/..." path to an entry, checking that every
+// directory on the way is searchable by others.
+func (ss *SnapshotServer) lookup(ctx context.Context, p string) (*snapshotTree, *fgrpc.ManifestEntry, error) {
+	_, err := caller(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	p = path.Clean("/" + p)
+	parts := strings.SplitN(p[1:], "/", 2)
+	t := ss.store.tree(parts[0])
+	if t == nil {
+		return nil, nil, fuse.ENOENT
+	}
+	rel := "/"
+	if len(parts) == 2 {
+		rel += parts[1]
+	}
+	e, ok := t.entries[rel]
+	if !ok {
+		return nil, nil, fuse.ENOENT
+	}
+	for dir := rel; dir != "/"; {
+		dir = path.Dir(dir)
+		if t.entries[dir].Attr.Mode&otherSearch == 0 {
+			return nil, nil, fuse.Errno(unix.EACCES)
+		}
+	}
+	return t, e, nil
+}
+
+// snapshotAttr is the attributes of e as served: nothing in a snapshot can
+// be written to.
+func snapshotAttr(e *fgrpc.ManifestEntry) *fgrpc.FileAttr {
+	attr := e.Attr
+	attr.Mode &^= 0222
+	return &attr
+}
+
+func (ss *SnapshotServer) Access(ctx context.Context, p string, mode uint32) error {
+	if mode&unix.W_OK != 0 {
+		return fuse.Errno(unix.EROFS)
+	}
+	_, e, err := ss.lookup(ctx, p)
+	if err != nil {
+		return err
+	}
+	want := otherBits(mode)
+	if e.Attr.Mode&want != want {
+		return fuse.Errno(unix.EACCES)
+	}
+	return nil
+}
+
+func (ss *SnapshotServer) Stat(ctx context.Context, p string) (*fgrpc.FileAttr, error) {
+	_, e, err := ss.lookup(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return snapshotAttr(e), nil
+}
+
+// Getxattr only knows fgrpc.SnapshotTimeXattr; the owner's own extended
+// attributes are not part of the snapshot.
+func (ss *SnapshotServer) Getxattr(ctx context.Context, p string, attr string, size uint32, position uint32) ([]byte, error) {
+	t, e, err := ss.lookup(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	if e.Attr.Mode&otherRead == 0 {
+		return nil, fuse.Errno(unix.EACCES)
+	}
+	if attr != fgrpc.SnapshotTimeXattr {
+		return nil, fuse.ErrNoXattr
+	}
+	return []byte(t.m.Taken.UTC().Format(time.RFC3339)), nil
+}
+
+func (ss *SnapshotServer) Listxattr(ctx context.Context, p string, size uint32, position uint32) ([]byte, error) {
+	_, e, err := ss.lookup(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	if e.Attr.Mode&otherRead == 0 {
+		return nil, fuse.Errno(unix.EACCES)
+	}
+	return []byte(fgrpc.SnapshotTimeXattr + "\x00"), nil
+}
+
+func (ss *SnapshotServer) Open(ctx context.Context, p string, dir bool, flags fgrpc.AgnosticOpenFlags) (fuse.OpenResponseFlags, uint64, error) {
+	sysFlags := flags.ToSys()
+	if sysFlags&unix.O_ACCMODE != unix.O_RDONLY || sysFlags&(unix.O_CREAT|unix.O_TRUNC) != 0 {
+		return 0, 0, fuse.Errno(unix.EROFS)
+	}
+	login, err := caller(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	t, e, err := ss.lookup(ctx, p)
+	if err != nil {
+		return 0, 0, err
+	}
+	if e.Attr.Mode&otherRead == 0 {
+		return 0, 0, fuse.Errno(unix.EACCES)
+	}
+	switch {
+	case e.Attr.Mode&os.ModeSymlink != 0:
+		return 0, 0, fuse.Errno(unix.ELOOP)
+	case dir && !e.Attr.Mode.IsDir():
+		return 0, 0, fuse.Errno(unix.ENOTDIR)
+	case !dir && e.Attr.Mode.IsDir():
+		return 0, 0, fuse.Errno(unix.EISDIR)
+	case e.Omitted:
+		return 0, 0, fuse.Errno(unix.EFBIG)
+	}
+
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	now := time.Now()
+	if now.Sub(ss.swept) >= time.Minute {
+		ss.swept = now
+		for fd, sf := range ss.files {
+			if now.Sub(sf.used) >= snapshotFileIdle {
+				delete(ss.files, fd)
+			}
+		}
+	}
+	n := 0
+	for _, sf := range ss.files {
+		if sf.owner == login {
+			n++
+		}
+	}
+	if n >= maxSnapshotFiles {
+		return 0, 0, fuse.Errno(unix.EMFILE)
+	}
+	fd := ss.nextFD
+	ss.nextFD++
+	ss.files[fd] = &snapshotFile{tree: t, e: e, owner: login, used: now}
+	// the snapshot never changes under an open handle
+	return fuse.OpenKeepCache, fd, nil
+}
+
+func (ss *SnapshotServer) Readlink(ctx context.Context, p string) (string, error) {
+	_, e, err := ss.lookup(ctx, p)
+	if err != nil {
+		return "", err
+	}
+	if e.Attr.Mode&os.ModeSymlink == 0 {
+		return "", fuse.Errno(unix.EINVAL)
+	}
+	return e.Target, nil
+}
+
+func (ss *SnapshotServer) LookupExists(ctx context.Context, p string) error {
+	_, _, err := ss.lookup(ctx, p)
+	return err
+}
+
+func (ss *SnapshotServer) file(ctx context.Context, fd uint64) (*snapshotFile, error) {
+	login, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+
+	sf, ok := ss.files[fd]
+	if !ok || sf.owner != login {
+		return nil, fuse.Errno(unix.EBADF)
+	}
+	sf.used = time.Now()
+	return sf, nil
+}
+
+func direntType(mode os.FileMode) fuse.DirentType {
+	switch {
+	case mode.IsDir():
+		return fuse.DT_Dir
+	case mode&os.ModeSymlink != 0:
+		return fuse.DT_Link
+	case mode.IsRegular():
+		return fuse.DT_File
+	}
+	return fuse.DT_Unknown
+}
+
+func (ss *SnapshotServer) ReadDir(ctx context.Context, fd uint64) ([]fgrpc.Dirent, error) {
+	sf, err := ss.file(ctx, fd)
+	if err != nil {
+		return nil, err
+	}
+	if !sf.e.Attr.Mode.IsDir() {
+		return nil, fuse.Errno(unix.ENOTDIR)
+	}
+	children := sf.tree.children[sf.e.Path]
+	ents := make([]fgrpc.Dirent, len(children))
+	for i, c := range children {
+		ents[i].Inode = c.Attr.INode
+		ents[i].Dev = c.Attr.Dev
+		ents[i].Type = uint32(direntType(c.Attr.Mode))
+		ents[i].Name = path.Base(c.Path)
+	}
+	return ents, nil
+}
+
+func (ss *SnapshotServer) ReadFrom(ctx context.Context, req *fgrpc.ReadRequest) ([]byte, error) {
+	sf, err := ss.file(ctx, req.FD)
+	if err != nil {
+		return nil, err
+	}
+	if req.Dir || sf.e.Attr.Mode.IsDir() {
+		return nil, fuse.Errno(unix.EISDIR)
+	}
+	return ss.store.readAt(sf.e, req.Offset, req.Size)
+}
+
+func (ss *SnapshotServer) Close(ctx context.Context, fd uint64) error {
+	_, err := ss.file(ctx, fd)
+	if err != nil {
+		return err
+	}
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	delete(ss.files, fd)
+	return nil
+}
+
+// Subscribe is not supported: a snapshot only changes when its owner is
+// online, and then readers talk to the owner's daemon instead.
+func (ss *SnapshotServer) Subscribe(ctx context.Context, events chan<- fgrpc.ChangeEvent) error {
+	return fuse.Errno(unix.ENOSYS)
+}
+
+// Snapshots are read-only.
+
+func (ss *SnapshotServer) Create(ctx context.Context, p string, mode os.FileMode, flags fgrpc.AgnosticOpenFlags) (fuse.OpenResponseFlags, uint64, error) {
+	return 0, 0, fuse.Errno(unix.EROFS)
+}
+
+func (ss *SnapshotServer) Mkdir(ctx context.Context, p string, mode os.FileMode) error {
+	return fuse.Errno(unix.EROFS)
+}
+
+func (ss *SnapshotServer) Remove(ctx context.Context, p string, dir bool) error {
+	return fuse.Errno(unix.EROFS)
+}
+
+func (ss *SnapshotServer) Rename(ctx context.Context, oldPath, newPath string) error {
+	return fuse.Errno(unix.EROFS)
+}
+
+func (ss *SnapshotServer) Setattr(ctx context.Context, req *fgrpc.SetattrRequest) (*fgrpc.FileAttr, error) {
+	return nil, fuse.Errno(unix.EROFS)
+}
+
+func (ss *SnapshotServer) WriteTo(ctx context.Context, req *fgrpc.WriteRequest) (int, error) {
+	return 0, fuse.Errno(unix.EROFS)
+}
@@ -0,0 +1,76 @@
+package coordserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bazil.org/fuse"
+	"github.com/riking/42fs/auth"
+	fgrpc "github.com/riking/42fs/grpc"
+	"golang.org/x/sys/unix"
+)
+
+func as(login string) context.Context {
+	return auth.NewContext(context.Background(), &auth.Identity{Login: login})
+}
+
+func newTestSnapshotServer(t *testing.T) *SnapshotServer {
+	st := newTestStore(t)
+	putChunks(t, st, "alice", []byte("x"))
+	if err := st.PutManifest(manifest("alice", fileEntry("/f", []byte("x")))); err != nil {
+		t.Fatal(err)
+	}
+	return NewSnapshotServer(st)
+}
+
+func TestSnapshotFileLimit(t *testing.T) {
+	ss := newTestSnapshotServer(t)
+	var fds []uint64
+	for i := 0; i < maxSnapshotFiles; i++ {
+		_, fd, err := ss.Open(as("bob"), "/alice/f", false, 0)
+		if err != nil {
+			t.Fatalf("open %d: %v", i, err)
+		}
+		fds = append(fds, fd)
+	}
+	if _, _, err := ss.Open(as("bob"), "/alice/f", false, 0); err != fuse.Errno(unix.EMFILE) {
+		t.Errorf("open past the limit: got %v, want EMFILE", err)
+	}
+	if _, _, err := ss.Open(as("carol"), "/alice/f", false, 0); err != nil {
+		t.Errorf("carol: %v", err)
+	}
+	if err := ss.Close(as("bob"), fds[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ss.Open(as("bob"), "/alice/f", false, 0); err != nil {
+		t.Errorf("open after closing one: %v", err)
+	}
+}
+
+func TestSnapshotFileIdle(t *testing.T) {
+	ss := newTestSnapshotServer(t)
+	_, idle, err := ss.Open(as("bob"), "/alice/f", false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, busy, err := ss.Open(as("bob"), "/alice/f", false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss.lock.Lock()
+	ss.files[idle].used = time.Now().Add(-snapshotFileIdle)
+	ss.files[busy].used = time.Now().Add(-snapshotFileIdle + time.Minute)
+	ss.swept = time.Time{}
+	ss.lock.Unlock()
+
+	if _, _, err := ss.Open(as("carol"), "/alice/f", false, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ss.ReadFrom(as("bob"), &fgrpc.ReadRequest{FD: idle, Size: 1}); err != fuse.Errno(unix.EBADF) {
+		t.Errorf("idle handle: got %v, want EBADF", err)
+	}
+	if b, err := ss.ReadFrom(as("bob"), &fgrpc.ReadRequest{FD: busy, Size: 1}); err != nil || string(b) != "x" {
+		t.Errorf("recently used handle: %q, %v", b, err)
+	}
+}
@@ -0,0 +1,335 @@
+package coordserver
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"bazil.org/fuse"
+	fgrpc "github.com/riking/42fs/grpc"
+	"golang.org/x/sys/unix"
+)
+
+var errInval = fuse.Errno(unix.EINVAL)
+
+func dirEntry(p string) fgrpc.ManifestEntry {
+	return fgrpc.ManifestEntry{Path: p, Attr: fgrpc.FileAttr{Mode: os.ModeDir | 0755}}
+}
+
+// fileEntry describes a file at p with the chunks of data.
+func fileEntry(p string, data []byte) fgrpc.ManifestEntry {
+	e := fgrpc.ManifestEntry{Path: p, Attr: fgrpc.FileAttr{Mode: 0644, Size: uint64(len(data))}}
+	for len(data) > 0 {
+		n := len(data)
+		if n > fgrpc.ChunkSize {
+			n = fgrpc.ChunkSize
+		}
+		e.Chunks = append(e.Chunks, fgrpc.ChunkHash(data[:n]))
+		data = data[n:]
+	}
+	return e
+}
+
+func manifest(login string, entries ...fgrpc.ManifestEntry) *fgrpc.Manifest {
+	return &fgrpc.Manifest{
+		Login:   login,
+		Taken:   time.Now(),
+		Entries: append([]fgrpc.ManifestEntry{dirEntry("/")}, entries...),
+	}
+}
+
+func TestCheckManifest(t *testing.T) {
+	big := bytes.Repeat([]byte("x"), fgrpc.ChunkSize+1)
+	if _, err := checkManifest(manifest("alice", dirEntry("/d"), fileEntry("/d/f", big), fileEntry("/empty", nil))); err != nil {
+		t.Fatalf("valid manifest: %v", err)
+	}
+
+	short := fileEntry("/f", big)
+	short.Chunks = short.Chunks[:1]
+	extra := fileEntry("/f", []byte("x"))
+	extra.Chunks = append(extra.Chunks, extra.Chunks[0])
+	upper := fileEntry("/f", []byte("x"))
+	upper.Chunks[0] = strings.ToUpper(upper.Chunks[0])
+	path := fileEntry("/f", []byte("x"))
+	path.Chunks[0] = "../../registry.json"
+	dirChunks := dirEntry("/d")
+	dirChunks.Chunks = fileEntry("", []byte("x")).Chunks
+	omitted := fileEntry("/f", []byte("x"))
+	omitted.Omitted = true
+	for name, m := range map[string]*fgrpc.Manifest{
+		"too few chunks":           manifest("alice", short),
+		"too many chunks":          manifest("alice", extra),
+		"upper case hash":          manifest("alice", upper),
+		"path as hash":             manifest("alice", path),
+		"directory with chunks":    manifest("alice", dirChunks),
+		"omitted file with chunks": manifest("alice", omitted),
+		"no root":                  {Login: "alice", Entries: []fgrpc.ManifestEntry{dirEntry("/d")}},
+		"relative path":            manifest("alice", fileEntry("f", nil)),
+		"unclean path":             manifest("alice", fileEntry("/d/../f", nil)),
+		"file as parent":           manifest("alice", fileEntry("/f", nil), fileEntry("/f/g", nil)),
+		"missing parent":           manifest("alice", fileEntry("/d/f", nil)),
+		"duplicate path":           manifest("alice", fileEntry("/f", nil), dirEntry("/f")),
+		"no login":                 manifest(""),
+		"login with slash":         manifest("../alice"),
+	} {
+		if _, err := checkManifest(m); err != errInval {
+			t.Errorf("%s: got %v, want EINVAL", name, err)
+		}
+	}
+}
+
+// newTestStore returns a snapshot store kept in a temporary directory.
+func newTestStore(t *testing.T) *SnapshotStore {
+	dir, err := ioutil.TempDir("", "42fs-snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	st, err := NewSnapshotStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+func putChunks(t *testing.T, st *SnapshotStore, login string, chunks ...[]byte) {
+	t.Helper()
+	for _, b := range chunks {
+		if err := st.PutChunk(login, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPutManifestChunkSizes(t *testing.T) {
+	st := newTestStore(t)
+	data := bytes.Repeat([]byte("ab"), fgrpc.ChunkSize)
+	// the right number of chunks, split in the wrong places
+	wrong := [][]byte{data[:fgrpc.ChunkSize-1], data[fgrpc.ChunkSize-1 : 2*fgrpc.ChunkSize-1]}
+	m := manifest("alice", fileEntry("/f", data))
+	m.Entries[1].Chunks = []string{fgrpc.ChunkHash(wrong[0]), fgrpc.ChunkHash(wrong[1])}
+	m.Entries[1].Attr.Size = 2*fgrpc.ChunkSize - 1
+	putChunks(t, st, "alice", wrong...)
+	if err := st.PutManifest(m); err != errInval {
+		t.Errorf("chunks of the wrong sizes: got %v, want EINVAL", err)
+	}
+	if st.Has("alice") {
+		t.Error("the manifest was stored")
+	}
+
+	m = manifest("alice", fileEntry("/f", data))
+	putChunks(t, st, "alice", data[:fgrpc.ChunkSize], data[fgrpc.ChunkSize:])
+	if err := st.PutManifest(m); err != nil {
+		t.Fatal(err)
+	}
+	e := st.tree("alice").entries["/f"]
+	got, err := st.readAt(e, 0, len(data))
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("read back %d bytes, %v", len(got), err)
+	}
+}
+
+func TestMissingIsPerLogin(t *testing.T) {
+	st := newTestStore(t)
+	secret := []byte("alice's answers")
+	putChunks(t, st, "alice", secret)
+	if err := st.PutManifest(manifest("alice", fileEntry("/f", secret))); err != nil {
+		t.Fatal(err)
+	}
+
+	// bob can't tell that alice stored it, nor use it without uploading it
+	guess := manifest("bob", fileEntry("/guess", secret))
+	if missing := st.Missing("bob", guess); len(missing) != 1 {
+		t.Errorf("bob is missing %v, want the chunk he doesn't have", missing)
+	}
+	if err := st.PutManifest(guess); err != errInval {
+		t.Errorf("manifest with another user's chunk: got %v, want EINVAL", err)
+	}
+	putChunks(t, st, "bob", secret)
+	if missing := st.Missing("bob", guess); len(missing) != 0 {
+		t.Errorf("bob is missing %v after uploading it", missing)
+	}
+	if err := st.PutManifest(guess); err != nil {
+		t.Fatal(err)
+	}
+
+	// alice's next snapshot doesn't have to send it again
+	again := manifest("alice", fileEntry("/f", secret), fileEntry("/g", []byte("new")))
+	want := []string{fgrpc.ChunkHash([]byte("new"))}
+	if missing := st.Missing("alice", again); strings.Join(missing, " ") != strings.Join(want, " ") {
+		t.Errorf("alice is missing %v, want %v", missing, want)
+	}
+}
+
+func TestSnapshotStoreReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "42fs-snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	st, err := NewSnapshotStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	putChunks(t, st, "alice", []byte("x"))
+	if err := st.PutManifest(manifest("alice", fileEntry("/f", []byte("x")))); err != nil {
+		t.Fatal(err)
+	}
+	st, err = NewSnapshotStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !st.Has("alice") {
+		t.Fatal("snapshot lost on restart")
+	}
+	if missing := st.Missing("alice", manifest("alice", fileEntry("/f", []byte("x")))); len(missing) != 0 {
+		t.Errorf("alice is missing %v of her own snapshot", missing)
+	}
+}
+
+// eachBlobStore runs test on a blob store in memory and one on disk. age
+// makes the blob named h look last stored d ago.
+func eachBlobStore(t *testing.T, test func(t *testing.T, bs *BlobStore, age func(h string, d time.Duration))) {
+	t.Run("memory", func(t *testing.T) {
+		bs, err := NewBlobStore("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		test(t, bs, func(h string, d time.Duration) {
+			bs.mem[h].added = time.Now().Add(-d)
+		})
+	})
+	t.Run("disk", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "42fs-blobs")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		bs, err := NewBlobStore(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		test(t, bs, func(h string, d time.Duration) {
+			at := time.Now().Add(-d)
+			if err := os.Chtimes(bs.path(h), at, at); err != nil {
+				t.Fatal(err)
+			}
+		})
+	})
+}
+
+func TestBlobGC(t *testing.T) {
+	eachBlobStore(t, func(t *testing.T, bs *BlobStore, age func(string, time.Duration)) {
+		hashes := make(map[string]string)
+		for _, s := range []string{"live", "old", "recent", "touched", "stored again"} {
+			h, err := bs.Put([]byte(s))
+			if err != nil {
+				t.Fatal(err)
+			}
+			hashes[s] = h
+			age(h, 2*time.Hour)
+		}
+		age(hashes["recent"], time.Minute)
+		if !bs.Touch(hashes["touched"]) {
+			t.Fatal("stored blob not found")
+		}
+		if _, err := bs.Put([]byte("stored again")); err != nil {
+			t.Fatal(err)
+		}
+
+		n, err := bs.GC(map[string]bool{hashes["live"]: true}, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Errorf("removed %d blobs, want 1", n)
+		}
+		var kept []string
+		for s, h := range hashes {
+			if _, err := bs.Get(h); err == nil {
+				kept = append(kept, s)
+			}
+		}
+		sort.Strings(kept)
+		if strings.Join(kept, ",") != "live,recent,stored again,touched" {
+			t.Errorf("kept %v", kept)
+		}
+		if bs.Touch(hashes["old"]) {
+			t.Error("a collected blob is still reported as stored")
+		}
+	})
+}
+
+func TestBlobGCRemovesStrayFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "42fs-blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bs, err := NewBlobStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := bs.Put([]byte("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// left behind by a crash in writeFileAtomic
+	stray := filepath.Join(dir, h[:2], "."+h+"123")
+	if err := ioutil.WriteFile(stray, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(stray, old, old)
+	os.Chtimes(bs.path(h), old, old)
+
+	n, err := bs.GC(map[string]bool{h: true}, time.Hour)
+	if err != nil || n != 1 {
+		t.Errorf("GC removed %d, %v", n, err)
+	}
+	if _, err := os.Stat(stray); !os.IsNotExist(err) {
+		t.Errorf("stray file is still there: %v", err)
+	}
+	if _, err := bs.Get(h); err != nil {
+		t.Errorf("live blob: %v", err)
+	}
+}
+
+func TestCollectGarbage(t *testing.T) {
+	st := newTestStore(t)
+	putChunks(t, st, "alice", []byte("kept"), []byte("replaced"))
+	if err := st.PutManifest(manifest("alice", fileEntry("/a", []byte("kept")), fileEntry("/b", []byte("replaced")))); err != nil {
+		t.Fatal(err)
+	}
+	putChunks(t, st, "alice", []byte("new"))
+	if err := st.PutManifest(manifest("alice", fileEntry("/a", []byte("kept")), fileEntry("/b", []byte("new")))); err != nil {
+		t.Fatal(err)
+	}
+	// uploaded for a manifest that never came
+	putChunks(t, st, "bob", []byte("abandoned"))
+
+	if n, err := st.CollectGarbage(time.Hour); err != nil || n != 0 {
+		t.Errorf("collection within the grace period removed %d, %v", n, err)
+	}
+	n, err := st.CollectGarbage(-time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("removed %d blobs, want the replaced and the abandoned one", n)
+	}
+	for s, want := range map[string]bool{"kept": true, "new": true, "replaced": false, "abandoned": false} {
+		_, err := st.blobs.Get(fgrpc.ChunkHash([]byte(s)))
+		if (err == nil) != want {
+			t.Errorf("%s: %v", s, err)
+		}
+	}
+	if len(st.uploads) != 0 {
+		t.Errorf("uploads of collected blobs are still remembered: %v", st.uploads)
+	}
+}
@@ -0,0 +1,215 @@
+// Package fs42test runs several simulated 42fs users in one process, for
+// end-to-end tests. Each user has a public directory in a temporary
+// directory and the FS42 their daemon would run. An in-process Coordinator
+// connects the users to each other without gRPC, and Mount serves a user's
+// filesystem through FUSE.
+package fs42test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"bazil.org/fuse"
+	"github.com/riking/42fs/auth"
+	"github.com/riking/42fs/fscore"
+	fgrpc "github.com/riking/42fs/grpc"
+)
+
+// firstINode matches the first inode number the real coordinator assigns.
+const firstINode = 16
+
+// Coordinator is an in-process stand-in for the coordination server. It
+// hands out DirConns instead of addresses, so that readers call the owner's
+// PeerServer directly.
+type Coordinator struct {
+	lock      sync.Mutex
+	users     map[string]*User
+	conns     map[[2]string]*DirConn
+	nextINode uint64
+}
+
+// User is a simulated user whose daemon is running, or not, in this
+// process.
+type User struct {
+	Login string
+	// Dir is the user's public directory.
+	Dir string
+	// FS42 is the filesystem the user's daemon serves, both to the kernel,
+	// see Mount, and to other users.
+	FS42 *fscore.FS42
+
+	c       *Coordinator
+	inode   uint64
+	created time.Time
+	peers   *fscore.PeerServer
+	online  bool
+}
+
+func NewCoordinator() *Coordinator {
+	return &Coordinator{
+		users:     make(map[string]*User),
+		conns:     make(map[[2]string]*DirConn),
+		nextINode: firstINode,
+	}
+}
+
+// AddUser creates login with an empty public directory, which peers can
+// enter, and brings their daemon online. The directory is removed when the
+// test ends.
+func (c *Coordinator) AddUser(t testing.TB, login string) *User {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "42fs-"+login)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	err = os.Chmod(dir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.users[login]; ok {
+		t.Fatalf("user %s added twice", login)
+	}
+	u := &User{
+		Login:   login,
+		Dir:     dir,
+		c:       c,
+		inode:   c.nextINode,
+		created: time.Now(),
+		online:  true,
+	}
+	c.nextINode++
+	u.FS42 = fscore.NewFS42(&coordView{c: c, login: login}, auth.NewUnverifiedSession(login), dir)
+	u.peers = fscore.NewPeerServer(u.FS42)
+	c.users[login] = u
+	t.Cleanup(func() { u.FS42.Close() })
+	return u
+}
+
+// SetOnline starts or stops serving u's directory to other users. Readers
+// get EHOSTDOWN while it is stopped, as there is no snapshot to fall back on.
+func (u *User) SetOnline(online bool) {
+	u.c.lock.Lock()
+	defer u.c.lock.Unlock()
+	u.online = online
+}
+
+// WriteFile creates or replaces the file at name, relative to u's public
+// directory, with exactly the permissions perm.
+func (u *User) WriteFile(t testing.TB, name, contents string, perm os.FileMode) {
+	t.Helper()
+	p := filepath.Join(u.Dir, name)
+	err := ioutil.WriteFile(p, []byte(contents), perm)
+	if err == nil {
+		err = os.Chmod(p, perm)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Mkdir creates the directory at name, relative to u's public directory,
+// with exactly the permissions perm.
+func (u *User) Mkdir(t testing.TB, name string, perm os.FileMode) {
+	t.Helper()
+	p := filepath.Join(u.Dir, name)
+	err := os.Mkdir(p, perm)
+	if err == nil {
+		err = os.Chmod(p, perm)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// conn returns the connection caller reads owner's directory through. It is
+// the same on every call, so that the caller's caches survive lookups.
+// The lock must be held.
+func (c *Coordinator) conn(owner *User, caller string) *DirConn {
+	key := [2]string{owner.Login, caller}
+	dc, ok := c.conns[key]
+	if !ok {
+		dc = NewDirConn(owner.peers, caller)
+		c.conns[key] = dc
+	}
+	return dc
+}
+
+// info must be called with the lock held.
+func (c *Coordinator) info(u *User, caller string) fgrpc.LoginInfo {
+	info := fgrpc.LoginInfo{Login: u.Login, Exists: true, INode: u.inode}
+	if u.online {
+		info.WasOnline = true
+		info.Addr = "fs42test:" + u.Login
+		info.Conn = c.conn(u, caller)
+	}
+	return info
+}
+
+// coordView is the Coordinator as seen by one user's daemon.
+type coordView struct {
+	c     *Coordinator
+	login string
+}
+
+var _ fgrpc.CoordinatorServer = &coordView{}
+
+func (v *coordView) UserDirInfo(ctx context.Context, login string) (*fgrpc.LoginInfo, error) {
+	v.c.lock.Lock()
+	defer v.c.lock.Unlock()
+
+	u, ok := v.c.users[login]
+	if !ok {
+		return &fgrpc.LoginInfo{Login: login}, nil
+	}
+	info := v.c.info(u, v.login)
+	return &info, nil
+}
+
+func (v *coordView) UserDirStat(ctx context.Context, login string) (*fgrpc.FileAttr, error) {
+	v.c.lock.Lock()
+	defer v.c.lock.Unlock()
+
+	u, ok := v.c.users[login]
+	if !ok {
+		return nil, fuse.ENOENT
+	}
+	return &fgrpc.FileAttr{
+		INode:     u.inode,
+		Mtime:     u.created,
+		Ctime:     u.created,
+		BirthTime: u.created,
+		Nlink:     2,
+		Mode:      os.ModeDir | 0555,
+	}, nil
+}
+
+func (v *coordView) ListUsers(ctx context.Context, onlineOnly bool) ([]fgrpc.LoginInfo, error) {
+	v.c.lock.Lock()
+	defer v.c.lock.Unlock()
+
+	list := make([]fgrpc.LoginInfo, 0, len(v.c.users))
+	for _, u := range v.c.users {
+		if onlineOnly && !u.online {
+			continue
+		}
+		list = append(list, v.c.info(u, v.login))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Login < list[j].Login })
+	return list, nil
+}
+
+func (v *coordView) MyINode(ctx context.Context) uint64 {
+	v.c.lock.Lock()
+	defer v.c.lock.Unlock()
+	return v.c.users[v.login].inode
+}
@@ -0,0 +1,194 @@
+package fs42test
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/riking/42fs/fscore"
+	fgrpc "github.com/riking/42fs/grpc"
+)
+
+// listDir returns the sorted names in the directory at p.
+func listDir(t *testing.T, conn fgrpc.UserConnection, p string) string {
+	ctx := context.Background()
+	_, fd, err := conn.Open(ctx, p, true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx, fd)
+	ents, err := conn.ReadDir(ctx, fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range ents {
+		names = append(names, e.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, " ")
+}
+
+func readFile(conn fgrpc.UserConnection, p string) (string, error) {
+	ctx := context.Background()
+	_, fd, err := conn.Open(ctx, p, false, 0)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close(ctx, fd)
+	b, err := conn.ReadFrom(ctx, &fgrpc.ReadRequest{FD: fd, Size: 4096})
+	return string(b), err
+}
+
+func TestUsersListEachOther(t *testing.T) {
+	ctx := context.Background()
+	c := NewCoordinator()
+	alice := c.AddUser(t, "alice")
+	bob := c.AddUser(t, "bob")
+	alice.WriteFile(t, "hello.txt", "hi from alice", 0644)
+	alice.WriteFile(t, "secret", "not for bob", 0600)
+	alice.Mkdir(t, "sub", 0755)
+	bob.WriteFile(t, "mine", "hi from bob", 0644)
+
+	for _, tc := range []struct {
+		reader, owner *User
+		names         string
+		file, data    string
+	}{
+		{bob, alice, "hello.txt secret sub", "/hello.txt", "hi from alice"},
+		{alice, bob, "mine", "/mine", "hi from bob"},
+	} {
+		view := &coordView{c: c, login: tc.reader.Login}
+		users, err := view.ListUsers(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(users) != 2 || users[0].Login != "alice" || users[1].Login != "bob" {
+			t.Fatalf("%s is told of %v", tc.reader.Login, users)
+		}
+		info, err := view.UserDirInfo(ctx, tc.owner.Login)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.Exists || info.Conn == nil || info.INode != tc.owner.inode {
+			t.Fatalf("%s is told %+v about %s", tc.reader.Login, info, tc.owner.Login)
+		}
+		if got := listDir(t, info.Conn, "/"); got != tc.names {
+			t.Errorf("%s sees %q in %s's directory, want %q", tc.reader.Login, got, tc.owner.Login, tc.names)
+		}
+		if got, err := readFile(info.Conn, tc.file); err != nil || got != tc.data {
+			t.Errorf("%s read %q, %v from %s", tc.reader.Login, got, err, tc.file)
+		}
+	}
+
+	info, _ := (&coordView{c: c, login: "bob"}).UserDirInfo(ctx, "alice")
+	if _, err := readFile(info.Conn, "/secret"); err == nil {
+		t.Error("bob read a file only alice may read")
+	}
+	info, _ = (&coordView{c: c, login: "bob"}).UserDirInfo(ctx, "carol")
+	if info.Exists {
+		t.Error("carol exists without being added")
+	}
+}
+
+func TestUserGoesOffline(t *testing.T) {
+	ctx := context.Background()
+	c := NewCoordinator()
+	alice := c.AddUser(t, "alice")
+	c.AddUser(t, "bob")
+	view := &coordView{c: c, login: "bob"}
+
+	alice.SetOnline(false)
+	info, err := view.UserDirInfo(ctx, "alice")
+	if err != nil || !info.Exists || info.Conn != nil {
+		t.Errorf("alice offline is %+v, %v", info, err)
+	}
+	online, _ := view.ListUsers(ctx, true)
+	if len(online) != 1 || online[0].Login != "bob" {
+		t.Errorf("online users are %v", online)
+	}
+	alice.SetOnline(true)
+	info, _ = view.UserDirInfo(ctx, "alice")
+	if info.Conn == nil {
+		t.Error("no connection to alice once back online")
+	}
+}
+
+func TestDirConn(t *testing.T) {
+	ctx := context.Background()
+	c := NewCoordinator()
+	alice := c.AddUser(t, "alice")
+	alice.WriteFile(t, "hello.txt", "hi", 0644)
+	ps := fscore.NewPeerServer(alice.FS42)
+	dc := NewDirConn(ps, "bob")
+
+	a, err := dc.Stat(ctx, "/hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Size != 2 || !a.Mode.IsRegular() {
+		t.Errorf("hello.txt is %v, %d bytes", a.Mode, a.Size)
+	}
+	if got := listDir(t, dc, "/"); got != "hello.txt" {
+		t.Errorf("root lists %q", got)
+	}
+
+	_, fd, err := dc.Open(ctx, "/hello.txt", false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dc.Close(ctx, fd)
+	b, err := dc.ReadFrom(ctx, &fgrpc.ReadRequest{FD: fd, Size: 100})
+	if err != nil || string(b) != "hi" {
+		t.Errorf("read %q, %v", b, err)
+	}
+	// handles belong to the daemon that opened them
+	other := NewDirConn(ps, "carol")
+	if _, err := other.ReadFrom(ctx, &fgrpc.ReadRequest{FD: fd, Size: 100}); err == nil {
+		t.Error("carol read from bob's handle")
+	}
+}
+
+func TestMount(t *testing.T) {
+	c := NewCoordinator()
+	alice := c.AddUser(t, "alice")
+	bob := c.AddUser(t, "bob")
+	alice.WriteFile(t, "hello.txt", "hi from alice", 0644)
+
+	mnt := bob.Mount(t)
+	b, err := ioutil.ReadFile(filepath.Join(mnt, "alice", "hello.txt"))
+	if err != nil || string(b) != "hi from alice" {
+		t.Errorf("read %q, %v through the mount", b, err)
+	}
+}
+
+func TestVFS(t *testing.T) {
+	c := NewCoordinator()
+	alice := c.AddUser(t, "alice")
+	bob := c.AddUser(t, "bob")
+	alice.WriteFile(t, "hello.txt", "hi from alice", 0644)
+	bob.WriteFile(t, "mine", "hi from bob", 0644)
+
+	v := bob.VFS(t)
+	ents, err := v.ReadDir("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range ents {
+		names = append(names, e.Name)
+	}
+	sort.Strings(names)
+	if got := strings.Join(names, " "); got != "README alice bob" {
+		t.Errorf("bob's root lists %q", got)
+	}
+	for p, want := range map[string]string{"alice/hello.txt": "hi from alice", "bob/mine": "hi from bob"} {
+		b, err := v.ReadFile(p)
+		if err != nil || string(b) != want {
+			t.Errorf("bob read %q, %v from %s", b, err, p)
+		}
+	}
+}
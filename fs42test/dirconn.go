@@ -0,0 +1,97 @@
+package fs42test
+
+import (
+	"context"
+	"os"
+
+	"bazil.org/fuse"
+	"github.com/riking/42fs/auth"
+	"github.com/riking/42fs/fscore"
+	fgrpc "github.com/riking/42fs/grpc"
+)
+
+// DirConn is a UserConnection to a directory on this machine. It calls the
+// owner's PeerServer as caller, as if the call had come in over gRPC with
+// caller's token, so the same permission checks apply.
+type DirConn struct {
+	ps *fscore.PeerServer
+	id *auth.Identity
+}
+
+var _ fgrpc.UserConnection = &DirConn{}
+
+func NewDirConn(ps *fscore.PeerServer, caller string) *DirConn {
+	return &DirConn{ps: ps, id: &auth.Identity{Login: caller}}
+}
+
+func (c *DirConn) ctx(ctx context.Context) context.Context {
+	return auth.NewContext(ctx, c.id)
+}
+
+func (c *DirConn) Access(ctx context.Context, path string, mode uint32) error {
+	return c.ps.Access(c.ctx(ctx), path, mode)
+}
+
+func (c *DirConn) Stat(ctx context.Context, path string) (*fgrpc.FileAttr, error) {
+	return c.ps.Stat(c.ctx(ctx), path)
+}
+
+func (c *DirConn) Getxattr(ctx context.Context, path string, attr string, size uint32, position uint32) ([]byte, error) {
+	return c.ps.Getxattr(c.ctx(ctx), path, attr, size, position)
+}
+
+func (c *DirConn) Listxattr(ctx context.Context, path string, size uint32, position uint32) ([]byte, error) {
+	return c.ps.Listxattr(c.ctx(ctx), path, size, position)
+}
+
+func (c *DirConn) Open(ctx context.Context, path string, dir bool, flags fgrpc.AgnosticOpenFlags) (fuse.OpenResponseFlags, uint64, error) {
+	return c.ps.Open(c.ctx(ctx), path, dir, flags)
+}
+
+func (c *DirConn) Readlink(ctx context.Context, path string) (string, error) {
+	return c.ps.Readlink(c.ctx(ctx), path)
+}
+
+func (c *DirConn) LookupExists(ctx context.Context, path string) error {
+	return c.ps.LookupExists(c.ctx(ctx), path)
+}
+
+func (c *DirConn) ReadDir(ctx context.Context, fd uint64) ([]fgrpc.Dirent, error) {
+	return c.ps.ReadDir(c.ctx(ctx), fd)
+}
+
+func (c *DirConn) ReadFrom(ctx context.Context, req *fgrpc.ReadRequest) ([]byte, error) {
+	return c.ps.ReadFrom(c.ctx(ctx), req)
+}
+
+func (c *DirConn) Close(ctx context.Context, fd uint64) error {
+	return c.ps.Close(c.ctx(ctx), fd)
+}
+
+func (c *DirConn) Subscribe(ctx context.Context, events chan<- fgrpc.ChangeEvent) error {
+	return c.ps.Subscribe(c.ctx(ctx), events)
+}
+
+func (c *DirConn) Create(ctx context.Context, path string, mode os.FileMode, flags fgrpc.AgnosticOpenFlags) (fuse.OpenResponseFlags, uint64, error) {
+	return c.ps.Create(c.ctx(ctx), path, mode, flags)
+}
+
+func (c *DirConn) Mkdir(ctx context.Context, path string, mode os.FileMode) error {
+	return c.ps.Mkdir(c.ctx(ctx), path, mode)
+}
+
+func (c *DirConn) Remove(ctx context.Context, path string, dir bool) error {
+	return c.ps.Remove(c.ctx(ctx), path, dir)
+}
+
+func (c *DirConn) Rename(ctx context.Context, oldPath, newPath string) error {
+	return c.ps.Rename(c.ctx(ctx), oldPath, newPath)
+}
+
+func (c *DirConn) Setattr(ctx context.Context, req *fgrpc.SetattrRequest) (*fgrpc.FileAttr, error) {
+	return c.ps.Setattr(c.ctx(ctx), req)
+}
+
+func (c *DirConn) WriteTo(ctx context.Context, req *fgrpc.WriteRequest) (int, error) {
+	return c.ps.WriteTo(c.ctx(ctx), req)
+}
@@ -0,0 +1,79 @@
+package fs42test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"bazil.org/fuse"
+	"github.com/riking/42fs/fs42test/vfs"
+	"github.com/riking/42fs/libfuse"
+)
+
+// unmountTimeout bounds how long the end of a test waits for the
+// filesystem to stop being served.
+const unmountTimeout = 5 * time.Second
+
+// Mount mounts u's filesystem, as their daemon would, on a temporary
+// directory with libfuse.DefaultMounter. It is served until the test ends,
+// and then unmounted. The test is skipped if FUSE isn't available.
+func (u *User) Mount(t testing.TB) string {
+	t.Helper()
+	if err := fuseAvailable(); err != nil {
+		t.Skipf("FUSE is not available: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "42fs-mnt-"+u.Login)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mounter := libfuse.NewDefaultMounter(dir,
+		fuse.FSName("42fs"),
+		fuse.VolumeName("42fs"),
+		fuse.NoAppleDouble(),
+	)
+	conn, err := mounter.Mount()
+	if err != nil {
+		os.Remove(dir)
+		t.Fatalf("mounting %s: %v", dir, err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- u.FS42.Serve(conn)
+	}()
+	<-conn.Ready
+	if conn.MountError != nil {
+		conn.Close()
+		os.Remove(dir)
+		t.Fatalf("mounting %s: %v", dir, conn.MountError)
+	}
+
+	t.Cleanup(func() {
+		err := mounter.Unmount()
+		if err != nil {
+			t.Errorf("unmounting %s: %v; are files still open?", dir, err)
+		}
+		select {
+		case err = <-served:
+			if err != nil {
+				t.Errorf("serving %s: %v", dir, err)
+			}
+		case <-time.After(unmountTimeout):
+			t.Errorf("%s was still served %v after unmounting", dir, unmountTimeout)
+		}
+		conn.Close()
+		os.Remove(dir)
+	})
+	return dir
+}
+
+// VFS returns a walker over u's filesystem, which calls the nodes directly
+// instead of going through the kernel. It works without FUSE.
+func (u *User) VFS(t testing.TB) *vfs.FS {
+	t.Helper()
+	v, err := vfs.New(u.FS42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
@@ -0,0 +1,17 @@
+package fs42test
+
+import (
+	"os"
+
+	"bazil.org/fuse"
+)
+
+// fuseAvailable returns why FUSE filesystems can't be mounted, if they
+// can't.
+func fuseAvailable() error {
+	_, err := os.Stat(fuse.OSXFUSELocationV3.Mount)
+	if err != nil {
+		_, err = os.Stat(fuse.OSXFUSELocationV2.Mount)
+	}
+	return err
+}
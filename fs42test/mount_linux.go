@@ -0,0 +1,17 @@
+package fs42test
+
+import (
+	"os"
+	"os/exec"
+)
+
+// fuseAvailable returns why FUSE filesystems can't be mounted, if they
+// can't.
+func fuseAvailable() error {
+	_, err := os.Stat("/dev/fuse")
+	if err != nil {
+		return err
+	}
+	_, err = exec.LookPath("fusermount")
+	return err
+}
@@ -0,0 +1,328 @@
+// Package vfs drives a bazil.org/fuse filesystem without the kernel, by
+// calling the fs.Node and fs.Handle methods the way fs.Server would. It
+// lets tests cover fscore on machines that can't mount FUSE filesystems.
+//
+// Unlike the kernel, vfs does not cache anything, check permissions or
+// resolve symbolic links, and it passes ".." to Lookup as is, so that tests
+// can check that nodes refuse to leave their directory.
+package vfs
+
+import (
+	"io"
+	"os"
+	"strings"
+	"syscall"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"golang.org/x/net/context"
+)
+
+// maxRead is the largest read the kernel sends, with the default
+// max_read mount option.
+const maxRead = 128 << 10
+
+// FS walks the node tree of a filesystem.
+type FS struct {
+	root fs.Node
+	// Context is passed to every call. It defaults to
+	// context.Background().
+	Context context.Context
+}
+
+// New returns a walker for the tree of filesystem.
+func New(filesystem fs.FS) (*FS, error) {
+	root, err := filesystem.Root()
+	if err != nil {
+		return nil, err
+	}
+	return &FS{root: root, Context: context.Background()}, nil
+}
+
+// split turns p into the names to look up from the root. Empty names and
+// "." are dropped.
+func split(p string) []string {
+	var names []string
+	for _, name := range strings.Split(p, "/") {
+		if name != "" && name != "." {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// lookup looks name up in dir, as fs.Server does for a LookupRequest.
+func (v *FS) lookup(dir fs.Node, name string) (fs.Node, error) {
+	switch d := dir.(type) {
+	case fs.NodeRequestLookuper:
+		req := &fuse.LookupRequest{Name: name}
+		return d.Lookup(v.Context, req, &fuse.LookupResponse{})
+	case fs.NodeStringLookuper:
+		return d.Lookup(v.Context, name)
+	}
+	return nil, fuse.ENOENT
+}
+
+// Lookup returns the node at p, which is relative to the root.
+func (v *FS) Lookup(p string) (fs.Node, error) {
+	n := v.root
+	for _, name := range split(p) {
+		next, err := v.lookup(n, name)
+		if err != nil {
+			return nil, &os.PathError{Op: "lookup", Path: p, Err: err}
+		}
+		n = next
+	}
+	return n, nil
+}
+
+// Stat returns the attributes of the node at p.
+func (v *FS) Stat(p string) (fuse.Attr, error) {
+	var a fuse.Attr
+	n, err := v.Lookup(p)
+	if err != nil {
+		return a, err
+	}
+	if g, ok := n.(fs.NodeGetattrer); ok {
+		var resp fuse.GetattrResponse
+		err = g.Getattr(v.Context, &fuse.GetattrRequest{}, &resp)
+		a = resp.Attr
+	} else {
+		err = n.Attr(v.Context, &a)
+	}
+	if err != nil {
+		return a, &os.PathError{Op: "stat", Path: p, Err: err}
+	}
+	return a, nil
+}
+
+// File is an open handle.
+type File struct {
+	v      *FS
+	path   string
+	node   fs.Node
+	handle fs.Handle
+	flags  fuse.OpenFlags
+	// all caches HandleReadAller's result, like fs.Server does per handle
+	all []byte
+}
+
+func (v *FS) open(p string, n fs.Node, dir bool, flags fuse.OpenFlags) (*File, error) {
+	f := &File{v: v, path: p, node: n, handle: n, flags: flags}
+	if o, ok := n.(fs.NodeOpener); ok {
+		req := &fuse.OpenRequest{Dir: dir, Flags: flags}
+		h, err := o.Open(v.Context, req, &fuse.OpenResponse{})
+		if err != nil {
+			return nil, &os.PathError{Op: "open", Path: p, Err: err}
+		}
+		f.handle = h
+	}
+	return f, nil
+}
+
+// Open opens the file at p with flags, such as fuse.OpenReadOnly.
+func (v *FS) Open(p string, flags fuse.OpenFlags) (*File, error) {
+	n, err := v.Lookup(p)
+	if err != nil {
+		return nil, err
+	}
+	return v.open(p, n, false, flags)
+}
+
+// OpenDir opens the directory at p for reading.
+func (v *FS) OpenDir(p string) (*File, error) {
+	n, err := v.Lookup(p)
+	if err != nil {
+		return nil, err
+	}
+	return v.open(p, n, true, fuse.OpenReadOnly|fuse.OpenDirectory)
+}
+
+// Create creates the file at p, or opens it if it exists and flags don't
+// include fuse.OpenExclusive.
+func (v *FS) Create(p string, flags fuse.OpenFlags, mode os.FileMode) (*File, error) {
+	names := split(p)
+	if len(names) == 0 {
+		return nil, &os.PathError{Op: "create", Path: p, Err: syscall.EEXIST}
+	}
+	dir, err := v.Lookup(strings.Join(names[:len(names)-1], "/"))
+	if err != nil {
+		return nil, err
+	}
+	c, ok := dir.(fs.NodeCreater)
+	if !ok {
+		return nil, &os.PathError{Op: "create", Path: p, Err: fuse.EPERM}
+	}
+	req := &fuse.CreateRequest{Name: names[len(names)-1], Flags: flags | fuse.OpenCreate, Mode: mode}
+	n, h, err := c.Create(v.Context, req, &fuse.CreateResponse{})
+	if err != nil {
+		return nil, &os.PathError{Op: "create", Path: p, Err: err}
+	}
+	return &File{v: v, path: p, node: n, handle: h, flags: req.Flags}, nil
+}
+
+// Node returns the node the file was opened from.
+func (f *File) Node() fs.Node {
+	return f.node
+}
+
+// Handle returns what the node's Open returned.
+func (f *File) Handle() fs.Handle {
+	return f.handle
+}
+
+// ReadDir lists the directory, with ReadDirAll.
+func (f *File) ReadDir() ([]fuse.Dirent, error) {
+	h, ok := f.handle.(fs.HandleReadDirAller)
+	if !ok {
+		return nil, &os.PathError{Op: "readdir", Path: f.path, Err: fuse.Errno(syscall.ENOTDIR)}
+	}
+	ents, err := h.ReadDirAll(f.v.Context)
+	if err != nil {
+		return nil, &os.PathError{Op: "readdir", Path: f.path, Err: err}
+	}
+	return ents, nil
+}
+
+// ReadAt reads like io.ReaderAt, in requests no larger than the kernel's.
+// It reads until b is full or a request returns nothing, so short reads
+// are not taken for the end of the file.
+func (f *File) ReadAt(b []byte, off int64) (int, error) {
+	n := 0
+	for n < len(b) {
+		size := len(b) - n
+		if size > maxRead {
+			size = maxRead
+		}
+		data, err := f.read(off+int64(n), size)
+		if err != nil {
+			return n, &os.PathError{Op: "read", Path: f.path, Err: err}
+		}
+		if len(data) == 0 {
+			return n, io.EOF
+		}
+		n += copy(b[n:], data)
+	}
+	return n, nil
+}
+
+func (f *File) read(off int64, size int) ([]byte, error) {
+	switch h := f.handle.(type) {
+	case fs.HandleReader:
+		req := &fuse.ReadRequest{Offset: off, Size: size, FileFlags: f.flags}
+		var resp fuse.ReadResponse
+		err := h.Read(f.v.Context, req, &resp)
+		return resp.Data, err
+	case fs.HandleReadAller:
+		if f.all == nil {
+			all, err := h.ReadAll(f.v.Context)
+			if err != nil {
+				return nil, err
+			}
+			f.all = all
+		}
+		if off >= int64(len(f.all)) {
+			return nil, nil
+		}
+		end := off + int64(size)
+		if end > int64(len(f.all)) {
+			end = int64(len(f.all))
+		}
+		return f.all[off:end], nil
+	}
+	return nil, fuse.Errno(syscall.EINVAL)
+}
+
+// WriteAt writes like io.WriterAt.
+func (f *File) WriteAt(b []byte, off int64) (int, error) {
+	h, ok := f.handle.(fs.HandleWriter)
+	if !ok {
+		return 0, &os.PathError{Op: "write", Path: f.path, Err: fuse.EPERM}
+	}
+	n := 0
+	for n < len(b) {
+		size := len(b) - n
+		if size > maxRead {
+			size = maxRead
+		}
+		req := &fuse.WriteRequest{Offset: off + int64(n), Data: b[n : n+size], FileFlags: f.flags}
+		var resp fuse.WriteResponse
+		err := h.Write(f.v.Context, req, &resp)
+		n += resp.Size
+		if err != nil {
+			return n, &os.PathError{Op: "write", Path: f.path, Err: err}
+		}
+		if resp.Size < size {
+			return n, io.ErrShortWrite
+		}
+	}
+	return n, nil
+}
+
+// Close flushes and releases the handle, as closing the last file
+// descriptor does.
+func (f *File) Close() error {
+	var err error
+	if h, ok := f.handle.(fs.HandleFlusher); ok {
+		err = h.Flush(f.v.Context, &fuse.FlushRequest{})
+	}
+	if h, ok := f.handle.(fs.HandleReleaser); ok {
+		err2 := h.Release(f.v.Context, &fuse.ReleaseRequest{Flags: f.flags})
+		if err == nil {
+			err = err2
+		}
+	}
+	if err != nil {
+		return &os.PathError{Op: "close", Path: f.path, Err: err}
+	}
+	return nil
+}
+
+// ReadFile returns the contents of the file at p.
+func (v *FS) ReadFile(p string) ([]byte, error) {
+	f, err := v.Open(p, fuse.OpenReadOnly)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	buf := make([]byte, maxRead)
+	for {
+		n, err := f.ReadAt(buf, int64(len(data)))
+		data = append(data, buf[:n]...)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return data, f.Close()
+}
+
+// ReadDir lists the directory at p.
+func (v *FS) ReadDir(p string) ([]fuse.Dirent, error) {
+	f, err := v.OpenDir(p)
+	if err != nil {
+		return nil, err
+	}
+	ents, err := f.ReadDir()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return ents, f.Close()
+}
+
+// WriteFile creates or truncates the file at p and writes data to it.
+func (v *FS) WriteFile(p string, data []byte, mode os.FileMode) error {
+	f, err := v.Create(p, fuse.OpenWriteOnly|fuse.OpenTruncate, mode)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteAt(data, 0)
+	err2 := f.Close()
+	if err == nil {
+		err = err2
+	}
+	return err
+}
@@ -0,0 +1,147 @@
+package vfs_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bazil.org/fuse"
+	"github.com/riking/42fs/auth"
+	"github.com/riking/42fs/fs42test/vfs"
+	"github.com/riking/42fs/fscore"
+)
+
+// newLocal returns a walker over the filesystem of alice, who has no
+// coordinator, and alice's public directory.
+func newLocal(t *testing.T) (*vfs.FS, string) {
+	dir, err := ioutil.TempDir("", "42fs-vfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	fs42 := fscore.NewFS42(nil, auth.NewUnverifiedSession("alice"), dir)
+	t.Cleanup(func() { fs42.Close() })
+	v, err := vfs.New(fs42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v, dir
+}
+
+func TestWalkLocalDir(t *testing.T) {
+	v, dir := newLocal(t)
+	// bigger than a single read
+	big := bytes.Repeat([]byte("0123456789"), 50000)
+	if err := ioutil.WriteFile(filepath.Join(dir, "big"), big, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "sub", "f"), []byte("in sub"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ents, err := v.ReadDir("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range ents {
+		names = append(names, e.Name)
+	}
+	if len(names) != 2 || names[0] != "README" || names[1] != "alice" {
+		t.Errorf("root lists %v, want README and alice", names)
+	}
+	ents, err = v.ReadDir("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ents) != 2 {
+		t.Errorf("alice lists %v, want big and sub", ents)
+	}
+	for _, e := range ents {
+		if e.Name == "sub" && e.Type != fuse.DT_Dir {
+			t.Errorf("sub has type %v", e.Type)
+		}
+	}
+
+	b, err := v.ReadFile("alice/big")
+	if err != nil || !bytes.Equal(b, big) {
+		t.Errorf("read %d of %d bytes of big: %v", len(b), len(big), err)
+	}
+	b, err = v.ReadFile("alice/sub/f")
+	if err != nil || string(b) != "in sub" {
+		t.Errorf("read %q from sub/f: %v", b, err)
+	}
+	a, err := v.Stat("alice/sub")
+	if err != nil || !a.Mode.IsDir() {
+		t.Errorf("sub is %v: %v", a.Mode, err)
+	}
+	if _, err := v.Stat("alice/nope"); err == nil {
+		t.Error("stat of a missing file succeeded")
+	}
+}
+
+func TestReadAt(t *testing.T) {
+	v, dir := newLocal(t)
+	if err := ioutil.WriteFile(filepath.Join(dir, "f"), []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := v.Open("alice/f", fuse.OpenReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b := make([]byte, 4)
+	n, err := f.ReadAt(b, 3)
+	if err != nil || string(b[:n]) != "3456" {
+		t.Errorf("read %q at 3: %v", b[:n], err)
+	}
+	n, _ = f.ReadAt(b, 8)
+	if string(b[:n]) != "89" {
+		t.Errorf("read %q at 8", b[:n])
+	}
+}
+
+func TestWriteLocalDir(t *testing.T) {
+	v, dir := newLocal(t)
+	if err := v.WriteFile("alice/new", []byte("made here"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "new"))
+	if err != nil || string(b) != "made here" {
+		t.Errorf("on disk: %q, %v", b, err)
+	}
+	a, err := v.Stat("alice/new")
+	if err != nil || a.Size != 9 || a.Mode.Perm() != 0640 {
+		t.Errorf("new is %v, %d bytes: %v", a.Mode, a.Size, err)
+	}
+
+	// truncated by a second write
+	if err := v.WriteFile("alice/new", []byte("short"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	b, err = v.ReadFile("alice/new")
+	if err != nil || string(b) != "short" {
+		t.Errorf("read back %q, %v", b, err)
+	}
+	if _, err := v.Create("alice/new", fuse.OpenWriteOnly|fuse.OpenExclusive, 0644); err == nil {
+		t.Error("exclusive create of an existing file succeeded")
+	}
+}
+
+func TestDotDot(t *testing.T) {
+	v, dir := newLocal(t)
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// vfs hands ".." to the nodes, which must not leave the directory
+	for _, p := range []string{"alice/..", "alice/../alice", "alice/sub/../..", "alice/sub/../../.."} {
+		if _, err := v.Stat(p); err == nil {
+			t.Errorf("stat of %s succeeded", p)
+		}
+	}
+}
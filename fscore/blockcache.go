@@ -0,0 +1,173 @@
+package fscore
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	fgrpc "github.com/riking/42fs/grpc"
+)
+
+// blockSize is the unit file contents are fetched and cached in. It matches
+// the largest read the kernel sends.
+const blockSize = 128 << 10
+
+// fileVersion identifies the contents of a file. Blocks cached for one
+// version are never returned for another, so a file that was modified is
+// fetched again; the old blocks age out of the cache.
+type fileVersion struct {
+	mtime int64
+	size  uint64
+}
+
+func versionOf(st *fgrpc.FileAttr) fileVersion {
+	return fileVersion{mtime: st.Mtime.UnixNano(), size: st.Size}
+}
+
+type blockKey struct {
+	login string
+	path  string
+	ver   fileVersion
+	index int64
+}
+
+// blockLoad is a fetch of a block in progress, which concurrent readers of
+// the same block wait for instead of fetching it again.
+type blockLoad struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+type cachedBlock struct {
+	key  blockKey
+	data []byte
+}
+
+// blockCache holds the contents of other users' files, shared by every
+// UserDir and bounded by CacheConfig.BlockCacheSize.
+type blockCache struct {
+	cfg *CacheConfig
+
+	lock    sync.Mutex
+	used    int64
+	lru     *list.List // of *cachedBlock, most recently used first
+	blocks  map[blockKey]*list.Element
+	loading map[blockKey]*blockLoad
+}
+
+func newBlockCache(cfg *CacheConfig) *blockCache {
+	return &blockCache{
+		cfg:     cfg,
+		lru:     list.New(),
+		blocks:  make(map[blockKey]*list.Element),
+		loading: make(map[blockKey]*blockLoad),
+	}
+}
+
+func (bc *blockCache) enabled() bool {
+	return bc.cfg.BlockCacheSize > 0
+}
+
+// has reports whether the block is cached or being fetched.
+func (bc *blockCache) has(key blockKey) bool {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+	_, cached := bc.blocks[key]
+	_, loading := bc.loading[key]
+	return cached || loading
+}
+
+// peek returns a cached block without fetching it.
+func (bc *blockCache) peek(key blockKey) ([]byte, bool) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+	el, ok := bc.blocks[key]
+	if !ok {
+		return nil, false
+	}
+	return el.Value.(*cachedBlock).data, true
+}
+
+// get returns the block named by key, calling load to fetch it if it isn't
+// cached. Errors are not cached.
+func (bc *blockCache) get(key blockKey, load func() ([]byte, error)) ([]byte, error) {
+	bc.lock.Lock()
+	if el, ok := bc.blocks[key]; ok {
+		bc.lru.MoveToFront(el)
+		bc.lock.Unlock()
+		return el.Value.(*cachedBlock).data, nil
+	}
+	if l, ok := bc.loading[key]; ok {
+		bc.lock.Unlock()
+		<-l.done
+		return l.data, l.err
+	}
+	l := &blockLoad{done: make(chan struct{})}
+	bc.loading[key] = l
+	bc.lock.Unlock()
+
+	l.data, l.err = load()
+
+	bc.lock.Lock()
+	delete(bc.loading, key)
+	if l.err == nil {
+		bc.add(key, l.data)
+	}
+	bc.lock.Unlock()
+	close(l.done)
+	return l.data, l.err
+}
+
+// add inserts a block and evicts the least recently used ones over the
+// size limit. It must be called with the lock held.
+func (bc *blockCache) add(key blockKey, data []byte) {
+	if _, ok := bc.blocks[key]; ok {
+		return
+	}
+	bc.blocks[key] = bc.lru.PushFront(&cachedBlock{key: key, data: data})
+	bc.used += int64(len(data))
+	for bc.used > bc.cfg.BlockCacheSize && bc.lru.Len() > 0 {
+		el := bc.lru.Back()
+		b := el.Value.(*cachedBlock)
+		bc.lru.Remove(el)
+		delete(bc.blocks, b.key)
+		bc.used -= int64(len(b.data))
+	}
+}
+
+// readaheadTimeout bounds background fetches, which have no request to
+// take a deadline from. Closing the file stops them sooner.
+const readaheadTimeout = time.Minute
+
+// readState tracks the reads of one open file to detect sequential access.
+type readState struct {
+	lock    sync.Mutex
+	nextOff int64
+	// window is how many blocks past the current read are prefetched. It
+	// doubles with every sequential read, up to the configured maximum, and
+	// drops back to zero on a seek.
+	window int64
+}
+
+// advance records a read of size bytes at off and returns the readahead
+// window to use after it.
+func (rs *readState) advance(off int64, size int, max int64) int64 {
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+	if off == rs.nextOff {
+		switch {
+		case rs.window == 0:
+			rs.window = 1
+		case rs.window < max:
+			rs.window *= 2
+		}
+		if rs.window > max {
+			rs.window = max
+		}
+	} else {
+		rs.window = 0
+	}
+	rs.nextOff = off + int64(size)
+	return rs.window
+}
@@ -0,0 +1,150 @@
+package fscore
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func newTestBlockCache(size int64) *blockCache {
+	return newBlockCache(&CacheConfig{BlockCacheSize: size})
+}
+
+func key(index int64) blockKey {
+	return blockKey{login: "alice", path: "/f", ver: fileVersion{mtime: 1, size: 10 * blockSize}, index: index}
+}
+
+// block returns a load function for a block of n bytes.
+func block(n int) func() ([]byte, error) {
+	return func() ([]byte, error) { return make([]byte, n), nil }
+}
+
+func TestBlockCacheLRU(t *testing.T) {
+	bc := newTestBlockCache(3 * blockSize)
+	for i := int64(0); i < 3; i++ {
+		bc.get(key(i), block(blockSize))
+	}
+	// 0 is now the most recently used, so 1 goes first
+	bc.get(key(0), block(blockSize))
+	bc.get(key(3), block(blockSize))
+	for i, want := range []bool{true, false, true, true} {
+		if _, ok := bc.peek(key(int64(i))); ok != want {
+			t.Errorf("block %d cached: %v, want %v", i, ok, want)
+		}
+	}
+	if bc.used != 3*blockSize {
+		t.Errorf("%d bytes used, want %d", bc.used, 3*blockSize)
+	}
+
+	// a larger block evicts as many as it takes
+	bc.get(key(4), block(2*blockSize))
+	if bc.used > bc.cfg.BlockCacheSize || bc.lru.Len() != 2 {
+		t.Errorf("%d blocks, %d bytes after adding a double block", bc.lru.Len(), bc.used)
+	}
+}
+
+func TestBlockCacheVersions(t *testing.T) {
+	bc := newTestBlockCache(10 * blockSize)
+	bc.get(key(0), func() ([]byte, error) { return []byte("old"), nil })
+	k := key(0)
+	k.ver.mtime++
+	b, _ := bc.get(k, func() ([]byte, error) { return []byte("new"), nil })
+	if string(b) != "new" {
+		t.Errorf("block of the modified file is %q", b)
+	}
+}
+
+func TestBlockCacheErrorsNotCached(t *testing.T) {
+	bc := newTestBlockCache(10 * blockSize)
+	errFetch := errors.New("fetch failed")
+	if _, err := bc.get(key(0), func() ([]byte, error) { return nil, errFetch }); err != errFetch {
+		t.Fatalf("got %v, want %v", err, errFetch)
+	}
+	if bc.has(key(0)) {
+		t.Error("a failed fetch was cached")
+	}
+	if b, err := bc.get(key(0), block(5)); err != nil || len(b) != 5 {
+		t.Errorf("fetch after a failure: %d bytes, %v", len(b), err)
+	}
+}
+
+func TestBlockCacheLoadsOnce(t *testing.T) {
+	bc := newTestBlockCache(10 * blockSize)
+	var loads int32
+	release := make(chan struct{})
+	load := func() ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return []byte("data"), nil
+	}
+
+	const readers = 10
+	var wg sync.WaitGroup
+	results := make(chan string, readers)
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b, err := bc.get(key(0), load)
+			if err != nil {
+				t.Error(err)
+			}
+			results <- string(b)
+		}()
+	}
+	// let the first fetch start, then the others find it in progress
+	for atomic.LoadInt32(&loads) == 0 {
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+	close(results)
+	for r := range results {
+		if r != "data" {
+			t.Errorf("reader got %q", r)
+		}
+	}
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Errorf("block fetched %d times", n)
+	}
+}
+
+func TestReadStateAdvance(t *testing.T) {
+	var rs readState
+	const max = 8
+	steps := []struct {
+		off, size int64
+		want      int64
+	}{
+		{0, 100, 1},
+		{100, 100, 2},
+		{200, 100, 4},
+		{300, 100, 8},
+		{400, 100, 8},
+		// a seek starts over
+		{1000, 100, 0},
+		{1100, 100, 1},
+		{1200, 100, 2},
+		// so does reading the same data again
+		{1200, 100, 0},
+		{1300, 50, 1},
+		{1350, 50, 2},
+	}
+	for _, s := range steps {
+		if got := rs.advance(s.off, int(s.size), max); got != s.want {
+			t.Errorf("read at %d: window %d, want %d", s.off, got, s.want)
+		}
+	}
+
+	// a maximum that isn't a power of two is still respected
+	rs = readState{}
+	var got int64
+	for off := int64(0); off < 10; off++ {
+		got = rs.advance(off, 1, 5)
+	}
+	if got != 5 {
+		t.Errorf("window grew to %d, want 5", got)
+	}
+}
@@ -0,0 +1,231 @@
+package fscore
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"bazil.org/fuse"
+	fgrpc "github.com/riking/42fs/grpc"
+)
+
+// CacheConfig sets how long metadata from other users' daemons is trusted,
+// both by the kernel and by the per-UserDir cache, and how much of their
+// files' contents is kept. A zero value turns that kind of caching off.
+type CacheConfig struct {
+	// AttrTTL covers file attributes and positive lookups.
+	AttrTTL time.Duration
+	// NegativeTTL covers lookups of names that don't exist.
+	NegativeTTL time.Duration
+	// DirTTL covers directory listings.
+	DirTTL time.Duration
+
+	// BlockCacheSize is how many bytes of file contents are kept in memory.
+	BlockCacheSize int64
+	// Readahead is how far ahead of sequential reads contents are fetched,
+	// in bytes.
+	Readahead int64
+
+	// DiskCacheDir is where files that were read completely are kept
+	// between runs, up to DiskCacheSize bytes. Files are copied there from
+	// the block cache, so larger files than BlockCacheSize never are.
+	// Copies are checked against the owner's daemon with Stat whenever the
+	// file is opened.
+	DiskCacheDir  string
+	DiskCacheSize int64
+}
+
+var DefaultCacheConfig = CacheConfig{
+	AttrTTL:     5 * time.Second,
+	NegativeTTL: 2 * time.Second,
+	DirTTL:      5 * time.Second,
+
+	BlockCacheSize: 64 << 20,
+	Readahead:      4 << 20,
+
+	DiskCacheSize: 1 << 30,
+}
+
+// metaCacheMax is the number of entries after which expired ones are swept
+// out on insertion.
+const metaCacheMax = 4096
+
+type cachedAttr struct {
+	attr    fgrpc.FileAttr
+	expires time.Time
+}
+
+type cachedDir struct {
+	ents    []fgrpc.Dirent
+	expires time.Time
+}
+
+// metaCache holds the metadata of one UserDir, keyed by RemoteNode.Path.
+type metaCache struct {
+	cfg *CacheConfig
+
+	lock     sync.Mutex
+	attrs    map[string]cachedAttr
+	negative map[string]time.Time
+	dirs     map[string]cachedDir
+}
+
+func newMetaCache(cfg *CacheConfig) *metaCache {
+	c := &metaCache{cfg: cfg}
+	c.reset()
+	return c
+}
+
+// reset drops everything, for when the connection behind the UserDir
+// changes.
+func (c *metaCache) reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.attrs = make(map[string]cachedAttr)
+	c.negative = make(map[string]time.Time)
+	c.dirs = make(map[string]cachedDir)
+}
+
+// sweep removes expired entries. It must be called with the lock held.
+func (c *metaCache) sweep(now time.Time) {
+	for p, a := range c.attrs {
+		if now.After(a.expires) {
+			delete(c.attrs, p)
+		}
+	}
+	for p, exp := range c.negative {
+		if now.After(exp) {
+			delete(c.negative, p)
+		}
+	}
+	for p, d := range c.dirs {
+		if now.After(d.expires) {
+			delete(c.dirs, p)
+		}
+	}
+}
+
+func (c *metaCache) attr(p string) (*fgrpc.FileAttr, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	a, ok := c.attrs[p]
+	if !ok || time.Now().After(a.expires) {
+		return nil, false
+	}
+	return &a.attr, true
+}
+
+func (c *metaCache) putAttr(p string, attr *fgrpc.FileAttr) {
+	if c.cfg.AttrTTL <= 0 {
+		return
+	}
+	now := time.Now()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.attrs) >= metaCacheMax {
+		c.sweep(now)
+	}
+	c.attrs[p] = cachedAttr{attr: *attr, expires: now.Add(c.cfg.AttrTTL)}
+	delete(c.negative, p)
+}
+
+// missing reports whether p is known not to exist, either from a failed
+// lookup or from a listing of its parent.
+func (c *metaCache) missing(p string) bool {
+	now := time.Now()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if exp, ok := c.negative[p]; ok && now.Before(exp) {
+		return true
+	}
+	// paths are built by RemoteNode.Join, so the root is ""
+	i := strings.LastIndex(p, "/")
+	d, ok := c.dirs[p[:i]]
+	if !ok || now.After(d.expires) {
+		return false
+	}
+	name := p[i+1:]
+	for _, e := range d.ents {
+		if e.Name == name {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *metaCache) putMissing(p string) {
+	if c.cfg.NegativeTTL <= 0 {
+		return
+	}
+	now := time.Now()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.negative) >= metaCacheMax {
+		c.sweep(now)
+	}
+	c.negative[p] = now.Add(c.cfg.NegativeTTL)
+	delete(c.attrs, p)
+}
+
+func (c *metaCache) dir(p string) ([]fgrpc.Dirent, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	d, ok := c.dirs[p]
+	if !ok || time.Now().After(d.expires) {
+		return nil, false
+	}
+	return d.ents, true
+}
+
+func (c *metaCache) putDir(p string, ents []fgrpc.Dirent) {
+	if c.cfg.DirTTL <= 0 {
+		return
+	}
+	now := time.Now()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.dirs) >= metaCacheMax {
+		c.sweep(now)
+	}
+	c.dirs[p] = cachedDir{ents: ents, expires: now.Add(c.cfg.DirTTL)}
+}
+
+// isNotExist reports whether err, as returned by a UserConnection, means the
+// file doesn't exist.
+func isNotExist(err error) bool {
+	en, ok := err.(fuse.ErrorNumber)
+	return ok && en.Errno() == fuse.ENOENT
+}
+
+// invalidate drops what is cached about p. If entry is set, p was created,
+// removed or renamed, so the listing of its parent and anything cached below
+// it go too.
+func (c *metaCache) invalidate(p string, entry bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.attrs, p)
+	delete(c.negative, p)
+	delete(c.dirs, p)
+	if !entry {
+		return
+	}
+	if i := strings.LastIndex(p, "/"); i >= 0 {
+		delete(c.dirs, p[:i])
+	}
+	prefix := p + "/"
+	for q := range c.attrs {
+		if strings.HasPrefix(q, prefix) {
+			delete(c.attrs, q)
+		}
+	}
+	for q := range c.negative {
+		if strings.HasPrefix(q, prefix) {
+			delete(c.negative, q)
+		}
+	}
+	for q := range c.dirs {
+		if strings.HasPrefix(q, prefix) {
+			delete(c.dirs, q)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package fscore
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	fgrpc "github.com/riking/42fs/grpc"
+)
+
+func newTestMetaCache() *metaCache {
+	cfg := DefaultCacheConfig
+	return newMetaCache(&cfg)
+}
+
+// expire makes everything cached about p look stale.
+func (c *metaCache) expire(p string) {
+	past := time.Now().Add(-time.Second)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if a, ok := c.attrs[p]; ok {
+		a.expires = past
+		c.attrs[p] = a
+	}
+	if _, ok := c.negative[p]; ok {
+		c.negative[p] = past
+	}
+	if d, ok := c.dirs[p]; ok {
+		d.expires = past
+		c.dirs[p] = d
+	}
+}
+
+func dirents(names ...string) []fgrpc.Dirent {
+	ents := make([]fgrpc.Dirent, len(names))
+	for i, n := range names {
+		ents[i].Name = n
+	}
+	return ents
+}
+
+func TestMetaCacheExpiry(t *testing.T) {
+	c := newTestMetaCache()
+	c.putAttr("/a", &fgrpc.FileAttr{Size: 3})
+	c.putMissing("/b")
+	c.putDir("/d", dirents("x"))
+	if a, ok := c.attr("/a"); !ok || a.Size != 3 {
+		t.Errorf("attr: %+v, %v", a, ok)
+	}
+	if !c.missing("/b") {
+		t.Error("negative lookup not cached")
+	}
+	if _, ok := c.dir("/d"); !ok {
+		t.Error("listing not cached")
+	}
+
+	for _, p := range []string{"/a", "/b", "/d"} {
+		c.expire(p)
+	}
+	if _, ok := c.attr("/a"); ok {
+		t.Error("expired attr returned")
+	}
+	if c.missing("/b") {
+		t.Error("expired negative lookup returned")
+	}
+	if _, ok := c.dir("/d"); ok {
+		t.Error("expired listing returned")
+	}
+	if c.missing("/d/y") {
+		t.Error("expired listing used for a negative lookup")
+	}
+}
+
+func TestMetaCacheDisabled(t *testing.T) {
+	c := newMetaCache(&CacheConfig{})
+	c.putAttr("/a", &fgrpc.FileAttr{})
+	c.putMissing("/b")
+	c.putDir("", dirents("a"))
+	if _, ok := c.attr("/a"); ok {
+		t.Error("attr cached with AttrTTL 0")
+	}
+	if c.missing("/b") {
+		t.Error("negative lookup cached with NegativeTTL 0")
+	}
+	if _, ok := c.dir(""); ok {
+		t.Error("listing cached with DirTTL 0")
+	}
+}
+
+func TestMetaCacheMissingFromListing(t *testing.T) {
+	c := newTestMetaCache()
+	c.putDir("", dirents("a", "d"))
+	c.putDir("/d", dirents("x"))
+	for p, want := range map[string]bool{
+		"/a":   false,
+		"/b":   true,
+		"/d/x": false,
+		"/d/y": true,
+		// no listing of /e
+		"/e/x": false,
+	} {
+		if got := c.missing(p); got != want {
+			t.Errorf("missing(%s) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestMetaCachePutReplaces(t *testing.T) {
+	c := newTestMetaCache()
+	c.putMissing("/a")
+	c.putAttr("/a", &fgrpc.FileAttr{})
+	if c.missing("/a") {
+		t.Error("a created file is still missing")
+	}
+	c.putMissing("/a")
+	if _, ok := c.attr("/a"); ok {
+		t.Error("a removed file still has attributes")
+	}
+}
+
+func TestMetaCacheInvalidate(t *testing.T) {
+	fill := func() *metaCache {
+		c := newTestMetaCache()
+		c.putDir("", dirents("d", "other"))
+		c.putAttr("/d", &fgrpc.FileAttr{})
+		c.putDir("/d", dirents("f", "sub"))
+		c.putAttr("/d/f", &fgrpc.FileAttr{})
+		c.putDir("/d/sub", dirents("g"))
+		c.putAttr("/d/sub/g", &fgrpc.FileAttr{})
+		c.putMissing("/d/sub/h")
+		c.putAttr("/other", &fgrpc.FileAttr{})
+		c.putAttr("/dd", &fgrpc.FileAttr{})
+		return c
+	}
+
+	// the attributes of d changed
+	c := fill()
+	c.invalidate("/d", false)
+	if _, ok := c.attr("/d"); ok {
+		t.Error("attr of /d kept")
+	}
+	if _, ok := c.dir("/d"); ok {
+		t.Error("listing of /d kept")
+	}
+	if _, ok := c.dir(""); !ok {
+		t.Error("listing of the parent dropped when only attributes changed")
+	}
+	if _, ok := c.attr("/d/f"); !ok {
+		t.Error("child dropped when only attributes changed")
+	}
+
+	// d was removed or renamed
+	c = fill()
+	c.invalidate("/d", true)
+	if _, ok := c.dir(""); ok {
+		t.Error("listing of the parent kept")
+	}
+	for _, p := range []string{"/d", "/d/f", "/d/sub/g"} {
+		if _, ok := c.attr(p); ok {
+			t.Errorf("attr of %s kept", p)
+		}
+	}
+	for _, p := range []string{"/d", "/d/sub"} {
+		if _, ok := c.dir(p); ok {
+			t.Errorf("listing of %s kept", p)
+		}
+	}
+	if c.missing("/d/sub/h") {
+		t.Error("negative lookup below /d kept")
+	}
+	for _, p := range []string{"/other", "/dd"} {
+		if _, ok := c.attr(p); !ok {
+			t.Errorf("attr of %s dropped", p)
+		}
+	}
+}
+
+func TestMetaCacheSweep(t *testing.T) {
+	c := newTestMetaCache()
+	for i := 0; i < metaCacheMax; i++ {
+		p := "/" + strconv.Itoa(i)
+		c.putAttr(p, &fgrpc.FileAttr{})
+		if i%2 == 0 {
+			c.expire(p)
+		}
+	}
+	c.putAttr("/new", &fgrpc.FileAttr{})
+	if n := len(c.attrs); n != metaCacheMax/2+1 {
+		t.Errorf("%d attrs cached after a sweep, want %d", n, metaCacheMax/2+1)
+	}
+}
@@ -0,0 +1,108 @@
+package fscore
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// Control files, such as ACL files, are small files in the public directory
+// through which the owner chooses how it is shared.
+
+// maxControlFileSize bounds how much of a control file is read.
+const maxControlFileSize = 64 << 10
+
+// parsedFile is the contents of one control file, and what it looked like
+// on disk when it was read.
+type parsedFile struct {
+	dev, ino uint64
+	size     int64
+	mtime    unix.Timespec
+	ctime    unix.Timespec
+	value    interface{}
+}
+
+func (f *parsedFile) matches(st *unix.Stat_t) bool {
+	mtime, ctime := changeTimes(st)
+	return f.dev == uint64(st.Dev) && f.ino == uint64(st.Ino) && f.size == st.Size &&
+		f.mtime == mtime && f.ctime == ctime
+}
+
+// controlFiles keeps the control files read from a LocalDir. They are
+// checked on every use but only parsed again when they change.
+type controlFiles struct {
+	lock  sync.Mutex
+	files map[string]*parsedFile // by path relative to the root
+}
+
+// load returns what parse made of the control file rel, or nil if there is
+// none. Anything but a regular file is ignored.
+func (c *controlFiles) load(md *LocalDir, rel string, parse func(io.Reader) interface{}) interface{} {
+	var st unix.Stat_t
+	err := md.lstat(rel, &st)
+	if err != nil || st.Mode&unix.S_IFMT != unix.S_IFREG {
+		c.lock.Lock()
+		delete(c.files, rel)
+		c.lock.Unlock()
+		return nil
+	}
+	c.lock.Lock()
+	f, ok := c.files[rel]
+	c.lock.Unlock()
+	if ok && f.matches(&st) {
+		return f.value
+	}
+
+	fd, err := md.open(rel, unix.O_RDONLY, 0)
+	if err != nil {
+		return nil
+	}
+	file := os.NewFile(uintptr(fd), rel)
+	defer file.Close()
+	// stat the file that was read, which may have been replaced since
+	err = unix.Fstat(fd, &st)
+	if err != nil || st.Mode&unix.S_IFMT != unix.S_IFREG {
+		return nil
+	}
+	var buf bytes.Buffer
+	_, err = buf.ReadFrom(io.LimitReader(file, maxControlFileSize))
+	if err != nil {
+		return nil
+	}
+	f = &parsedFile{
+		dev:   uint64(st.Dev),
+		ino:   uint64(st.Ino),
+		size:  st.Size,
+		value: parse(&buf),
+	}
+	f.mtime, f.ctime = changeTimes(&st)
+	c.lock.Lock()
+	c.files[rel] = f
+	c.lock.Unlock()
+	return f.value
+}
+
+// isControlFile reports whether name is the name of a control file.
+func isControlFile(name string) bool {
+	return name == aclFile || name == symlinkPolicyFile || name == ignoreFile
+}
+
+// controlWords splits a control file into words, separated by spaces or
+// newlines. Lines starting with # are comments.
+func controlWords(r io.Reader) []string {
+	var words []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, strings.Fields(line)...)
+	}
+	return words
+}
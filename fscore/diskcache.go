@@ -0,0 +1,186 @@
+package fscore
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tmpPrefix marks files being written into the disk cache. Leftovers from a
+// crash are removed on startup.
+const tmpPrefix = ".tmp-"
+
+// diskCache keeps whole files read from other users' directories under
+// CacheConfig.DiskCacheDir, so they survive restarts of the daemon. Each
+// file is named after the login, path and version it was read at, and the
+// modification times of the cache files keep the LRU order.
+type diskCache struct {
+	cfg *CacheConfig
+
+	lock    sync.Mutex
+	started bool
+	dir     string
+	used    int64
+	lru     *list.List // of *diskEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type diskEntry struct {
+	name string
+	size int64
+}
+
+func newDiskCache(cfg *CacheConfig) *diskCache {
+	return &diskCache{
+		cfg:     cfg,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func diskCacheName(login, p string, ver fileVersion) string {
+	h := sha256.New()
+	h.Write([]byte(login + "\x00" + p + "\x00" +
+		strconv.FormatInt(ver.mtime, 10) + "\x00" + strconv.FormatUint(ver.size, 10)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// start loads the contents of the cache directory the first time the cache
+// is used. It must be called with the lock held and reports whether the
+// cache is usable.
+func (dc *diskCache) start() bool {
+	if dc.started {
+		return dc.dir != ""
+	}
+	dc.started = true
+	dir := dc.cfg.DiskCacheDir
+	if dir == "" || dc.cfg.DiskCacheSize <= 0 {
+		return false
+	}
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		log.Println("disk cache disabled:", err)
+		return false
+	}
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		log.Println("disk cache disabled:", err)
+		return false
+	}
+	// oldest last
+	sort.Slice(fis, func(i, j int) bool {
+		return fis[i].ModTime().After(fis[j].ModTime())
+	})
+	for _, fi := range fis {
+		if strings.HasPrefix(fi.Name(), tmpPrefix) {
+			os.Remove(filepath.Join(dir, fi.Name()))
+			continue
+		}
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		e := &diskEntry{name: fi.Name(), size: fi.Size()}
+		dc.entries[e.name] = dc.lru.PushBack(e)
+		dc.used += e.size
+	}
+	dc.dir = dir
+	dc.evict()
+	return true
+}
+
+func (dc *diskCache) enabled() bool {
+	dc.lock.Lock()
+	defer dc.lock.Unlock()
+	return dc.start()
+}
+
+// open returns the cached copy of a file, or nil if there is none.
+func (dc *diskCache) open(login, p string, ver fileVersion) *os.File {
+	name := diskCacheName(login, p, ver)
+	dc.lock.Lock()
+	defer dc.lock.Unlock()
+	if !dc.start() {
+		return nil
+	}
+	el, ok := dc.entries[name]
+	if !ok {
+		return nil
+	}
+	full := filepath.Join(dc.dir, name)
+	f, err := os.Open(full)
+	if err != nil {
+		dc.remove(el)
+		return nil
+	}
+	dc.lru.MoveToFront(el)
+	now := time.Now()
+	os.Chtimes(full, now, now)
+	return f
+}
+
+// put stores the contents of a file that was read completely.
+func (dc *diskCache) put(login, p string, ver fileVersion, data []byte) error {
+	name := diskCacheName(login, p, ver)
+	dc.lock.Lock()
+	if !dc.start() || int64(len(data)) > dc.cfg.DiskCacheSize {
+		dc.lock.Unlock()
+		return nil
+	}
+	_, ok := dc.entries[name]
+	dir := dc.dir
+	dc.lock.Unlock()
+	if ok {
+		return nil
+	}
+
+	tmp, err := ioutil.TempFile(dir, tmpPrefix)
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cErr := tmp.Close(); err == nil {
+		err = cErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(dir, name))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	dc.lock.Lock()
+	defer dc.lock.Unlock()
+	if _, ok := dc.entries[name]; !ok {
+		dc.entries[name] = dc.lru.PushFront(&diskEntry{name: name, size: int64(len(data))})
+		dc.used += int64(len(data))
+	}
+	dc.evict()
+	return nil
+}
+
+// evict removes the least recently used files until the cache fits its
+// quota. It must be called with the lock held.
+func (dc *diskCache) evict() {
+	for dc.used > dc.cfg.DiskCacheSize && dc.lru.Len() > 0 {
+		el := dc.lru.Back()
+		os.Remove(filepath.Join(dc.dir, el.Value.(*diskEntry).name))
+		dc.remove(el)
+	}
+}
+
+func (dc *diskCache) remove(el *list.Element) {
+	e := el.Value.(*diskEntry)
+	dc.lru.Remove(el)
+	delete(dc.entries, e.name)
+	dc.used -= e.size
+}
@@ -0,0 +1,116 @@
+package fscore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestDiskCache(t *testing.T, size int64) *diskCache {
+	dir, err := ioutil.TempDir("", "42fs-diskcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return newDiskCache(&CacheConfig{DiskCacheDir: dir, DiskCacheSize: size})
+}
+
+var v1 = fileVersion{mtime: 1, size: 10}
+
+// cached returns the cached contents of p at v1, or "" if there are none.
+func (dc *diskCache) cached(t *testing.T, p string) string {
+	f := dc.open("alice", p, v1)
+	if f == nil {
+		return ""
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func (dc *diskCache) mustPut(t *testing.T, p, data string) {
+	if err := dc.put("alice", p, v1, []byte(data)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiskCachePutOpen(t *testing.T) {
+	dc := newTestDiskCache(t, 100)
+	dc.mustPut(t, "/f", "0123456789")
+	if got := dc.cached(t, "/f"); got != "0123456789" {
+		t.Errorf("cached copy is %q", got)
+	}
+	if f := dc.open("alice", "/f", fileVersion{mtime: 2, size: 10}); f != nil {
+		f.Close()
+		t.Error("copy of another version returned")
+	}
+	if f := dc.open("bob", "/f", v1); f != nil {
+		f.Close()
+		t.Error("copy of another user's file returned")
+	}
+	// larger than the whole cache
+	dc.mustPut(t, "/big", string(make([]byte, 101)))
+	if dc.cached(t, "/big") != "" {
+		t.Error("file over the quota was cached")
+	}
+}
+
+func TestDiskCacheEviction(t *testing.T) {
+	dc := newTestDiskCache(t, 25)
+	dc.mustPut(t, "/a", "aaaaaaaaaa")
+	dc.mustPut(t, "/b", "bbbbbbbbbb")
+	// a is now the most recently used
+	dc.cached(t, "/a")
+	dc.mustPut(t, "/c", "cccccccccc")
+	for p, want := range map[string]bool{"/a": true, "/b": false, "/c": true} {
+		if got := dc.cached(t, p) != ""; got != want {
+			t.Errorf("%s cached: %v, want %v", p, got, want)
+		}
+	}
+	names, _ := ioutil.ReadDir(dc.dir)
+	if len(names) != 2 || dc.used != 20 {
+		t.Errorf("%d files, %d bytes in the cache after eviction", len(names), dc.used)
+	}
+}
+
+func TestDiskCacheRestart(t *testing.T) {
+	dc := newTestDiskCache(t, 100)
+	dc.mustPut(t, "/old", "oooooooooo")
+	dc.mustPut(t, "/new", "nnnnnnnnnn")
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(dc.dir, diskCacheName("alice", "/old", v1)), old, old)
+	leftover := filepath.Join(dc.dir, tmpPrefix+"123")
+	if err := ioutil.WriteFile(leftover, []byte("partial"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// restarted with a smaller quota
+	cfg := *dc.cfg
+	cfg.DiskCacheSize = 15
+	dc = newDiskCache(&cfg)
+	if got := dc.cached(t, "/new"); got != "nnnnnnnnnn" {
+		t.Errorf("cached copy after a restart is %q", got)
+	}
+	if dc.cached(t, "/old") != "" {
+		t.Error("the least recently used file was kept over the quota")
+	}
+	if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+		t.Errorf("leftover temporary file: %v", err)
+	}
+}
+
+func TestDiskCacheDisabled(t *testing.T) {
+	dc := newDiskCache(&CacheConfig{DiskCacheSize: 100})
+	if dc.enabled() {
+		t.Error("enabled without a directory")
+	}
+	dc.mustPut(t, "/f", "x")
+	if dc.cached(t, "/f") != "" {
+		t.Error("file cached without a directory")
+	}
+}
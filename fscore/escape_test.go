@@ -0,0 +1,259 @@
+package fscore_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"github.com/riking/42fs/fs42test"
+	"github.com/riking/42fs/fscore"
+	fgrpc "github.com/riking/42fs/grpc"
+)
+
+// secret is kept next to the public directory, where nothing may reach it.
+const secret = "outside the public directory"
+
+var policies = []fscore.SymlinkPolicy{fscore.SymlinksHide, fscore.SymlinksResolve, fscore.SymlinksServe}
+
+// escapeFixture is a public directory with symlinks and paths out of it,
+// next to the directory they lead to.
+type escapeFixture struct {
+	c     *fs42test.Coordinator
+	alice *fs42test.User
+	// outside holds secret in key
+	outside string
+	// escapes are paths in alice's directory that lead to outside/key
+	escapes []string
+	readers int
+}
+
+func newEscapeFixture(t *testing.T) *escapeFixture {
+	outside, err := ioutil.TempDir("", "42fs-outside")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(outside) })
+	err = os.Chmod(outside, 0777)
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(outside, "key"), []byte(secret), 0644)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := fs42test.NewCoordinator()
+	alice := c.AddUser(t, "alice")
+	alice.FS42.Dropbox = "box"
+	alice.Mkdir(t, "box", 0777)
+	alice.Mkdir(t, "real", 0755)
+	alice.WriteFile(t, "real/f", "inside", 0644)
+	if filepath.Dir(alice.Dir) != filepath.Dir(outside) {
+		t.Fatalf("%s and %s are not in the same directory", alice.Dir, outside)
+	}
+	// from the public directory
+	up := "../" + filepath.Base(outside)
+	links := map[string]string{
+		"abs":       outside + "/key",
+		"absdir":    outside,
+		"up":        up + "/key",
+		"updir":     up,
+		"real/upup": "../" + up + "/key",
+		"box/evil":  outside,
+	}
+	for name, target := range links {
+		err := os.Symlink(target, filepath.Join(alice.Dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &escapeFixture{
+		c:       c,
+		alice:   alice,
+		outside: outside,
+		escapes: []string{
+			"abs", "absdir/key", "up", "updir/key", "real/upup", "box/evil/key",
+			up + "/key", "real/../" + up + "/key",
+		},
+	}
+}
+
+// reader adds a user who hasn't looked at alice's directory yet, so that
+// nothing is cached.
+func (f *escapeFixture) reader(t *testing.T) *fs42test.User {
+	f.readers++
+	return f.c.AddUser(t, fmt.Sprintf("reader%d", f.readers))
+}
+
+// checkOutsideUntouched fails if anything but key was created outside.
+func (f *escapeFixture) checkOutsideUntouched(t *testing.T) {
+	t.Helper()
+	infos, err := ioutil.ReadDir(f.outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range infos {
+		if fi.Name() != "key" {
+			t.Errorf("%s was created outside the public directory", fi.Name())
+		}
+	}
+}
+
+// eachWalk runs test with openat2(2), where the kernel has it, and with the
+// fallback that opens one name at a time.
+func eachWalk(t *testing.T, test func(t *testing.T)) {
+	t.Run("openat2", test)
+	t.Run("walk", func(t *testing.T) {
+		defer fscore.WithoutOpenat2()()
+		test(t)
+	})
+}
+
+// readPeer reads p in owner's directory over a connection from login.
+func readPeer(ps *fscore.PeerServer, login, p string) ([]byte, error) {
+	ctx := context.Background()
+	dc := fs42test.NewDirConn(ps, login)
+	_, fd, err := dc.Open(ctx, p, false, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer dc.Close(ctx, fd)
+	return dc.ReadFrom(ctx, &fgrpc.ReadRequest{FD: fd, Size: fgrpc.ChunkSize})
+}
+
+func TestEscapes(t *testing.T) {
+	eachWalk(t, func(t *testing.T) {
+		f := newEscapeFixture(t)
+		for _, policy := range policies {
+			f.alice.FS42.Symlinks = policy
+			owner := f.alice.VFS(t)
+			peer := f.reader(t).VFS(t)
+			ps := fscore.NewPeerServer(f.alice.FS42)
+			for _, p := range f.escapes {
+				if b, err := owner.ReadFile("alice/" + p); err == nil {
+					t.Errorf("%v: alice read %q from %s", policy, b, p)
+				}
+				if b, err := peer.ReadFile("alice/" + p); err == nil {
+					t.Errorf("%v: a peer read %q from %s", policy, b, p)
+				}
+				if b, err := readPeer(ps, "bob", "/"+p); err == nil && string(b) == secret {
+					t.Errorf("%v: a peer connection read %s", policy, p)
+				}
+				// served symlinks can be looked at, not through
+				if a, err := peer.Stat("alice/" + p); err == nil && a.Mode&os.ModeSymlink == 0 {
+					t.Errorf("%v: a peer found a %v at %s", policy, a.Mode, p)
+				}
+			}
+
+			if err := owner.WriteFile("alice/absdir/planted", []byte("x"), 0644); err == nil {
+				t.Errorf("%v: alice wrote through absdir", policy)
+			}
+			if err := owner.WriteFile("alice/updir/planted", []byte("x"), 0644); err == nil {
+				t.Errorf("%v: alice wrote through updir", policy)
+			}
+			if err := peer.WriteFile("alice/box/evil/planted", []byte("x"), 0644); err == nil {
+				t.Errorf("%v: a peer wrote through box/evil", policy)
+			}
+			f.checkOutsideUntouched(t)
+		}
+	})
+}
+
+func TestDotDotNames(t *testing.T) {
+	eachWalk(t, func(t *testing.T) {
+		f := newEscapeFixture(t)
+		f.c.AddUser(t, "bob")
+		owner := f.alice.VFS(t)
+		peer := f.reader(t).VFS(t)
+		for _, p := range []string{"alice/..", "alice/../bob", "alice/real/..", "alice/real/../..", "alice/real/../../bob"} {
+			if _, err := owner.Stat(p); err == nil {
+				t.Errorf("alice looked up %s", p)
+			}
+			if _, err := peer.Stat(p); err == nil {
+				t.Errorf("a peer looked up %s", p)
+			}
+		}
+
+		// peers' paths are taken relative to the public directory, which
+		// has no parent
+		ctx := context.Background()
+		dc := fs42test.NewDirConn(fscore.NewPeerServer(f.alice.FS42), "bob")
+		a, err := dc.Stat(ctx, "/../../real/f")
+		if err != nil || a.Size != uint64(len("inside")) {
+			t.Errorf("/../../real/f is %d bytes: %v", a.Size, err)
+		}
+		root, _ := dc.Stat(ctx, "/")
+		up, err := dc.Stat(ctx, "..")
+		if err != nil || up.INode != root.INode {
+			t.Errorf(".. is not the public directory: %v", err)
+		}
+	})
+}
+
+// lookupIn looks name up in the directory node n, as the kernel would.
+func lookupIn(n fs.Node, name string) (fs.Node, error) {
+	ctx := context.Background()
+	switch d := n.(type) {
+	case fs.NodeRequestLookuper:
+		return d.Lookup(ctx, &fuse.LookupRequest{Name: name}, &fuse.LookupResponse{})
+	case fs.NodeStringLookuper:
+		return d.Lookup(ctx, name)
+	}
+	return nil, fuse.ENOENT
+}
+
+func TestDirectorySwappedForSymlink(t *testing.T) {
+	eachWalk(t, func(t *testing.T) {
+		f := newEscapeFixture(t)
+		f.alice.Mkdir(t, "d", 0755)
+		f.alice.WriteFile(t, "d/f", "inside", 0644)
+		owner := f.alice.VFS(t)
+		dir, err := owner.OpenDir("alice/d")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer dir.Close()
+		ctx := context.Background()
+		dc := fs42test.NewDirConn(fscore.NewPeerServer(f.alice.FS42), "bob")
+		_, fd, err := dc.Open(ctx, "/d", true, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer dc.Close(ctx, fd)
+
+		// d is replaced while it is open
+		d := filepath.Join(f.alice.Dir, "d")
+		if err := os.Rename(d, d+".old"); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(f.outside, d); err != nil {
+			t.Fatal(err)
+		}
+
+		if n, err := lookupIn(dir.Node(), "key"); err == nil {
+			t.Errorf("looked key up in the swapped directory: %v", n)
+		}
+		ents, err := dir.ReadDir()
+		for _, e := range ents {
+			if e.Name == "key" {
+				t.Errorf("the swapped directory lists key: %v", err)
+			}
+		}
+		if _, err := owner.ReadFile("alice/d/key"); err == nil {
+			t.Error("alice read key through the swapped directory")
+		}
+		if b, err := readPeer(fscore.NewPeerServer(f.alice.FS42), "bob", "/d/key"); err == nil {
+			t.Errorf("a peer read %q through the swapped directory", b)
+		}
+		pents, _ := dc.ReadDir(ctx, fd)
+		for _, e := range pents {
+			if e.Name == "key" {
+				t.Error("a peer's open directory lists key after the swap")
+			}
+		}
+	})
+}
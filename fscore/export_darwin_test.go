@@ -0,0 +1,7 @@
+package fscore
+
+// WithoutOpenat2 does nothing, as openBeneath always walks paths one name
+// at a time on darwin.
+func WithoutOpenat2() (restore func()) {
+	return func() {}
+}
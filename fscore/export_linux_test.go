@@ -0,0 +1,10 @@
+package fscore
+
+import "sync/atomic"
+
+// WithoutOpenat2 makes openBeneath walk paths one name at a time, as it does
+// on kernels without openat2(2), until restore is called.
+func WithoutOpenat2() (restore func()) {
+	old := atomic.SwapInt32(&noOpenat2, 1)
+	return func() { atomic.StoreInt32(&noOpenat2, old) }
+}
@@ -3,12 +3,14 @@ package fscore
 import (
 	"os"
 
+	"github.com/riking/42fs/auth"
 	fgrpc "github.com/riking/42fs/grpc"
 
 	"bazil.org/fuse"
 	"bazil.org/fuse/fs"
 	"golang.org/x/net/context"
-	"fmt"
+	"log"
+	"sync"
 )
 
 const (
@@ -20,19 +22,55 @@ const (
 )
 
 type FS42 struct {
+	// OnlineOnly leaves users whose daemon isn't running out of the root
+	// directory listing. They can still be looked up by name.
+	OnlineOnly bool
+	// Dropbox is a directory, relative to the public directory, in which
+	// other users may create files, like in a world-writable directory with
+	// the sticky bit set. Empty means peers can't write anywhere. It has to
+	// be on a filesystem that supports user extended attributes, which
+	// record who created each file.
+	Dropbox string
+	// Groups names sets of logins, which ACL files can grant access to as
+	// @name.
+	Groups map[string][]string
+	// Symlinks chooses how symlinks in the public directory are shown. A
+	// .42fs-symlinks file in the public directory overrides it.
+	Symlinks SymlinkPolicy
+	// Cache sets how long metadata of other users' files is cached. It must
+	// be set before the filesystem is served.
+	Cache CacheConfig
+
 	coordCur   fgrpc.CoordinatorServer
-	myLogin    string
+	session    *auth.Session
 	myRealPath string
 	root       RootDir
 	local      *LocalDir
+	peers      *peerPool
+	inodes     *inodeMap
+	blocks     *blockCache
+	disk       *diskCache
+	// server is set by Serve before any request is handled
+	server     *fs.Server
+
+	userLock sync.Mutex
+	userDirs map[string]*UserDir
 }
 
-func NewFS42(coord fgrpc.CoordinatorServer, myLogin string, myDir string) *FS42 {
+// NewFS42 creates the filesystem for the owner identified by session, whose
+// public directory is myDir.
+func NewFS42(coord fgrpc.CoordinatorServer, session *auth.Session, myDir string) *FS42 {
 	fs42 := &FS42{
-		coordCur:      coord,
-		myLogin:    myLogin,
+		coordCur:   coord,
+		session:    session,
 		myRealPath: myDir,
+		Cache:      DefaultCacheConfig,
+		peers:      newPeerPool(session),
+		inodes:     newINodeMap(),
+		userDirs:   make(map[string]*UserDir),
 	}
+	fs42.blocks = newBlockCache(&fs42.Cache)
+	fs42.disk = newDiskCache(&fs42.Cache)
 	fs42.root = RootDir{fs42: fs42}
 	fs42.local = NewLocalDir(fs42, myDir)
 	return fs42
@@ -42,11 +80,48 @@ func (fs42 *FS42) Root() (fs.Node, error) {
 	return fs42.root, nil
 }
 
+// WhoAmI returns the owner's login as verified by the coordinator.
 func (fs42 *FS42) WhoAmI() string {
-	return fs42.myLogin
+	id := fs42.session.Identity()
+	if id == nil {
+		return ""
+	}
+	return id.Login
+}
+
+// LocalINode returns the on-disk inode number of the public directory.
+func (fs42 *FS42) LocalINode(ctx context.Context) (uint64, error) {
+	var a fuse.Attr
+	_, err := fs42.local.statAttr(&a)
+	if err != nil {
+		return 0, err
+	}
+	return a.Inode, nil
 }
 
 func (fs42 *FS42) coord() fgrpc.CoordinatorServer {
+	return fs42.coordCur
+}
+
+// Serve serves the filesystem on conn until it is unmounted. Unlike
+// fs.Serve, it lets changes reported by other users' daemons invalidate what
+// the kernel has cached.
+func (fs42 *FS42) Serve(conn *fuse.Conn) error {
+	fs42.server = fs.New(conn, nil)
+	return fs42.server.Serve(fs42)
+}
+
+// Close stops following changes, drops the connections to peer daemons and
+// closes the owner's files that are still open. It must only be called once
+// the filesystem is no longer served, to peers or to the kernel.
+func (fs42 *FS42) Close() error {
+	fs42.userLock.Lock()
+	for _, ud := range fs42.userDirs {
+		ud.unfollow()
+	}
+	fs42.userLock.Unlock()
+	fs42.peers.closeAll()
+	fs42.local.closeFiles()
 	return nil
 }
 
@@ -61,13 +136,14 @@ func (d RootDir) Attr(ctx context.Context, a *fuse.Attr) error {
 }
 
 func (d RootDir) Lookup(ctx context.Context, name string) (fs.Node, error) {
-	fmt.Println(d)
 	if name == "README" {
 		return ReadmeFile{}, nil
 	} else if name == d.fs42.WhoAmI() {
 		return d.fs42.local, nil
 	} else {
-		return nil, fuse.ENOENT // TODO
+		if d.fs42.coord() == nil {
+			return nil, fuse.ENOENT
+		}
 		info, err := d.fs42.coord().UserDirInfo(ctx, name)
 		if err != nil {
 			return nil, err
@@ -75,7 +151,10 @@ func (d RootDir) Lookup(ctx context.Context, name string) (fs.Node, error) {
 		if !info.Exists {
 			return nil, fuse.ENOENT
 		}
-		ud := NewUserDir(d.fs42, info)
+		ud, err := d.fs42.userDir(info)
+		if err != nil {
+			return nil, err
+		}
 		return &ud.RemoteNode, nil
 	}
 }
@@ -84,13 +163,53 @@ var rootEntries = []fuse.Dirent{
 	{Inode: INodeREADME, Name: "README", Type: fuse.DT_File},
 }
 
+// ReadDirAll lists the static entries, the owner's own directory, and every
+// user the coordinator knows about. User directories use the inode numbers
+// the coordinator assigned, which stay the same across listings and mounts.
 func (r RootDir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
-	entries := make([]fuse.Dirent, len(rootEntries) + 1)
+	me := r.fs42.WhoAmI()
+	entries := make([]fuse.Dirent, len(rootEntries), len(rootEntries)+1)
 	copy(entries, rootEntries)
-	entries[len(rootEntries)] = fuse.Dirent{
-		//Inode: r.fs42.local.Inode(ctx),
-		Name:  r.fs42.WhoAmI(),
-		Type:  fuse.DT_Dir,
+	seen := make(map[string]bool)
+	for _, e := range rootEntries {
+		seen[e.Name] = true
+	}
+
+	// until the coordinator has told us who we are, there is no name to
+	// list our own directory under
+	if me != "" {
+		seen[me] = true
+		var a fuse.Attr
+		err := r.fs42.local.Attr(ctx, &a)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fuse.Dirent{
+			Inode: a.Inode,
+			Name:  me,
+			Type:  fuse.DT_Dir,
+		})
+	}
+	coord := r.fs42.coord()
+	if coord == nil {
+		return entries, nil
+	}
+	users, err := coord.ListUsers(ctx, r.fs42.OnlineOnly)
+	if err != nil {
+		// still show what we have locally
+		log.Println("listing users:", err)
+		return entries, nil
+	}
+	for _, u := range users {
+		if seen[u.Login] {
+			continue
+		}
+		seen[u.Login] = true
+		entries = append(entries, fuse.Dirent{
+			Inode: u.INode,
+			Name:  u.Login,
+			Type:  fuse.DT_Dir,
+		})
 	}
 	return entries, nil
 }
@@ -101,7 +220,9 @@ const readmeContent = `
 Welcome to fs42!
 
 Here you can place files so they can be accessed by other 42 users.
-In order to browse someone's fs42 directory, you must both be logged in.
+When the owner of a directory is logged out, you will see a read-only copy
+from the last time their computer was on. The user.42fs.snapshot_time
+extended attribute says how old it is.
 
 `
 
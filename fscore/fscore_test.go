@@ -0,0 +1,43 @@
+package fscore
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/riking/42fs/auth"
+)
+
+func rootNames(t *testing.T, fs42 *FS42) []string {
+	ents, err := fs42.root.ReadDirAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range ents {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+func TestRootListsOwnerOnceKnown(t *testing.T) {
+	dir, err := ioutil.TempDir("", "42fs-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// no token yet
+	fs42 := NewFS42(nil, auth.NewSession(), dir)
+	defer fs42.Close()
+	if names := rootNames(t, fs42); len(names) != 1 || names[0] != "README" {
+		t.Errorf("root lists %q before the owner is known", names)
+	}
+
+	fs42 = NewFS42(nil, auth.NewUnverifiedSession("alice"), dir)
+	defer fs42.Close()
+	if names := rootNames(t, fs42); len(names) != 2 || names[1] != "alice" {
+		t.Errorf("root lists %q", names)
+	}
+}
@@ -0,0 +1,209 @@
+package fscore
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"path"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// ignoreFile is the name of the control file, in the public directory
+// itself, listing entries to hide from peers, in the syntax of .gitignore:
+// patterns without a slash match entries in any directory, the others are
+// relative to the public directory, a trailing slash only matches
+// directories, ** matches any number of directories and ! shows again what
+// an earlier pattern hid. Entries inside a hidden directory can't be shown
+// again. The owner still sees everything in their own directory.
+const ignoreFile = ".42fsignore"
+
+// defaultIgnore is applied before ignoreFile, which can show what it hides
+// with "!": files that are easy to share by mistake.
+var defaultIgnore = parseIgnoreLines("built-in", []string{
+	// control files, which tell who the owner shares with and how
+	"/" + ignoreFile,
+	"/" + symlinkPolicyFile,
+	aclFile,
+	// version control, which holds the whole history
+	".git/",
+	".hg/",
+	".svn/",
+	// secrets
+	".env",
+	".env.*",
+	// editor swap and backup files
+	"*.sw[a-p]",
+	"*~",
+	".#*",
+	"\\#*#",
+})
+
+// ignorePattern is one line of an ignore file.
+type ignorePattern struct {
+	// segs is the pattern split at slashes; a "**" segment matches any
+	// number of names
+	segs    []string
+	negate  bool
+	dirOnly bool
+}
+
+type ignoreList []ignorePattern
+
+func parseIgnore(r io.Reader) interface{} {
+	var lines []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	return parseIgnoreLines(ignoreFile, lines)
+}
+
+// parseIgnoreLines parses the lines of an ignore file. Bad patterns are
+// logged and skipped.
+func parseIgnoreLines(source string, lines []string) ignoreList {
+	var l ignoreList
+	for _, line := range lines {
+		// trailing spaces are dropped unless escaped
+		end := len(line)
+		for end > 0 && line[end-1] == ' ' && !(end > 1 && line[end-2] == '\\') {
+			end--
+		}
+		line = line[:end]
+		orig := line
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var p ignorePattern
+		if strings.HasPrefix(line, "!") {
+			p.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		if strings.Contains(line, "/") {
+			line = strings.TrimPrefix(line, "/")
+		} else {
+			line = "**/" + line
+		}
+		p.segs = strings.Split(line, "/")
+		valid := true
+		for _, seg := range p.segs {
+			if _, err := path.Match(seg, ""); err != nil {
+				log.Printf("%s: bad pattern %q: %v", source, orig, err)
+				valid = false
+				break
+			}
+		}
+		if valid {
+			l = append(l, p)
+		}
+	}
+	return l
+}
+
+// matchSegs reports whether names matches the pattern segments segs.
+func matchSegs(segs, names []string) bool {
+	for len(segs) > 0 {
+		if segs[0] == "**" {
+			segs = segs[1:]
+			if len(segs) == 0 {
+				// "dir/**" matches what is inside dir, not dir itself
+				return len(names) > 0
+			}
+			for i := range names {
+				if matchSegs(segs, names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(names) == 0 {
+			return false
+		}
+		ok, _ := path.Match(segs[0], names[0])
+		if !ok {
+			return false
+		}
+		segs, names = segs[1:], names[1:]
+	}
+	return len(names) == 0
+}
+
+// match reports whether the last pattern of l that matches names, a path
+// from the public directory, hides it, and whether any did.
+func (l ignoreList) match(names []string, isDir bool) (hidden, matched bool) {
+	for i := len(l) - 1; i >= 0; i-- {
+		p := &l[i]
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if matchSegs(p.segs, names) {
+			return !p.negate, true
+		}
+	}
+	return false, false
+}
+
+// ignoreLists returns the patterns in force, in the order they apply.
+func (md *LocalDir) ignoreLists() []ignoreList {
+	lists := []ignoreList{defaultIgnore}
+	if l, ok := md.control.load(md, ignoreFile, parseIgnore).(ignoreList); ok {
+		lists = append(lists, l)
+	}
+	return lists
+}
+
+// hides reports whether lists hide names, or a directory leading to it.
+// isDir tells whether names itself is a directory.
+func hides(lists []ignoreList, names []string, isDir bool) bool {
+	for i := 1; i <= len(names); i++ {
+		hidden := false
+		for j := len(lists) - 1; j >= 0; j-- {
+			h, matched := lists[j].match(names[:i], isDir || i < len(names))
+			if matched {
+				hidden = h
+				break
+			}
+		}
+		if hidden {
+			return true
+		}
+	}
+	return false
+}
+
+// ignored reports whether rel, a path relative to Root, is hidden from
+// peers.
+func (md *LocalDir) ignored(rel string) bool {
+	names, err := splitRel(rel)
+	if err != nil {
+		return true
+	}
+	lists := md.ignoreLists()
+	hidden := hides(lists, names, false)
+	if hidden != hides(lists, names, true) {
+		// only a pattern for directories matches
+		var st unix.Stat_t
+		isDir := md.lstat(rel, &st) == nil && st.Mode&unix.S_IFMT == unix.S_IFDIR
+		hidden = hides(lists, names, isDir)
+	}
+	return hidden
+}
+
+// mayBeIgnored reports whether rel would be hidden from peers as a file or
+// as a directory, for names that don't exist yet.
+func (md *LocalDir) mayBeIgnored(rel string) bool {
+	names, err := splitRel(rel)
+	if err != nil {
+		return true
+	}
+	lists := md.ignoreLists()
+	return hides(lists, names, false) || hides(lists, names, true)
+}
@@ -0,0 +1,158 @@
+package fscore
+
+import (
+	"strings"
+	"testing"
+)
+
+// split turns "a/b/" into the names a, b and whether it is a directory.
+func split(p string) ([]string, bool) {
+	isDir := strings.HasSuffix(p, "/")
+	return strings.Split(strings.TrimSuffix(p, "/"), "/"), isDir
+}
+
+func TestIgnorePatterns(t *testing.T) {
+	cases := []struct {
+		pattern string
+		hidden  []string
+		shown   []string
+	}{
+		// no slash: a name at any depth
+		{"build", []string{"build", "build/", "a/build", "a/b/build/"}, []string{"builds", "a/xbuild"}},
+		{"*.o", []string{"x.o", "a/b/x.o"}, []string{"x.oo", "a/x.c"}},
+		// a leading or inner slash anchors it
+		{"/build", []string{"build", "build/"}, []string{"a/build"}},
+		{"a/build", []string{"a/build"}, []string{"build", "b/a/build"}},
+		// a trailing slash only matches directories
+		{"tmp/", []string{"tmp/", "a/tmp/"}, []string{"tmp", "a/tmp"}},
+		{"/a/tmp/", []string{"a/tmp/"}, []string{"a/tmp", "tmp/"}},
+		// ** as prefix, middle and suffix
+		{"**/x", []string{"x", "a/x", "a/b/x"}, []string{"xa", "x/a"}},
+		{"a/**/x", []string{"a/x", "a/b/x", "a/b/c/x"}, []string{"x", "b/a/x", "a/x/y"}},
+		{"dir/**", []string{"dir/f", "dir/a/f", "dir/a/"}, []string{"dir", "dir/", "a/dir/f"}},
+		// escapes
+		{`\#notes#`, []string{"#notes#"}, []string{`\#notes#`}},
+		{`trailing\ `, []string{"trailing "}, []string{"trailing"}},
+		{`two\  `, []string{"two "}, []string{"two", "two  "}},
+		{"spaces   ", []string{"spaces"}, []string{"spaces "}},
+	}
+	for _, c := range cases {
+		l := parseIgnoreLines("test", []string{c.pattern})
+		if len(l) != 1 {
+			t.Errorf("%q parsed into %d patterns", c.pattern, len(l))
+			continue
+		}
+		for _, p := range c.hidden {
+			names, isDir := split(p)
+			if hidden, _ := l.match(names, isDir); !hidden {
+				t.Errorf("%q doesn't hide %s", c.pattern, p)
+			}
+		}
+		for _, p := range c.shown {
+			names, isDir := split(p)
+			if hidden, _ := l.match(names, isDir); hidden {
+				t.Errorf("%q hides %s", c.pattern, p)
+			}
+		}
+	}
+}
+
+func TestIgnoreSkippedLines(t *testing.T) {
+	l := parseIgnoreLines("test", []string{"", "   ", "# comment", "/", "!", "[", "ok"})
+	if len(l) != 1 || strings.Join(l[0].segs, "/") != "**/ok" {
+		t.Errorf("parsed %+v, want only ok", l)
+	}
+}
+
+func TestMatchSegs(t *testing.T) {
+	for _, c := range []struct {
+		segs, names string
+		want        bool
+	}{
+		{"a", "a", true},
+		{"a", "a/b", false},
+		{"a/b", "a", false},
+		{"**", "", false},
+		{"**", "a", true},
+		{"**/**", "a/b", true},
+		{"a/**/b/**", "a/x/b/y", true},
+		{"a/**/b/**", "a/x/b", false},
+		{"*/b", "a/b", true},
+		{"*/b", "b", false},
+	} {
+		var names []string
+		if c.names != "" {
+			names = strings.Split(c.names, "/")
+		}
+		if got := matchSegs(strings.Split(c.segs, "/"), names); got != c.want {
+			t.Errorf("matchSegs(%s, %s) = %v, want %v", c.segs, c.names, got, c.want)
+		}
+	}
+}
+
+func TestHides(t *testing.T) {
+	file := parseIgnoreLines(ignoreFile, []string{
+		"!.env",
+		"!.git/",
+		"secret/",
+		"!secret/public",
+		"*.log",
+		"!keep.log",
+	})
+	lists := []ignoreList{defaultIgnore, file}
+	for _, c := range []struct {
+		path   string
+		hidden bool
+	}{
+		// the file shows what the defaults hide
+		{".env", false},
+		{".git/", false},
+		{".git/config", false},
+		{".env.local", true},
+		{"x.swp", true},
+		// but can't show what is inside a hidden directory
+		{"secret/", true},
+		{"secret/public", true},
+		// later lines win
+		{"a.log", true},
+		{"keep.log", false},
+		{"d/keep.log", false},
+		// and the control files stay hidden
+		{".42fs-acl", true},
+		{"d/.42fs-acl", true},
+		{ignoreFile, true},
+		{"d/" + ignoreFile, false},
+	} {
+		names, isDir := split(c.path)
+		if got := hides(lists, names, isDir); got != c.hidden {
+			t.Errorf("%s hidden: %v, want %v", c.path, got, c.hidden)
+		}
+	}
+}
+
+func TestLocalDirIgnored(t *testing.T) {
+	alice := newTestOwner(t)
+	alice.mkdir(t, "build")
+	alice.writeFile(t, "out", "file")
+	alice.mkdir(t, "d")
+	alice.mkdir(t, "d/out")
+	alice.writeFile(t, ignoreFile, "out/\n/build\n")
+	ld := alice.fs42.local
+	for rel, want := range map[string]bool{
+		"build":       true,
+		"build/x":     true,
+		"d/build":     false,
+		"out":         false,
+		"d/out":       true,
+		"d/out/f":     true,
+		"x.swp":       true,
+		ignoreFile:    true,
+		"d":           false,
+		"../escaped":  true,
+		"d/../d/file": true,
+	} {
+		if got := ld.ignored(rel); got != want {
+			t.Errorf("ignored(%s) = %v, want %v", rel, got, want)
+		}
+	}
+}
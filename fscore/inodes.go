@@ -0,0 +1,75 @@
+package fscore
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// The inode numbers the kernel sees are not the ones on disk: files of
+// different users, or on different devices of the same user, would collide.
+// The root directory and its entries use small fixed numbers (INodeRootDir
+// and friends, then the coordinator's numbers for user directories), and
+// every other file gets a number from firstMappedINode up the first time it
+// is seen, which it keeps until unmount.
+
+// firstMappedINode is above anything the coordinator hands out.
+const firstMappedINode uint64 = 1 << 32
+
+// localLogin keys the owner's own files in the inode map. No remote user
+// has an empty login, and the owner's login isn't known until registration.
+const localLogin = ""
+
+type inodeKey struct {
+	login string
+	dev   uint64
+	ino   uint64
+}
+
+type inodeMap struct {
+	lock   sync.Mutex
+	next   uint64
+	inodes map[inodeKey]uint64
+}
+
+func newINodeMap() *inodeMap {
+	return &inodeMap{
+		next:   firstMappedINode,
+		inodes: make(map[inodeKey]uint64),
+	}
+}
+
+// get returns the mount-local inode number for inode ino on device dev of
+// login's machine.
+func (im *inodeMap) get(login string, dev, ino uint64) uint64 {
+	key := inodeKey{login: login, dev: dev, ino: ino}
+	im.lock.Lock()
+	defer im.lock.Unlock()
+
+	n, ok := im.inodes[key]
+	if !ok {
+		n = im.next
+		im.next++
+		im.inodes[key] = n
+	}
+	return n
+}
+
+// userRootINode is the inode number of the top of a user's directory. It is
+// the number the coordinator assigned, so that it matches the root
+// directory listing, unless there is none.
+func (fs42 *FS42) userRootINode(login string, coordINode, dev, ino uint64) uint64 {
+	if coordINode != 0 {
+		return coordINode
+	}
+	return fs42.inodes.get(login, dev, ino)
+}
+
+// myRootINode is the coordinator's number for the owner's directory, or 0.
+func (fs42 *FS42) myRootINode(ctx context.Context) uint64 {
+	coord := fs42.coord()
+	if coord == nil {
+		return 0
+	}
+	return coord.MyINode(ctx)
+}
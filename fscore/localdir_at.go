@@ -0,0 +1,222 @@
+package fscore
+
+import (
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Every file under LocalDir.Root is reached from a descriptor of Root, one
+// name at a time and without following symlinks, instead of by handing a
+// joined path to the kernel. Symlinks in the public directory are shown
+// according to the SymlinkPolicy, which only ever resolves them within
+// Root, and are never followed here: otherwise one pointing at ~/.ssh, or
+// a directory swapped for one while a path is in use, would let peers read
+// or change files outside Root.
+
+// errEscape is returned for paths that try to leave Root with "..".
+const errEscape = unix.EACCES
+
+// splitRel turns a path relative to Root into names. Empty names and "."
+// are dropped, so Root itself has none.
+func splitRel(rel string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(rel, "/") {
+		switch name {
+		case "", ".":
+		case "..":
+			return nil, errEscape
+		default:
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// openRoot opens Root itself, which the owner may have made a symlink.
+func (md *LocalDir) openRoot() (int, error) {
+	return unix.Open(md.Root, walkFlags|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+}
+
+// walkBeneath opens the directory at names below dirfd one name at a time.
+// It fails with ELOOP or ENOTDIR at a symlink.
+func walkBeneath(dirfd int, names []string) (int, error) {
+	fd, err := unix.Openat(dirfd, ".", walkFlags|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, err
+	}
+	for _, name := range names {
+		next, err := unix.Openat(fd, name, walkFlags|unix.O_DIRECTORY|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+		unix.Close(fd)
+		if err != nil {
+			return -1, err
+		}
+		fd = next
+	}
+	return fd, nil
+}
+
+// parentAt opens the directory holding rel, a path relative to Root, and
+// returns rel's name in it. For Root itself, that is Root and ".".
+func (md *LocalDir) parentAt(rel string) (int, string, error) {
+	names, err := splitRel(rel)
+	if err != nil {
+		return -1, "", err
+	}
+	root, err := md.openRoot()
+	if err != nil {
+		return -1, "", err
+	}
+	if len(names) == 0 {
+		return root, ".", nil
+	}
+	dirfd, err := openBeneath(root, names[:len(names)-1])
+	unix.Close(root)
+	if err != nil {
+		return -1, "", err
+	}
+	return dirfd, names[len(names)-1], nil
+}
+
+// lstat stats rel itself, even if it is a symlink.
+func (md *LocalDir) lstat(rel string, st *unix.Stat_t) error {
+	dirfd, name, err := md.parentAt(rel)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(dirfd)
+	return unix.Fstatat(dirfd, name, st, unix.AT_SYMLINK_NOFOLLOW)
+}
+
+// open opens rel, failing with ELOOP if it is a symlink.
+func (md *LocalDir) open(rel string, flags int, mode uint32) (int, error) {
+	dirfd, name, err := md.parentAt(rel)
+	if err != nil {
+		return -1, err
+	}
+	defer unix.Close(dirfd)
+	return unix.Openat(dirfd, name, flags|unix.O_NOFOLLOW|unix.O_CLOEXEC, mode)
+}
+
+// access checks the owner's access to rel with access(2) semantics.
+func (md *LocalDir) access(rel string, mask uint32) error {
+	dirfd, name, err := md.parentAt(rel)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(dirfd)
+	return unix.Faccessat(dirfd, name, mask, unix.AT_SYMLINK_NOFOLLOW)
+}
+
+func (md *LocalDir) readlink(rel string) (string, error) {
+	dirfd, name, err := md.parentAt(rel)
+	if err != nil {
+		return "", err
+	}
+	defer unix.Close(dirfd)
+	b := make([]byte, 64)
+	for {
+		n, err := unix.Readlinkat(dirfd, name, b)
+		if err != nil {
+			return "", err
+		}
+		if n < len(b) {
+			return string(b[:n]), nil
+		}
+		b = make([]byte, len(b)*2)
+	}
+}
+
+func (md *LocalDir) mkdir(rel string, mode uint32) error {
+	dirfd, name, err := md.parentAt(rel)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(dirfd)
+	return unix.Mkdirat(dirfd, name, mode)
+}
+
+func (md *LocalDir) symlink(target, rel string) error {
+	dirfd, name, err := md.parentAt(rel)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(dirfd)
+	return unix.Symlinkat(target, dirfd, name)
+}
+
+// remove removes the entry rel, which must be a directory if dir is set and
+// must not be one otherwise.
+func (md *LocalDir) remove(rel string, dir bool) error {
+	dirfd, name, err := md.parentAt(rel)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(dirfd)
+	flags := 0
+	if dir {
+		flags = unix.AT_REMOVEDIR
+	}
+	return unix.Unlinkat(dirfd, name, flags)
+}
+
+func (md *LocalDir) rename(oldRel, newRel string) error {
+	oldDir, oldName, err := md.parentAt(oldRel)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(oldDir)
+	newDir, newName, err := md.parentAt(newRel)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(newDir)
+	return unix.Renameat(oldDir, oldName, newDir, newName)
+}
+
+// link makes newRel a hard link to oldRel, or to the symlink if oldRel is
+// one.
+func (md *LocalDir) link(oldRel, newRel string) error {
+	oldDir, oldName, err := md.parentAt(oldRel)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(oldDir)
+	newDir, newName, err := md.parentAt(newRel)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(newDir)
+	return unix.Linkat(oldDir, oldName, newDir, newName, 0)
+}
+
+// utimes sets the access and modification times of rel itself. A time with
+// Nsec set to UTIME_OMIT is left alone.
+func (md *LocalDir) utimes(rel string, ts []unix.Timespec) error {
+	dirfd, name, err := md.parentAt(rel)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(dirfd)
+	return unix.UtimesNanoAt(dirfd, name, ts, unix.AT_SYMLINK_NOFOLLOW)
+}
+
+// chmod changes the permissions of rel, never those of a symlink's target.
+func (md *LocalDir) chmod(rel string, mode uint32) error {
+	dirfd, name, err := md.parentAt(rel)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(dirfd)
+	return fchmodatNoFollow(dirfd, name, mode)
+}
+
+// truncate changes the size of rel, failing if it is a symlink.
+func (md *LocalDir) truncate(rel string, size int64) error {
+	fd, err := md.open(rel, unix.O_WRONLY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	return unix.Ftruncate(fd, size)
+}
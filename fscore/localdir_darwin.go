@@ -12,11 +12,81 @@ import (
 	"golang.org/x/sys/unix"
 )
 
-func (d *LocalNode) Attr(ctx context.Context, a *fuse.Attr) error {
+// errNoXattr is returned when a file lacks the extended attribute asked for.
+const errNoXattr = unix.ENOATTR
+
+// walkFlags opens the directories leading to a file. Without O_PATH, they
+// must be readable by the owner.
+const walkFlags = unix.O_RDONLY
+
+// openBeneath opens the directory at names below dirfd, failing at any
+// symlink.
+func openBeneath(dirfd int, names []string) (int, error) {
+	return walkBeneath(dirfd, names)
+}
+
+// fchmodatNoFollow changes the permissions of name in dirfd, or of the
+// symlink itself if it is one.
+func fchmodatNoFollow(dirfd int, name string, mode uint32) error {
+	return unix.Fchmodat(dirfd, name, mode, unix.AT_SYMLINK_NOFOLLOW)
+}
+
+// xattrPath returns the path of rel for the xattr calls, which have no *at
+// variants, after checking that no directory leading to it is a symlink.
+// The bridges pass XATTR_NOFOLLOW for rel itself. A directory replaced by a
+// symlink between the check and the call can still be followed. done must
+// be called when the path is no longer used.
+func (md *LocalDir) xattrPath(rel string) (p string, done func(), err error) {
+	dirfd, _, err := md.parentAt(rel)
+	if err != nil {
+		return "", nil, err
+	}
+	unix.Close(dirfd)
+	return md.Root + "/" + rel, func() {}, nil
+}
+
+// getxattr reads the extended attribute attr of rel into dest.
+func (md *LocalDir) getxattr(rel, attr string, dest []byte) (int, error) {
+	p, done, err := md.xattrPath(rel)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+	return unix.Lgetxattr(p, attr, dest)
+}
+
+// chflags sets the BSD flags of rel, failing if it is a symlink.
+func (md *LocalDir) chflags(rel string, flags int) error {
+	fd, err := md.open(rel, unix.O_RDONLY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	return unix.Fchflags(fd, flags)
+}
+
+// setxattr sets the extended attribute attr of rel to value.
+func (md *LocalDir) setxattr(rel, attr string, value []byte) error {
+	p, done, err := md.xattrPath(rel)
+	if err != nil {
+		return err
+	}
+	defer done()
+	return unix.Lsetxattr(p, attr, value, 0)
+}
+
+// changeTimes returns the modification and status change times in st.
+func changeTimes(st *unix.Stat_t) (mtime, ctime unix.Timespec) {
+	return st.Mtimespec, st.Ctimespec
+}
+
+// statAttr fills a from lstat(2), leaving the on-disk inode number in
+// a.Inode, and returns the device the file is on.
+func (d *LocalNode) statAttr(a *fuse.Attr) (uint64, error) {
 	var stat_t unix.Stat_t
-	err := unix.Lstat(d.FullPath(), &stat_t)
+	err := d.md.lstat(d.Path, &stat_t)
 	if err != nil {
-		return fuse.Errno(err.(syscall.Errno))
+		return 0, fuse.Errno(err.(syscall.Errno))
 	}
 
 	a.Inode = stat_t.Ino
@@ -33,11 +103,11 @@ func (d *LocalNode) Attr(ctx context.Context, a *fuse.Attr) error {
 	a.Rdev = uint32(stat_t.Rdev)
 	a.Flags = uint32(stat_t.Flags)
 	a.BlockSize = uint32(stat_t.Blksize)
-	return nil
+	return uint64(stat_t.Dev), nil
 }
 
 func (d *LocalNode) Fsync(ctx context.Context, req *fuse.FsyncRequest) error {
-	fd, err := unix.Open(d.FullPath(), unix.O_NOFOLLOW | unix.O_RDONLY, 0)
+	fd, err := d.md.open(d.Path, unix.O_RDONLY, 0)
 	if err != nil {
 		return err
 	}
@@ -46,7 +116,7 @@ func (d *LocalNode) Fsync(ctx context.Context, req *fuse.FsyncRequest) error {
 }
 
 func (d *LocalNode) setattrPlatform(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
-	var err, finalErr error
+	var finalErr error
 	addErr := func(e error) {
 		if finalErr == nil {
 			finalErr = e
@@ -54,8 +124,7 @@ func (d *LocalNode) setattrPlatform(ctx context.Context, req *fuse.SetattrReques
 	}
 
 	if req.Valid.Flags() {
-		err = unix.Chflags(d.FullPath(), int(req.Flags))
-		addErr(err)
+		addErr(d.md.chflags(d.Path, int(req.Flags)))
 	}
 	if req.Valid.Bkuptime() || req.Valid.Chgtime() || req.Valid.Crtime() {
 		addErr(fuse.ENOTSUP)
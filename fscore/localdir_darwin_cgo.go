@@ -74,7 +74,12 @@ func (d *LocalNode) Getxattr(ctx context.Context, req *fuse.GetxattrRequest, res
 	var bufAddr unsafe.Pointer
 	var bufLen C.ssize_t
 
-	bufLen, err := C.bridge_getxattr(C.CString(d.FullPath()), C.CString(req.Name), &bufAddr, C.size_t(req.Size), req.Position)
+	p, done, err := d.md.xattrPath(d.Path)
+	if err != nil {
+		return err
+	}
+	defer done()
+	bufLen, err = C.bridge_getxattr(C.CString(p), C.CString(req.Name), &bufAddr, C.size_t(req.Size), req.Position)
 	if bufLen == -1 {
 		return err
 	}
@@ -91,7 +96,12 @@ func (d *LocalNode) Listxattr(ctx context.Context, req *fuse.ListxattrRequest, r
 	var bufAddr unsafe.Pointer
 	var bufLen C.ssize_t
 
-	bufLen, err := C.bridge_listxattr(C.CString(d.FullPath()), &bufAddr, C.size_t(req.Size))
+	p, done, err := d.md.xattrPath(d.Path)
+	if err != nil {
+		return err
+	}
+	defer done()
+	bufLen, err = C.bridge_listxattr(C.CString(p), &bufAddr, C.size_t(req.Size))
 	if bufLen == -1 {
 		return err
 	}
@@ -105,11 +115,21 @@ func (d *LocalNode) Listxattr(ctx context.Context, req *fuse.ListxattrRequest, r
 }
 
 func (d *LocalNode) Removexattr(ctx context.Context, req *fuse.RemovexattrRequest) error {
-	_, err := C.bridge_removexattr(C.CString(d.FullPath()), C.CString(req.Name))
+	p, done, err := d.md.xattrPath(d.Path)
+	if err != nil {
+		return err
+	}
+	defer done()
+	_, err = C.bridge_removexattr(C.CString(p), C.CString(req.Name))
 	return err
 }
 
 func (d *LocalNode) Setxattr(ctx context.Context, req *fuse.SetxattrRequest) error {
-	_, err := C.bridge_setxattr(C.CString(d.FullPath()), C.CString(req.Name), C.CBytes(req.Xattr), C.size_t(len(req.Xattr)), req.Position, C.int(req.Flags))
+	p, done, err := d.md.xattrPath(d.Path)
+	if err != nil {
+		return err
+	}
+	defer done()
+	_, err = C.bridge_setxattr(C.CString(p), C.CString(req.Name), C.CBytes(req.Xattr), C.size_t(len(req.Xattr)), req.Position, C.int(req.Flags))
 	return err
 }
\ No newline at end of file
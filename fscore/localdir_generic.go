@@ -8,8 +8,6 @@ import (
 	"golang.org/x/net/context"
 	"sync"
 	"os"
-	"path/filepath"
-	"strings"
 )
 
 type LocalDir struct {
@@ -20,6 +18,9 @@ type LocalDir struct {
 	lock      sync.Mutex
 	pathCache map[string]*LocalNode
 	openFiles map[int]*LocalFile
+	control   controlFiles
+
+	changes   *changeFeed
 }
 
 func NewLocalDir(fs42 *FS42, root string) *LocalDir {
@@ -28,6 +29,8 @@ func NewLocalDir(fs42 *FS42, root string) *LocalDir {
 		Root:      root,
 		pathCache: make(map[string]*LocalNode),
 		openFiles: make(map[int]*LocalFile),
+		control:   controlFiles{files: make(map[string]*parsedFile)},
+		changes:   newChangeFeed(root),
 	}
 	md.LocalNode = LocalNode{md: md, Path: "."}
 	md.pathCache[""] = &md.LocalNode
@@ -45,7 +48,11 @@ func NewLocalDir(fs42 *FS42, root string) *LocalDir {
 }
 
 func (md *LocalDir) nodeFor(parent *LocalNode, name string) *LocalNode {
-	relName := parent.JoinRelative(name)
+	return md.node(parent.JoinRelative(name))
+}
+
+// node returns the node at relName, a path relative to Root.
+func (md *LocalDir) node(relName string) *LocalNode {
 	md.lock.Lock()
 	defer md.lock.Unlock()
 
@@ -68,6 +75,21 @@ func (md *LocalDir) forgetNode(name string) {
 	}
 }
 
+// closeFiles releases every file still open, by the kernel or by peers.
+// Nothing may use them afterwards.
+func (md *LocalDir) closeFiles() {
+	md.lock.Lock()
+	files := make([]*LocalFile, 0, len(md.openFiles))
+	for _, lf := range md.openFiles {
+		files = append(files, lf)
+	}
+	md.lock.Unlock()
+
+	for _, lf := range files {
+		lf.Release(context.Background(), &fuse.ReleaseRequest{})
+	}
+}
+
 type LocalNode struct {
 	md   *LocalDir
 	Path string
@@ -77,19 +99,9 @@ func (d *LocalNode) FullPath() string {
 	return fmt.Sprintf("%s/%s", d.md.Root, d.Path)
 }
 
-func (d *LocalNode) Join(file string) string {
-	file = filepath.Clean(file)
-	if strings.HasPrefix(file, "..") {
-		return "/BAD_PATH"
-	}
-	return fmt.Sprintf("%s/%s/%s", d.md.Root, d.Path, file)
-}
-
+// JoinRelative returns the path of file in d, relative to Root. It is only
+// checked when used, which fails if it tries to leave Root.
 func (d *LocalNode) JoinRelative(file string) string {
-	file = filepath.Clean(file)
-	if strings.HasPrefix(file, "..") {
-		return "/BAD_PATH"
-	}
 	return fmt.Sprintf("%s/%s", d.Path, file)
 }
 
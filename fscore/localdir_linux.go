@@ -2,6 +2,8 @@
 package fscore
 
 import (
+	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -11,12 +13,115 @@ import (
 	"fmt"
 )
 
-func (d *LocalNode) Attr(ctx context.Context, a *fuse.Attr) error {
+// errNoXattr is returned when a file lacks the extended attribute asked for.
+const errNoXattr = unix.ENODATA
+
+// walkFlags opens the directories leading to a file. O_PATH only needs
+// search permission on them.
+const walkFlags = unix.O_PATH
+
+// noOpenat2 is set once openat2(2) turns out to be missing, or blocked by a
+// seccomp filter.
+var noOpenat2 int32
+
+// openBeneath opens the directory at names below dirfd, failing at any
+// symlink.
+func openBeneath(dirfd int, names []string) (int, error) {
+	if len(names) == 0 || atomic.LoadInt32(&noOpenat2) != 0 {
+		return walkBeneath(dirfd, names)
+	}
+	fd, err := unix.Openat2(dirfd, strings.Join(names, "/"), &unix.OpenHow{
+		Flags:   unix.O_PATH | unix.O_DIRECTORY | unix.O_CLOEXEC,
+		Resolve: unix.RESOLVE_BENEATH | unix.RESOLVE_NO_SYMLINKS,
+	})
+	switch err {
+	case unix.ENOSYS, unix.EPERM:
+		atomic.StoreInt32(&noOpenat2, 1)
+		return walkBeneath(dirfd, names)
+	case unix.EAGAIN:
+		// a rename raced with the lookup
+		return walkBeneath(dirfd, names)
+	}
+	return fd, err
+}
+
+// fchmodatNoFollow changes the permissions of name in dirfd, failing with
+// EOPNOTSUPP if it is a symlink. Kernels before 6.6 lack fchmodat2(2), so
+// the file is then reached through /proc instead.
+func fchmodatNoFollow(dirfd int, name string, mode uint32) error {
+	err := unix.Fchmodat(dirfd, name, mode, unix.AT_SYMLINK_NOFOLLOW)
+	if err != unix.EOPNOTSUPP {
+		return err
+	}
+	fd, err := unix.Openat(dirfd, name, unix.O_PATH|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	var st unix.Stat_t
+	err = unix.Fstat(fd, &st)
+	if err != nil {
+		return err
+	}
+	if st.Mode&unix.S_IFMT == unix.S_IFLNK {
+		return unix.EOPNOTSUPP
+	}
+	return unix.Chmod(fmt.Sprintf("/proc/self/fd/%d", fd), mode)
+}
+
+// xattrPath returns a path to rel for the xattr calls, which have no *at
+// variants. It names a descriptor in /proc instead of going through Root,
+// so the calls must follow it; done closes the descriptor. Symlinks, which
+// can't hold user attributes, fail with errNoXattr.
+func (md *LocalDir) xattrPath(rel string) (p string, done func(), err error) {
+	fd, err := md.open(rel, unix.O_PATH, 0)
+	if err != nil {
+		return "", nil, err
+	}
+	var st unix.Stat_t
+	err = unix.Fstat(fd, &st)
+	if err == nil && st.Mode&unix.S_IFMT == unix.S_IFLNK {
+		err = errNoXattr
+	}
+	if err != nil {
+		unix.Close(fd)
+		return "", nil, err
+	}
+	return fmt.Sprintf("/proc/self/fd/%d", fd), func() { unix.Close(fd) }, nil
+}
+
+// getxattr reads the extended attribute attr of rel into dest.
+func (md *LocalDir) getxattr(rel, attr string, dest []byte) (int, error) {
+	p, done, err := md.xattrPath(rel)
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+	return unix.Getxattr(p, attr, dest)
+}
+
+// setxattr sets the extended attribute attr of rel to value.
+func (md *LocalDir) setxattr(rel, attr string, value []byte) error {
+	p, done, err := md.xattrPath(rel)
+	if err != nil {
+		return err
+	}
+	defer done()
+	return unix.Setxattr(p, attr, value, 0)
+}
+
+// changeTimes returns the modification and status change times in st.
+func changeTimes(st *unix.Stat_t) (mtime, ctime unix.Timespec) {
+	return st.Mtim, st.Ctim
+}
+
+// statAttr fills a from lstat(2), leaving the on-disk inode number in
+// a.Inode, and returns the device the file is on.
+func (d *LocalNode) statAttr(a *fuse.Attr) (uint64, error) {
 	var stat_t unix.Stat_t
-	fmt.Println("stat", d.Path)
-	err := unix.Lstat(d.FullPath(), &stat_t)
+	err := d.md.lstat(d.Path, &stat_t)
 	if err != nil {
-		return fuse.Errno(err.(syscall.Errno))
+		return 0, fuse.Errno(err.(syscall.Errno))
 	}
 
 	a.Inode = stat_t.Ino
@@ -33,11 +138,11 @@ func (d *LocalNode) Attr(ctx context.Context, a *fuse.Attr) error {
 	a.Rdev = uint32(stat_t.Rdev)
 	//a.Flags = uint32(stat_t.Flags)
 	a.BlockSize = uint32(stat_t.Blksize)
-	return nil
+	return uint64(stat_t.Dev), nil
 }
 
 func (d *LocalNode) Fsync(ctx context.Context, req *fuse.FsyncRequest) error {
-	fd, err := unix.Open(d.FullPath(), unix.O_NOFOLLOW | unix.O_RDONLY, 0)
+	fd, err := d.md.open(d.Path, unix.O_RDONLY, 0)
 	if err != nil {
 		return err
 	}
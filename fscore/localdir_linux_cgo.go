@@ -2,7 +2,6 @@
 package fscore
 
 import (
-	"fmt"
 	"unsafe"
 
 	"bazil.org/fuse"
@@ -24,7 +23,7 @@ ssize_t bridge_getxattr(char *path, char *attrName, void **buf, size_t size)
 		*buf = 0;
 	else
 		*buf = malloc(size);
-	size_read = lgetxattr(path, attrName, *buf, size);
+	size_read = getxattr(path, attrName, *buf, size);
 	free(path);
 	free(attrName);
 	return (size_read);
@@ -39,7 +38,7 @@ ssize_t	bridge_listxattr(char *path, void **buf, size_t size)
 		*buf = 0;
 	else
 		*buf = malloc(size);
-	size_read = llistxattr(path, *buf, size);
+	size_read = listxattr(path, *buf, size);
 	free(path);
 	return (size_read);
 }
@@ -49,7 +48,7 @@ int	bridge_removexattr(char *path, char *name)
 	int eret;
 
 	errno = 0;
-	eret = lremovexattr(path, name);
+	eret = removexattr(path, name);
 	free(path);
 	free(name);
 	return (eret);
@@ -60,7 +59,7 @@ int	bridge_setxattr(char *path, char *name, void *value, size_t size, int option
 	int eret;
 
 	errno = 0;
-	eret = lsetxattr(path, name, value, size, options);
+	eret = setxattr(path, name, value, size, options);
 	free(path);
 	free(name);
 	free(value);
@@ -74,11 +73,15 @@ func (d *LocalNode) Getxattr(ctx context.Context, req *fuse.GetxattrRequest, res
 	var bufAddr unsafe.Pointer
 	var bufLen C.ssize_t
 
-	fmt.Println("getxattr", d.Path, req.Name)
-	defer func() {
-		fmt.Println("getxattr return", resp.Xattr)
-	}()
-	bufLen, err := C.bridge_getxattr(C.CString(d.FullPath()), C.CString(req.Name), &bufAddr, C.size_t(req.Size))
+	p, done, err := d.md.xattrPath(d.Path)
+	if err == errNoXattr {
+		resp.Xattr = nil
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer done()
+	bufLen, err = C.bridge_getxattr(C.CString(p), C.CString(req.Name), &bufAddr, C.size_t(req.Size))
 	if req.Size != 0 {
 		defer C.free(bufAddr)
 	}
@@ -101,7 +104,14 @@ func (d *LocalNode) Listxattr(ctx context.Context, req *fuse.ListxattrRequest, r
 	var bufAddr unsafe.Pointer
 	var bufLen C.ssize_t
 
-	bufLen, err := C.bridge_listxattr(C.CString(d.FullPath()), &bufAddr, C.size_t(req.Size))
+	p, done, err := d.md.xattrPath(d.Path)
+	if err == errNoXattr {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer done()
+	bufLen, err = C.bridge_listxattr(C.CString(p), &bufAddr, C.size_t(req.Size))
 	if bufLen == -1 {
 		return err
 	}
@@ -115,11 +125,25 @@ func (d *LocalNode) Listxattr(ctx context.Context, req *fuse.ListxattrRequest, r
 }
 
 func (d *LocalNode) Removexattr(ctx context.Context, req *fuse.RemovexattrRequest) error {
-	_, err := C.bridge_removexattr(C.CString(d.FullPath()), C.CString(req.Name))
+	p, done, err := d.md.xattrPath(d.Path)
+	if err == errNoXattr {
+		return fuse.EPERM
+	} else if err != nil {
+		return err
+	}
+	defer done()
+	_, err = C.bridge_removexattr(C.CString(p), C.CString(req.Name))
 	return err
 }
 
 func (d *LocalNode) Setxattr(ctx context.Context, req *fuse.SetxattrRequest) error {
-	_, err := C.bridge_setxattr(C.CString(d.FullPath()), C.CString(req.Name), C.CBytes(req.Xattr), C.size_t(len(req.Xattr)), C.int(req.Flags))
+	p, done, err := d.md.xattrPath(d.Path)
+	if err == errNoXattr {
+		return fuse.EPERM
+	} else if err != nil {
+		return err
+	}
+	defer done()
+	_, err = C.bridge_setxattr(C.CString(p), C.CString(req.Name), C.CBytes(req.Xattr), C.size_t(len(req.Xattr)), C.int(req.Flags))
 	return err
 }
@@ -2,13 +2,13 @@ package fscore
 
 import (
 	"os"
-	"fmt"
-	"time"
 
 	"bazil.org/fuse"
 	"bazil.org/fuse/fs"
 	"golang.org/x/sys/unix"
 	"golang.org/x/net/context"
+
+	fgrpc "github.com/riking/42fs/grpc"
 )
 
 // fileMode returns a Go os.FileMode from a Unix mode.
@@ -98,20 +98,34 @@ func unixCreateMode(osMode os.FileMode) uint32 {
 	return mode
 }
 
-func toTimeval(t time.Time) unix.Timeval {
-	return unix.NsecToTimeval(t.UnixNano())
+func (d *LocalNode) Attr(ctx context.Context, a *fuse.Attr) error {
+	dev, err := d.statAttr(a)
+	if err != nil {
+		return err
+	}
+	fs42 := d.md.fs42
+	if d.Path == "." {
+		a.Inode = fs42.userRootINode(localLogin, fs42.myRootINode(ctx), dev, a.Inode)
+	} else {
+		a.Inode = fs42.inodes.get(localLogin, dev, a.Inode)
+	}
+	return nil
 }
 
 func (d *LocalNode) Lookup(ctx context.Context, name string) (fs.Node, error) {
-	err := unix.Access(d.Join(name), unix.F_OK)
+	rel, err := d.md.resolve(d.JoinRelative(name), d.md.symlinkPolicy(), nil)
 	if err != nil {
 		return nil, err
 	}
-	return d.md.nodeFor(d, name), nil
+	err = d.md.access(rel, unix.F_OK)
+	if err != nil {
+		return nil, err
+	}
+	return d.md.node(rel), nil
 }
 
 func (d *LocalNode) Access(ctx context.Context, req *fuse.AccessRequest) error {
-	return unix.Access(d.FullPath(), req.Mask)
+	return d.md.access(d.Path, req.Mask)
 }
 
 func (d *LocalNode) Link(ctx context.Context, req *fuse.LinkRequest, old fs.Node) (fs.Node, error) {
@@ -119,7 +133,7 @@ func (d *LocalNode) Link(ctx context.Context, req *fuse.LinkRequest, old fs.Node
 	if !ok {
 		return nil, fuse.Errno(unix.EBADF)
 	}
-	err := unix.Link(oldLN.FullPath(), d.Join(req.NewName))
+	err := d.md.link(oldLN.Path, d.JoinRelative(req.NewName))
 	if err != nil {
 		return nil, err
 	}
@@ -128,7 +142,7 @@ func (d *LocalNode) Link(ctx context.Context, req *fuse.LinkRequest, old fs.Node
 }
 
 func (d *LocalNode) Symlink(ctx context.Context, req *fuse.SymlinkRequest) (fs.Node, error) {
-	err := unix.Symlink(req.Target, d.Join(req.NewName))
+	err := d.md.symlink(req.Target, d.JoinRelative(req.NewName))
 	if err != nil {
 		return nil, err
 	}
@@ -137,29 +151,15 @@ func (d *LocalNode) Symlink(ctx context.Context, req *fuse.SymlinkRequest) (fs.N
 }
 
 func (d *LocalNode) Readlink(ctx context.Context, req *fuse.ReadlinkRequest) (string, error) {
-	fullPath := d.FullPath()
-	b := make([]byte, 0, 64)
-	var l int
-	var err error
-	for {
-		l, err = unix.Readlink(fullPath, b)
-		if err != nil {
-			return "", err
-		}
-		if l <= cap(b) {
-			break
-		}
-		b = make([]byte, 0, cap(b) * 2)
+	if d.md.symlinkPolicy() != SymlinksServe {
+		// nothing is shown as a symlink
+		return "", fuse.Errno(unix.EINVAL)
 	}
-	return string(b[:l]), nil
+	return d.md.readlink(d.Path)
 }
 
 func (d *LocalNode) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
-	if req.Dir {
-		return unix.Rmdir(d.Join(req.Name))
-	} else {
-		return unix.Unlink(d.Join(req.Name))
-	}
+	return d.md.remove(d.JoinRelative(req.Name), req.Dir)
 }
 
 func (d *LocalNode) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.Node) error {
@@ -167,17 +167,10 @@ func (d *LocalNode) Rename(ctx context.Context, req *fuse.RenameRequest, newDir
 	if !ok {
 		return fuse.Errno(unix.EBADF)
 	}
-	return unix.Rename(d.Join(req.OldName), newLN.Join(req.NewName))
+	return d.md.rename(d.JoinRelative(req.OldName), newLN.JoinRelative(req.NewName))
 }
 
 func (d *LocalNode) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
-	var a fuse.Attr
-	err := d.Attr(ctx, &a)
-	if err != nil {
-		return err
-	}
-	fullPath := d.FullPath()
-
 	var finalErr error
 	addErr := func(e error) {
 		if finalErr == nil {
@@ -186,14 +179,10 @@ func (d *LocalNode) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp
 	}
 
 	if req.Valid.Size() {
-		err = unix.Truncate(fullPath, int64(req.Size))
-		addErr(err)
+		addErr(d.md.truncate(d.Path, int64(req.Size)))
 	}
 	if req.Valid.Mode() {
-		err = os.Chmod(fullPath, req.Mode)
-		if err != nil {
-			addErr(err.(*os.PathError).Err)
-		}
+		addErr(d.md.chmod(d.Path, unixCreateMode(req.Mode)))
 	}
 	if req.Valid.Uid() || req.Valid.Gid() {
 		// haha, nice joke. no. not allowed.
@@ -201,43 +190,27 @@ func (d *LocalNode) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp
 	}
 	addErr(d.setattrPlatform(ctx, req, resp))
 	if req.Valid.Mtime() || req.Valid.Atime() || req.Valid.MtimeNow() || req.Valid.AtimeNow() {
-		var times [2]unix.Timeval
-		times[0] = toTimeval(a.Atime)
-		times[1] = toTimeval(a.Mtime)
-		if req.Valid.Atime() {
-			if req.Valid.AtimeNow() {
-				times[0] = toTimeval(time.Now())
-			} else {
-				times[0] = toTimeval(req.Atime)
-			}
-		}
-		if req.Valid.Mtime() {
-			if req.Valid.MtimeNow() {
-				times[0] = toTimeval(time.Now())
-			} else {
-				times[0] = toTimeval(req.Mtime)
-			}
+		times := []unix.Timespec{{Nsec: unix.UTIME_OMIT}, {Nsec: unix.UTIME_OMIT}}
+		if req.Valid.AtimeNow() {
+			times[0].Nsec = unix.UTIME_NOW
+		} else if req.Valid.Atime() {
+			times[0] = unix.NsecToTimespec(req.Atime.UnixNano())
 		}
-		// must open the file to get NOFOLLOW semantics
-		fd, err := unix.Open(fullPath, unix.O_NOFOLLOW | unix.O_RDONLY, 0)
-		if err == nil {
-			err = unix.Futimes(fd, times[:])
-			unix.Close(fd)
+		if req.Valid.MtimeNow() {
+			times[1].Nsec = unix.UTIME_NOW
+		} else if req.Valid.Mtime() {
+			times[1] = unix.NsecToTimespec(req.Mtime.UnixNano())
 		}
-		addErr(err)
+		addErr(d.md.utimes(d.Path, times))
 	}
 
 	return finalErr
 }
 
 
-func (d *LocalNode) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (h fs.Handle, err error) {
-	defer func() {
-		fmt.Println("open", d.Path, req.Flags, h, err)
-	}()
-
+func (d *LocalNode) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
 	req.Flags &^= unix.O_NONBLOCK
-	fd, err := unix.Open(d.FullPath(), int(req.Flags), 0)
+	fd, err := d.md.open(d.Path, int(req.Flags), 0)
 	if err != nil {
 		return nil, err
 	}
@@ -255,13 +228,13 @@ func (d *LocalNode) Create(ctx context.Context, req *fuse.CreateRequest, resp *f
 		oldUmask = unix.Umask(int(unixCreateMode(req.Umask)))
 	}
 	req.Flags &^= unix.O_NONBLOCK
-	fd, err := unix.Open(d.Join(req.Name), int(req.Flags), unixCreateMode(req.Mode))
-	if err != nil {
-		return nil, nil, err.(*os.PathError).Err
-	}
+	fd, err := d.md.open(d.JoinRelative(req.Name), int(req.Flags), unixCreateMode(req.Mode))
 	if req.Umask != 0 {
 		unix.Umask(oldUmask)
 	}
+	if err != nil {
+		return nil, nil, err
+	}
 	newLn := d.md.nodeFor(d, req.Name)
 	newLf := &LocalFile{
 		fd: fd,
@@ -279,7 +252,7 @@ func (d *LocalNode) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node,
 	if req.Umask != 0 {
 		oldUmask = unix.Umask(int(unixCreateMode(req.Umask)))
 	}
-	err := unix.Mkdir(d.Join(req.Name), unixCreateMode(req.Mode))
+	err := d.md.mkdir(d.JoinRelative(req.Name), unixCreateMode(req.Mode))
 	if req.Umask != 0 {
 		unix.Umask(oldUmask)
 	}
@@ -309,8 +282,10 @@ func (f *LocalFile) Flush(ctx context.Context, req *fuse.FlushRequest) error {
 	return nil
 }
 
-func (f *LocalFile) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
-	fmt.Println("readdir", f.ln.Path)
+// readDir lists the directory with on-disk inode numbers, which is what
+// peers are sent. check is passed to LocalDir.resolve for the symlinks in
+// it; those it refuses are left out.
+func (f *LocalFile) readDir(check func(rel string) error) ([]fgrpc.Dirent, error) {
 	of, err := f.getOSFile()
 	if err != nil {
 		return nil, err
@@ -319,42 +294,77 @@ func (f *LocalFile) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 	if err != nil {
 		return nil, err
 	}
+	md := f.ln.md
+	policy := md.symlinkPolicy()
 	var stat_t unix.Stat_t
-	fuseEnts := make([]fuse.Dirent, len(names))
-	for i, v := range names {
-		fuseEnts[i].Name = v
-		err = unix.Lstat(f.ln.Join(v), &stat_t)
+	ents := make([]fgrpc.Dirent, 0, len(names))
+	for _, v := range names {
+		ents = append(ents, fgrpc.Dirent{Name: v})
+		e := &ents[len(ents)-1]
+		err = unix.Fstatat(f.fd, v, &stat_t, unix.AT_SYMLINK_NOFOLLOW)
+		if err == nil && stat_t.Mode&unix.S_IFMT == unix.S_IFLNK && policy != SymlinksServe {
+			// listed as what it leads to, or not at all
+			var rel string
+			rel, err = md.resolve(f.ln.JoinRelative(v), policy, check)
+			if err == nil {
+				err = md.lstat(rel, &stat_t)
+			}
+			if err != nil {
+				ents = ents[:len(ents)-1]
+				continue
+			}
+		}
 		if err != nil {
 			continue
 		}
-		switch {
-		case stat_t.Mode&unix.S_IFSOCK != 0:
-			fuseEnts[i].Type = fuse.DT_Socket
-		case stat_t.Mode&unix.S_IFLNK != 0:
-			fuseEnts[i].Type = fuse.DT_Link
-		case stat_t.Mode&unix.S_IFREG != 0:
-			fuseEnts[i].Type = fuse.DT_File
-		case stat_t.Mode&unix.S_IFDIR != 0:
-			fuseEnts[i].Type = fuse.DT_Dir
-		case stat_t.Mode&unix.S_IFBLK != 0:
-			fuseEnts[i].Type = fuse.DT_Block
-		case stat_t.Mode&unix.S_IFCHR != 0:
-			fuseEnts[i].Type = fuse.DT_Char
-		case stat_t.Mode&unix.S_IFIFO != 0:
-			fuseEnts[i].Type = fuse.DT_FIFO
+		e.Inode = stat_t.Ino
+		e.Dev = uint64(stat_t.Dev)
+		var t fuse.DirentType
+		switch stat_t.Mode & unix.S_IFMT {
+		case unix.S_IFSOCK:
+			t = fuse.DT_Socket
+		case unix.S_IFLNK:
+			t = fuse.DT_Link
+		case unix.S_IFREG:
+			t = fuse.DT_File
+		case unix.S_IFDIR:
+			t = fuse.DT_Dir
+		case unix.S_IFBLK:
+			t = fuse.DT_Block
+		case unix.S_IFCHR:
+			t = fuse.DT_Char
+		case unix.S_IFIFO:
+			t = fuse.DT_FIFO
 		default:
-			fuseEnts[i].Type = fuse.DT_Unknown
+			t = fuse.DT_Unknown
+		}
+		e.Type = uint32(t)
+	}
+	return ents, nil
+}
+
+func (f *LocalFile) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	ents, err := f.readDir(nil)
+	if err != nil {
+		return nil, err
+	}
+	inodes := f.ln.md.fs42.inodes
+	fuseEnts := make([]fuse.Dirent, len(ents))
+	for i, e := range ents {
+		fuseEnts[i].Name = e.Name
+		fuseEnts[i].Type = fuse.DirentType(e.Type)
+		if e.Inode != 0 {
+			fuseEnts[i].Inode = inodes.get(localLogin, e.Dev, e.Inode)
 		}
 	}
-	fmt.Println("readdir returning", len(fuseEnts), err)
-	return fuseEnts, err
+	return fuseEnts, nil
 }
 
 func (f *LocalFile) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
 	if req.Size > 4096 * 16 {
 		req.Size = 4096 * 16
 	}
-	resp.Data = make([]byte, 0, req.Size)
+	resp.Data = make([]byte, req.Size)
 	n, err := unix.Pread(f.fd, resp.Data, req.Offset)
 	if err != nil {
 		return err
@@ -368,7 +378,6 @@ func (f *LocalFile) Release(ctx context.Context, req *fuse.ReleaseRequest) error
 	delete(f.ln.md.openFiles, f.fd)
 	f.ln.md.lock.Unlock()
 
-	fmt.Println("close", f.fd, f.ln.Path)
 	err := unix.Close(f.fd)
 	var err2 error
 	if f.osFile != nil {
@@ -0,0 +1,51 @@
+package fscore
+
+import (
+	"io"
+	"strings"
+)
+
+// aclFile is the name of the file that lets the owner share a directory
+// with particular logins, without opening it to everyone. It lists logins
+// and @groups (see FS42.Groups), separated by spaces or newlines; lines
+// starting with # are comments. The listed peers may read the directory and
+// everything below it, whatever the "other" bits say. Grants only add to
+// the "other" bits, and never allow writing.
+const aclFile = ".42fs-acl"
+
+func parseACL(r io.Reader) interface{} {
+	names := make(map[string]bool)
+	for _, name := range controlWords(r) {
+		names[name] = true
+	}
+	return names
+}
+
+// aclGrants reports whether the ACL file in dir, relative to the public
+// directory, lets login read it.
+func (ps *PeerServer) aclGrants(dir string, login string) bool {
+	if login == ps.ld.fs42.WhoAmI() {
+		// the snapshot syncer reads as the owner, and the snapshot must
+		// only hold what every peer may read
+		return false
+	}
+	names, _ := ps.ld.control.load(ps.ld, dir+"/"+aclFile, parseACL).(map[string]bool)
+	if names == nil {
+		return false
+	}
+	// "@" only names groups, whatever login the coordinator let through
+	if names[login] && !strings.HasPrefix(login, "@") {
+		return true
+	}
+	for group, members := range ps.ld.fs42.Groups {
+		if !names["@"+group] {
+			continue
+		}
+		for _, m := range members {
+			if m == login {
+				return true
+			}
+		}
+	}
+	return false
+}
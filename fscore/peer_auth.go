@@ -0,0 +1,143 @@
+package fscore
+
+import (
+	"path"
+	"strings"
+
+	"bazil.org/fuse"
+	"golang.org/x/sys/unix"
+)
+
+// Remote readers are always "other" as far as the owner's files are
+// concerned: the daemon runs as the owner, so every permission check on the
+// serving side has to be done here against the world bits instead of by the
+// kernel. The owner can extend them for particular logins with ACL files,
+// see peer_acl.go.
+
+const (
+	otherRead   = unix.S_IROTH
+	otherWrite  = unix.S_IWOTH
+	otherSearch = unix.S_IXOTH
+)
+
+// otherBits converts an access(2) mask to the matching "other" mode bits.
+func otherBits(mask uint32) uint32 {
+	var bits uint32
+	if mask&unix.R_OK != 0 {
+		bits |= otherRead
+	}
+	if mask&unix.W_OK != 0 {
+		bits |= otherWrite
+	}
+	if mask&unix.X_OK != 0 {
+		bits |= otherSearch
+	}
+	return bits
+}
+
+// ownerBits converts an access(2) mask to the matching owner mode bits.
+func ownerBits(mask uint32) uint32 {
+	return otherBits(mask) << 6
+}
+
+// aclBits are the bits an ACL file grants, for directories and for other
+// files.
+const (
+	aclDirBits  = otherRead | otherSearch
+	aclFileBits = otherRead
+)
+
+// allowed reports whether the mode of a file lets a peer have the access in
+// mask, an access(2) mask.
+func allowed(mode uint32, mask uint32, granted bool) bool {
+	have := mode
+	if granted && mode&unix.S_IFMT == unix.S_IFDIR {
+		have |= aclDirBits
+	} else if granted && mode&unix.S_IFMT == unix.S_IFREG {
+		have |= aclFileBits
+	}
+	want := otherBits(mask)
+	return have&want == want
+}
+
+// search verifies that login may traverse every directory leading to p,
+// starting with the public directory itself, and reports whether an ACL
+// file in one of them grants login read access to p.
+func (ps *PeerServer) search(login, p string) (bool, error) {
+	p = path.Clean("/" + p)
+	dir := "."
+	granted := false
+	var stat_t unix.Stat_t
+	components := strings.Split(p, "/")
+	// components[0] is always empty; the last one is p itself
+	for i := 0; i < len(components)-1; i++ {
+		if components[i] != "" {
+			dir = dir + "/" + components[i]
+		}
+		err := ps.ld.lstat(dir, &stat_t)
+		if err != nil {
+			return false, err
+		}
+		if stat_t.Mode&unix.S_IFMT != unix.S_IFDIR {
+			return false, fuse.Errno(unix.EACCES)
+		}
+		granted = granted || ps.aclGrants(dir, login)
+		if !allowed(uint32(stat_t.Mode), unix.X_OK, granted) {
+			return false, fuse.Errno(unix.EACCES)
+		}
+	}
+	return granted, nil
+}
+
+// checkSearch verifies that login may traverse every directory leading to
+// p.
+func (ps *PeerServer) checkSearch(login, p string) error {
+	_, err := ps.search(login, p)
+	return err
+}
+
+// access verifies that login may reach p and has the access in mask (an
+// access(2) mask) on it. F_OK only requires that p can be reached. It
+// reports whether an ACL grants login read access to p; symlinks are never
+// granted anything, as their target may be outside the shared directory.
+func (ps *PeerServer) access(login, p string, mask uint32) (bool, error) {
+	granted, err := ps.search(login, p)
+	if err != nil {
+		return false, err
+	}
+	var stat_t unix.Stat_t
+	rel := ps.nodeAt(p).Path
+	err = ps.ld.lstat(rel, &stat_t)
+	if err != nil {
+		return false, err
+	}
+	switch stat_t.Mode & unix.S_IFMT {
+	case unix.S_IFDIR:
+		granted = granted || ps.aclGrants(rel, login)
+	case unix.S_IFLNK:
+		granted = false
+	}
+	if !allowed(uint32(stat_t.Mode), mask, granted) {
+		return false, fuse.Errno(unix.EACCES)
+	}
+	return granted, nil
+}
+
+func (ps *PeerServer) checkAccess(login, p string, mask uint32) error {
+	_, err := ps.access(login, p, mask)
+	return err
+}
+
+// checkOpened verifies the object that was actually opened, which is not
+// necessarily the one checkAccess looked at if it was replaced since.
+func checkOpened(lf *LocalFile, mask uint32, granted bool) error {
+	var stat_t unix.Stat_t
+	err := unix.Fstat(lf.fd, &stat_t)
+	if err != nil {
+		return err
+	}
+	if !allowed(uint32(stat_t.Mode), mask, granted) {
+		return fuse.Errno(unix.EACCES)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package fscore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bazil.org/fuse"
+	"github.com/riking/42fs/auth"
+	"golang.org/x/sys/unix"
+)
+
+var errAccess = fuse.Errno(unix.EACCES)
+
+func as(login string) context.Context {
+	return auth.NewContext(context.Background(), &auth.Identity{Login: login})
+}
+
+// openAs opens p in ps for reading as login, and closes it again.
+func openAs(ps *PeerServer, login, p string, dir bool) error {
+	_, fd, err := ps.Open(as(login), p, dir, 0)
+	if err == nil {
+		ps.Close(as(login), fd)
+	}
+	return err
+}
+
+func (o *testOwner) chmod(t *testing.T, name string, mode os.FileMode) {
+	if err := os.Chmod(filepath.Join(o.dir, name), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPeerPathPermissions(t *testing.T) {
+	alice := newTestOwner(t)
+	alice.mkdir(t, "open")
+	alice.writeFile(t, "open/f", "x")
+	alice.writeFile(t, "open/private", "x")
+	alice.chmod(t, "open/private", 0640)
+	alice.mkdir(t, "closed")
+	alice.mkdir(t, "closed/sub")
+	alice.writeFile(t, "closed/sub/f", "x")
+	alice.chmod(t, "closed", 0700)
+	alice.mkdir(t, "hidden")
+	alice.writeFile(t, "hidden/f", "x")
+	alice.chmod(t, "hidden", 0711)
+	ps := NewPeerServer(alice.fs42)
+
+	for _, c := range []struct {
+		p    string
+		dir  bool
+		want error
+	}{
+		{"/open", true, nil},
+		{"/open/f", false, nil},
+		{"/open/private", false, errAccess},
+		// a 0700 component blocks everything below it
+		{"/closed", true, errAccess},
+		{"/closed/sub", true, errAccess},
+		{"/closed/sub/f", false, errAccess},
+		// search without read: names can be used but not listed
+		{"/hidden", true, errAccess},
+		{"/hidden/f", false, nil},
+	} {
+		if err := openAs(ps, "bob", c.p, c.dir); err != c.want {
+			t.Errorf("open %s: got %v, want %v", c.p, err, c.want)
+		}
+	}
+	if _, err := ps.Stat(as("bob"), "/closed/sub/f"); err != errAccess {
+		t.Errorf("stat below a 0700 directory: got %v, want EACCES", err)
+	}
+	if err := ps.Access(as("bob"), "/open/f", unix.W_OK); err != errAccess {
+		t.Errorf("write access to a 0644 file: got %v, want EACCES", err)
+	}
+}
+
+func TestPeerACL(t *testing.T) {
+	alice := newTestOwner(t)
+	alice.fs42.Groups = map[string][]string{"staff": {"dave"}}
+	alice.mkdir(t, "shared")
+	alice.mkdir(t, "shared/sub")
+	alice.writeFile(t, "shared/sub/f", "x")
+	alice.writeFile(t, "shared/"+aclFile, "# who may read\nbob @staff\n")
+	alice.chmod(t, "shared/sub/f", 0600)
+	alice.chmod(t, "shared/sub", 0700)
+	alice.chmod(t, "shared", 0700)
+	ps := NewPeerServer(alice.fs42)
+
+	for _, c := range []struct {
+		login string
+		want  error
+	}{
+		{"bob", nil},
+		{"dave", nil},
+		{"carol", errAccess},
+		{"staff", errAccess},
+		{"@staff", errAccess},
+	} {
+		for _, p := range []string{"/shared", "/shared/sub"} {
+			if err := openAs(ps, c.login, p, true); err != c.want {
+				t.Errorf("%s listing %s: got %v, want %v", c.login, p, err, c.want)
+			}
+		}
+		if err := openAs(ps, c.login, "/shared/sub/f", false); err != c.want {
+			t.Errorf("%s reading shared/sub/f: got %v, want %v", c.login, err, c.want)
+		}
+	}
+	// grants never allow writing
+	if err := ps.Access(as("bob"), "/shared/sub/f", unix.W_OK); err != errAccess {
+		t.Errorf("write access through an ACL: got %v, want EACCES", err)
+	}
+	// and don't apply outside the directory they are in
+	alice.mkdir(t, "other")
+	alice.chmod(t, "other", 0700)
+	if err := openAs(ps, "bob", "/other", true); err != errAccess {
+		t.Errorf("bob listing other: got %v, want EACCES", err)
+	}
+}
+
+func TestCheckOpenedAfterSwap(t *testing.T) {
+	alice := newTestOwner(t)
+	alice.writeFile(t, "f", "public")
+	alice.writeFile(t, "secret", "private")
+	alice.chmod(t, "secret", 0600)
+	ps := NewPeerServer(alice.fs42)
+
+	granted, err := ps.access("bob", "/f", unix.R_OK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// between the check and the open, f is replaced
+	if err := os.Rename(filepath.Join(alice.dir, "secret"), filepath.Join(alice.dir, "f")); err != nil {
+		t.Fatal(err)
+	}
+	h, err := ps.nodeAt("/f").Open(context.Background(), &fuse.OpenRequest{}, &fuse.OpenResponse{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lf := h.(*LocalFile)
+	defer lf.Release(context.Background(), &fuse.ReleaseRequest{})
+	if err := checkOpened(lf, unix.R_OK, granted); err != errAccess {
+		t.Errorf("checkOpened on the swapped file: got %v, want EACCES", err)
+	}
+	if err := checkOpened(lf, unix.W_OK, true); err != errAccess {
+		t.Errorf("an ACL grant opened a 0600 file to writing: got %v", err)
+	}
+}
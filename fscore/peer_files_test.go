@@ -0,0 +1,77 @@
+package fscore
+
+import (
+	"testing"
+	"time"
+
+	"bazil.org/fuse"
+	"golang.org/x/sys/unix"
+)
+
+func TestPeerFileLimit(t *testing.T) {
+	alice := newTestOwner(t)
+	alice.writeFile(t, "f", "x")
+	ps := NewPeerServer(alice.fs42)
+
+	var fds []uint64
+	for i := 0; i < maxPeerFiles; i++ {
+		_, fd, err := ps.Open(as("bob"), "/f", false, 0)
+		if err != nil {
+			t.Fatalf("open %d: %v", i, err)
+		}
+		fds = append(fds, fd)
+	}
+	if _, _, err := ps.Open(as("bob"), "/f", false, 0); err != fuse.Errno(unix.EMFILE) {
+		t.Errorf("open past the limit: got %v, want EMFILE", err)
+	}
+	// the limit is per login
+	if err := openAs(ps, "carol", "/f", false); err != nil {
+		t.Errorf("carol: %v", err)
+	}
+	if err := ps.Close(as("bob"), fds[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := openAs(ps, "bob", "/f", false); err != nil {
+		t.Errorf("open after closing one: %v", err)
+	}
+}
+
+func TestPeerFileIdle(t *testing.T) {
+	alice := newTestOwner(t)
+	alice.writeFile(t, "f", "x")
+	ps := NewPeerServer(alice.fs42)
+
+	_, idle, err := ps.Open(as("bob"), "/f", false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, busy, err := ps.Open(as("bob"), "/f", false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close(as("bob"), busy)
+	ps.lock.Lock()
+	lf := ps.files[idle].lf
+	ps.files[idle].used = time.Now().Add(-peerFileIdle)
+	ps.files[busy].used = time.Now().Add(-peerFileIdle + time.Minute)
+	ps.swept = time.Time{}
+	ps.lock.Unlock()
+
+	// the next open closes what went unused
+	if err := openAs(ps, "carol", "/f", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ps.file(as("bob"), idle); err != fuse.Errno(unix.EBADF) {
+		t.Errorf("idle handle: got %v, want EBADF", err)
+	}
+	ld := alice.fs42.local
+	ld.lock.Lock()
+	_, open := ld.openFiles[lf.fd]
+	ld.lock.Unlock()
+	if open {
+		t.Error("the idle file was not released")
+	}
+	if _, err := ps.file(as("bob"), busy); err != nil {
+		t.Errorf("recently used handle: %v", err)
+	}
+}
@@ -0,0 +1,463 @@
+package fscore
+
+import (
+	"context"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/riking/42fs/auth"
+	fgrpc "github.com/riking/42fs/grpc"
+
+	"bazil.org/fuse"
+	"golang.org/x/sys/unix"
+)
+
+// PeerServer serves the owner's LocalDir to other daemons. It implements
+// UserConnection so it can be registered on a gRPC server with
+// fgrpc.RegisterUserConnection, behind the session's token-checking
+// interceptor. Every call is checked against the "other" permission bits
+// and the owner's ACL files, see peer_auth.go.
+type PeerServer struct {
+	ld *LocalDir
+
+	lock   sync.Mutex
+	nextFD uint64
+	files  map[uint64]*peerFile
+	// swept is when idle files were last closed
+	swept time.Time
+
+	// draining is closed by Drain
+	draining  chan struct{}
+	drainOnce sync.Once
+}
+
+type peerFile struct {
+	lf    *LocalFile
+	dir   bool
+	write bool
+	owner string
+	// used is when the file was last read or written
+	used time.Time
+}
+
+// Peers can go away without closing what they opened, so files that go
+// unused for peerFileIdle are closed for them, and no login may have more
+// than maxPeerFiles open at once.
+const (
+	peerFileIdle = time.Hour
+	maxPeerFiles = 256
+)
+
+var _ fgrpc.UserConnection = &PeerServer{}
+
+func NewPeerServer(fs42 *FS42) *PeerServer {
+	return &PeerServer{
+		ld:     fs42.local,
+		nextFD: 1,
+		files:  make(map[uint64]*peerFile),
+
+		draining: make(chan struct{}),
+	}
+}
+
+// Drain ends every Subscribe stream, and makes new ones end right away, so
+// that stopping the gRPC server gracefully only waits for the calls in
+// flight. Readers take the end of the stream as a sign that their caches
+// may be stale.
+func (ps *PeerServer) Drain() {
+	ps.drainOnce.Do(func() { close(ps.draining) })
+}
+
+// nodeAt resolves a path sent by a peer to a node under the public
+// directory. The node is not entered into the path cache, which belongs to
+// the kernel's view of the filesystem.
+func (ps *PeerServer) nodeAt(p string) *LocalNode {
+	p = path.Clean("/" + p)
+	if p == "/" {
+		return &ps.ld.LocalNode
+	}
+	return &LocalNode{md: ps.ld, Path: "." + p}
+}
+
+// resolve applies the symlink policy to a path sent by login, so that the
+// checks and the call see what it leads to. Entries are only looked at if
+// login may reach them, and ignored ones don't exist.
+func (ps *PeerServer) resolve(login, p string) (string, error) {
+	rel := ps.nodeAt(p).Path
+	if ps.ld.ignored(rel) {
+		return "", fuse.ENOENT
+	}
+	return ps.ld.resolve(rel, ps.ld.symlinkPolicy(), ps.reachCheck(login))
+}
+
+// resolveParent is resolve for calls on the entry p itself, which is not
+// followed if it is a symlink.
+func (ps *PeerServer) resolveParent(login, p string) (string, error) {
+	p = path.Clean("/" + p)
+	if p == "/" {
+		return ".", nil
+	}
+	dir, err := ps.resolve(login, path.Dir(p))
+	if err != nil {
+		return "", err
+	}
+	return dir + "/" + path.Base(p), nil
+}
+
+// reachCheck returns a check for LocalDir.resolve that refuses entries
+// login can't see.
+func (ps *PeerServer) reachCheck(login string) func(p string) error {
+	return func(p string) error {
+		if ps.ld.ignored(p) {
+			return fuse.ENOENT
+		}
+		return ps.checkSearch(login, p)
+	}
+}
+
+// caller returns the verified login of the daemon making a call.
+func caller(ctx context.Context) (string, error) {
+	id, ok := auth.FromContext(ctx)
+	if !ok {
+		return "", fuse.Errno(unix.EACCES)
+	}
+	return id.Login, nil
+}
+
+// file looks up a handle opened by the calling daemon. Handles are not
+// shared between peers.
+func (ps *PeerServer) file(ctx context.Context, fd uint64) (*peerFile, error) {
+	login, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	pf, ok := ps.files[fd]
+	if !ok || pf.owner != login {
+		return nil, fuse.Errno(unix.EBADF)
+	}
+	pf.used = time.Now()
+	return pf, nil
+}
+
+func attrToFileAttr(a *fuse.Attr, dev uint64) *fgrpc.FileAttr {
+	return &fgrpc.FileAttr{
+		INode:     a.Inode,
+		Size:      a.Size,
+		Blocks:    a.Blocks,
+		Mtime:     a.Mtime,
+		Ctime:     a.Ctime,
+		BirthTime: a.Crtime,
+		Nlink:     a.Nlink,
+		Uid:       a.Uid,
+		Gid:       a.Gid,
+		BlockSize: a.BlockSize,
+		Mode:      a.Mode,
+		Dev:       dev,
+	}
+}
+
+func (ps *PeerServer) Access(ctx context.Context, p string, mode uint32) error {
+	login, err := caller(ctx)
+	if err == nil {
+		p, err = ps.resolve(login, p)
+	}
+	if err != nil {
+		return err
+	}
+	return ps.checkAccess(login, p, mode)
+}
+
+func (ps *PeerServer) Stat(ctx context.Context, p string) (*fgrpc.FileAttr, error) {
+	login, err := caller(ctx)
+	if err == nil {
+		p, err = ps.resolve(login, p)
+	}
+	if err == nil {
+		err = ps.checkSearch(login, p)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var a fuse.Attr
+	dev, err := ps.nodeAt(p).statAttr(&a)
+	if err != nil {
+		return nil, err
+	}
+	return attrToFileAttr(&a, dev), nil
+}
+
+func (ps *PeerServer) Getxattr(ctx context.Context, p string, attr string, size uint32, position uint32) ([]byte, error) {
+	login, err := caller(ctx)
+	if err == nil {
+		p, err = ps.resolve(login, p)
+	}
+	if err == nil {
+		err = ps.checkAccess(login, p, unix.R_OK)
+	}
+	if err != nil {
+		return nil, err
+	}
+	req := &fuse.GetxattrRequest{Name: attr, Size: size, Position: position}
+	var resp fuse.GetxattrResponse
+	err = ps.nodeAt(p).Getxattr(ctx, req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Xattr, nil
+}
+
+func (ps *PeerServer) Listxattr(ctx context.Context, p string, size uint32, position uint32) ([]byte, error) {
+	login, err := caller(ctx)
+	if err == nil {
+		p, err = ps.resolve(login, p)
+	}
+	if err == nil {
+		err = ps.checkAccess(login, p, unix.R_OK)
+	}
+	if err != nil {
+		return nil, err
+	}
+	req := &fuse.ListxattrRequest{Size: size, Position: position}
+	var resp fuse.ListxattrResponse
+	err = ps.nodeAt(p).Listxattr(ctx, req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Xattr, nil
+}
+
+func (ps *PeerServer) Open(ctx context.Context, p string, dir bool, flags fgrpc.AgnosticOpenFlags) (fuse.OpenResponseFlags, uint64, error) {
+	sysFlags := flags.ToSys()
+	if sysFlags&unix.O_CREAT != 0 {
+		return 0, 0, fuse.Errno(unix.EROFS)
+	}
+	login, err := caller(ctx)
+	if err == nil {
+		p, err = ps.resolve(login, p)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	var mask uint32
+	switch sysFlags & unix.O_ACCMODE {
+	case unix.O_RDONLY:
+		mask = unix.R_OK
+	case unix.O_WRONLY:
+		mask = unix.W_OK
+	default:
+		mask = unix.R_OK | unix.W_OK
+	}
+	write := mask&unix.W_OK != 0 || sysFlags&unix.O_TRUNC != 0
+	var granted bool
+	if write {
+		if dir {
+			return 0, 0, fuse.Errno(unix.EISDIR)
+		}
+		// only the creator of a file in the dropbox may write to it
+		mask |= unix.W_OK
+		err = ps.checkInDropbox(p)
+		if err == nil {
+			err = ps.checkCreatorAccess(login, p, mask)
+		}
+	} else {
+		granted, err = ps.access(login, p, mask)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	req := &fuse.OpenRequest{Dir: dir, Flags: sysFlags}
+	var resp fuse.OpenResponse
+	h, err := ps.nodeAt(p).Open(ctx, req, &resp)
+	if err != nil {
+		return 0, 0, err
+	}
+	lf := h.(*LocalFile)
+	if write {
+		err = checkCreatorOpened(lf.fd, login, mask)
+	} else {
+		err = checkOpened(lf, mask, granted)
+	}
+	if err != nil {
+		lf.Release(ctx, &fuse.ReleaseRequest{})
+		return 0, 0, err
+	}
+	fd, err := ps.addFile(&peerFile{lf: lf, dir: dir, write: write, owner: login})
+	if err != nil {
+		lf.Release(ctx, &fuse.ReleaseRequest{})
+		return 0, 0, err
+	}
+	return resp.Flags, fd, nil
+}
+
+// addFile hands out a descriptor for pf, unless its owner already has
+// maxPeerFiles open.
+func (ps *PeerServer) addFile(pf *peerFile) (uint64, error) {
+	ps.closeIdle()
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+	n := 0
+	for _, f := range ps.files {
+		if f.owner == pf.owner {
+			n++
+		}
+	}
+	if n >= maxPeerFiles {
+		return 0, fuse.Errno(unix.EMFILE)
+	}
+	fd := ps.nextFD
+	ps.nextFD++
+	pf.used = time.Now()
+	ps.files[fd] = pf
+	return fd, nil
+}
+
+// closeIdle closes the files that went unused for peerFileIdle. It looks
+// at most once a minute.
+func (ps *PeerServer) closeIdle() {
+	now := time.Now()
+	var idle []*peerFile
+	ps.lock.Lock()
+	if now.Sub(ps.swept) >= time.Minute {
+		ps.swept = now
+		for fd, pf := range ps.files {
+			if now.Sub(pf.used) >= peerFileIdle {
+				idle = append(idle, pf)
+				delete(ps.files, fd)
+			}
+		}
+	}
+	ps.lock.Unlock()
+	for _, pf := range idle {
+		pf.lf.Release(context.Background(), &fuse.ReleaseRequest{})
+	}
+}
+
+func (ps *PeerServer) Readlink(ctx context.Context, p string) (string, error) {
+	login, err := caller(ctx)
+	if err == nil {
+		p, err = ps.resolve(login, p)
+	}
+	if err == nil {
+		err = ps.checkSearch(login, p)
+	}
+	if err != nil {
+		return "", err
+	}
+	return ps.nodeAt(p).Readlink(ctx, &fuse.ReadlinkRequest{})
+}
+
+func (ps *PeerServer) LookupExists(ctx context.Context, p string) error {
+	login, err := caller(ctx)
+	if err == nil {
+		p, err = ps.resolve(login, p)
+	}
+	if err == nil {
+		err = ps.checkSearch(login, p)
+	}
+	if err != nil {
+		return err
+	}
+	var a fuse.Attr
+	_, err = ps.nodeAt(p).statAttr(&a)
+	return err
+}
+
+func (ps *PeerServer) ReadDir(ctx context.Context, fd uint64) ([]fgrpc.Dirent, error) {
+	pf, err := ps.file(ctx, fd)
+	if err != nil {
+		return nil, err
+	}
+	if !pf.dir {
+		return nil, fuse.Errno(unix.ENOTDIR)
+	}
+	ents, err := pf.lf.readDir(ps.reachCheck(pf.owner))
+	if err != nil {
+		return nil, err
+	}
+	// leave out what is ignored
+	dir, err := splitRel(pf.lf.ln.Path)
+	if err != nil {
+		return nil, err
+	}
+	lists := ps.ld.ignoreLists()
+	shown := ents[:0]
+	for _, e := range ents {
+		names := append(dir[:len(dir):len(dir)], e.Name)
+		if !hides(lists, names, fuse.DirentType(e.Type) == fuse.DT_Dir) {
+			shown = append(shown, e)
+		}
+	}
+	return shown, nil
+}
+
+func (ps *PeerServer) ReadFrom(ctx context.Context, req *fgrpc.ReadRequest) ([]byte, error) {
+	if req.Size < 0 || req.Offset < 0 {
+		return nil, fuse.Errno(unix.EINVAL)
+	}
+	pf, err := ps.file(ctx, req.FD)
+	if err != nil {
+		return nil, err
+	}
+	if pf.dir || req.Dir {
+		return nil, fuse.Errno(unix.EISDIR)
+	}
+	fReq := &fuse.ReadRequest{
+		Offset:    req.Offset,
+		Size:      req.Size,
+		FileFlags: req.FileFlags,
+	}
+	var resp fuse.ReadResponse
+	err = pf.lf.Read(ctx, fReq, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+func (ps *PeerServer) Close(ctx context.Context, fd uint64) error {
+	pf, err := ps.file(ctx, fd)
+	if err != nil {
+		return err
+	}
+	ps.lock.Lock()
+	delete(ps.files, fd)
+	ps.lock.Unlock()
+
+	return pf.lf.Release(ctx, &fuse.ReleaseRequest{})
+}
+
+// Subscribe sends the changes under the public directory, leaving out the
+// ones in directories the caller couldn't reach.
+func (ps *PeerServer) Subscribe(ctx context.Context, events chan<- fgrpc.ChangeEvent) error {
+	login, err := caller(ctx)
+	if err != nil {
+		return err
+	}
+	sub, err := ps.ld.changes.subscribe()
+	if err != nil {
+		return err
+	}
+	defer ps.ld.changes.unsubscribe(sub)
+	for {
+		var ev fgrpc.ChangeEvent
+		select {
+		case ev = <-sub.c:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ps.draining:
+			return nil
+		}
+		if !ev.Overflow && (ps.ld.ignored(ps.nodeAt(ev.Path).Path) || ps.checkSearch(login, ev.Path) != nil) {
+			continue
+		}
+		select {
+		case events <- ev:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package fscore_test
+
+import (
+	"context"
+	"testing"
+
+	"bazil.org/fuse"
+	"github.com/riking/42fs/fs42test"
+	"github.com/riking/42fs/fscore"
+	fgrpc "github.com/riking/42fs/grpc"
+	"golang.org/x/sys/unix"
+)
+
+func TestReadFromBounds(t *testing.T) {
+	ctx := context.Background()
+	c := fs42test.NewCoordinator()
+	alice := c.AddUser(t, "alice")
+	alice.WriteFile(t, "f", "hello", 0644)
+	dc := fs42test.NewDirConn(fscore.NewPeerServer(alice.FS42), "bob")
+	_, fd, err := dc.Open(ctx, "/f", false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dc.Close(ctx, fd)
+
+	for _, req := range []fgrpc.ReadRequest{
+		{FD: fd, Size: -1},
+		{FD: fd, Offset: -1, Size: 5},
+	} {
+		_, err := dc.ReadFrom(ctx, &req)
+		if err != fuse.Errno(unix.EINVAL) {
+			t.Errorf("read of %d bytes at %d returned %v, want EINVAL", req.Size, req.Offset, err)
+		}
+	}
+	b, err := dc.ReadFrom(ctx, &fgrpc.ReadRequest{FD: fd, Offset: 1, Size: 3})
+	if err != nil || string(b) != "ell" {
+		t.Errorf("read returned %q, %v", b, err)
+	}
+}
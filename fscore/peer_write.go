@@ -0,0 +1,306 @@
+package fscore
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+
+	fgrpc "github.com/riking/42fs/grpc"
+
+	"bazil.org/fuse"
+	"golang.org/x/sys/unix"
+)
+
+// Peers may only write inside FS42.Dropbox. Everything created there is
+// owned by the owner on disk, so the login of whoever created an entry is
+// kept in an extended attribute: like in a sticky directory, only they may
+// remove, rename or change it, and the owner bits of its mode apply to them
+// as if they owned it. Creating entries also needs the "other" write and
+// search bits on the parent directory.
+
+const creatorXattr = "user.42fs.creator"
+
+// checkInDropbox verifies that p is inside the dropbox, and not the dropbox
+// itself.
+func (ps *PeerServer) checkInDropbox(p string) error {
+	box := ps.ld.fs42.Dropbox
+	if box == "" {
+		return fuse.Errno(unix.EROFS)
+	}
+	box = path.Clean("/" + box)
+	p = path.Clean("/" + p)
+	if p == box || box != "/" && !strings.HasPrefix(p, box+"/") {
+		return fuse.Errno(unix.EROFS)
+	}
+	return nil
+}
+
+// checkEntryChange verifies that login may create or remove the entry p.
+// Control files can't be created: they would let the peer choose how the
+// dropbox is shared, and an ACL file would let them read what others put
+// in it. Neither can ignored entries, which peers don't see, whether they
+// would be files or directories.
+func (ps *PeerServer) checkEntryChange(login, p string) error {
+	err := ps.checkInDropbox(p)
+	if err != nil {
+		return err
+	}
+	if isControlFile(path.Base(p)) || ps.ld.mayBeIgnored(ps.nodeAt(p).Path) {
+		return fuse.Errno(unix.EPERM)
+	}
+	// in a directory they created, the peer has the owner bits
+	parent := path.Dir(path.Clean("/" + p))
+	if ps.checkCreator(parent, login) == nil {
+		return ps.checkCreatorAccess(login, parent, unix.W_OK|unix.X_OK)
+	}
+	return ps.checkAccess(login, parent, unix.W_OK|unix.X_OK)
+}
+
+// checkCreator verifies that login created the entry at p.
+func (ps *PeerServer) checkCreator(p string, login string) error {
+	b := make([]byte, 256)
+	n, err := ps.ld.getxattr(ps.nodeAt(p).Path, creatorXattr, b)
+	return creatorIs(login, b, n, err)
+}
+
+// creatorIs checks the result of reading creatorXattr into b against login.
+func creatorIs(login string, b []byte, n int, err error) error {
+	if err == errNoXattr || err == nil && string(b[:n]) != login {
+		return fuse.Errno(unix.EPERM)
+	}
+	return err
+}
+
+// checkCreatorAccess verifies that login may reach p, created it, and has
+// the access in mask (an access(2) mask) on it by the owner bits.
+func (ps *PeerServer) checkCreatorAccess(login, p string, mask uint32) error {
+	err := ps.checkSearch(login, p)
+	if err == nil {
+		err = ps.checkCreator(p, login)
+	}
+	if err != nil {
+		return err
+	}
+	var stat_t unix.Stat_t
+	err = ps.ld.lstat(ps.nodeAt(p).Path, &stat_t)
+	if err != nil {
+		return err
+	}
+	want := ownerBits(mask)
+	if uint32(stat_t.Mode)&want != want {
+		return fuse.Errno(unix.EACCES)
+	}
+	return nil
+}
+
+// checkCreatorOpened is checkOpened for a file opened by its creator.
+func checkCreatorOpened(fd int, login string, mask uint32) error {
+	b := make([]byte, 256)
+	n, err := unix.Fgetxattr(fd, creatorXattr, b)
+	err = creatorIs(login, b, n, err)
+	if err != nil {
+		return err
+	}
+	var stat_t unix.Stat_t
+	err = unix.Fstat(fd, &stat_t)
+	if err != nil {
+		return err
+	}
+	want := ownerBits(mask)
+	if uint32(stat_t.Mode)&want != want {
+		return fuse.Errno(unix.EACCES)
+	}
+	return nil
+}
+
+func (ps *PeerServer) Create(ctx context.Context, p string, mode os.FileMode, flags fgrpc.AgnosticOpenFlags) (fuse.OpenResponseFlags, uint64, error) {
+	login, err := caller(ctx)
+	if err == nil {
+		p, err = ps.resolveParent(login, p)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	err = ps.checkEntryChange(login, p)
+	if err != nil {
+		return 0, 0, err
+	}
+	sysFlags := flags.ToSys()
+	oflags := int(sysFlags&(unix.O_ACCMODE|unix.O_APPEND)) | unix.O_CREAT | unix.O_EXCL
+	ln := ps.nodeAt(p)
+	fd, err := ps.ld.open(ln.Path, oflags, uint32(mode.Perm()))
+	if err != nil {
+		return 0, 0, err
+	}
+	err = unix.Fsetxattr(fd, creatorXattr, []byte(login), 0)
+	if err != nil {
+		unix.Close(fd)
+		ps.ld.remove(ln.Path, false)
+		return 0, 0, err
+	}
+	pf := &peerFile{
+		lf:    &LocalFile{ln: ln, fd: fd},
+		write: sysFlags&unix.O_ACCMODE != unix.O_RDONLY,
+		owner: login,
+	}
+	handle, err := ps.addFile(pf)
+	if err != nil {
+		pf.lf.Release(ctx, &fuse.ReleaseRequest{})
+		ps.ld.remove(ln.Path, false)
+		return 0, 0, err
+	}
+	return 0, handle, nil
+}
+
+func (ps *PeerServer) Mkdir(ctx context.Context, p string, mode os.FileMode) error {
+	login, err := caller(ctx)
+	if err == nil {
+		p, err = ps.resolveParent(login, p)
+	}
+	if err != nil {
+		return err
+	}
+	err = ps.checkEntryChange(login, p)
+	if err != nil {
+		return err
+	}
+	rel := ps.nodeAt(p).Path
+	err = ps.ld.mkdir(rel, uint32(mode.Perm()))
+	if err != nil {
+		return err
+	}
+	err = ps.ld.setxattr(rel, creatorXattr, []byte(login))
+	if err != nil {
+		ps.ld.remove(rel, true)
+		return err
+	}
+	return nil
+}
+
+func (ps *PeerServer) Remove(ctx context.Context, p string, dir bool) error {
+	login, err := caller(ctx)
+	if err == nil {
+		p, err = ps.resolveParent(login, p)
+	}
+	if err != nil {
+		return err
+	}
+	err = ps.checkEntryChange(login, p)
+	if err != nil {
+		return err
+	}
+	err = ps.checkCreator(p, login)
+	if err != nil {
+		return err
+	}
+	return ps.ld.remove(ps.nodeAt(p).Path, dir)
+}
+
+func (ps *PeerServer) Rename(ctx context.Context, oldPath, newPath string) error {
+	login, err := caller(ctx)
+	if err == nil {
+		oldPath, err = ps.resolveParent(login, oldPath)
+	}
+	if err == nil {
+		newPath, err = ps.resolveParent(login, newPath)
+	}
+	if err != nil {
+		return err
+	}
+	for _, p := range []string{oldPath, newPath} {
+		err = ps.checkEntryChange(login, p)
+		if err != nil {
+			return err
+		}
+	}
+	err = ps.checkCreator(oldPath, login)
+	if err != nil {
+		return err
+	}
+	newRel := ps.nodeAt(newPath).Path
+	var stat_t unix.Stat_t
+	if ps.ld.lstat(newRel, &stat_t) == nil {
+		// replacing someone else's entry would remove it
+		err = ps.checkCreator(newPath, login)
+		if err != nil {
+			return err
+		}
+	}
+	return ps.ld.rename(ps.nodeAt(oldPath).Path, newRel)
+}
+
+// Setattr lets the creator of an entry truncate it, change its permission
+// bits and set its times. Like for the owner of a file, only truncating it
+// depends on its mode.
+func (ps *PeerServer) Setattr(ctx context.Context, req *fgrpc.SetattrRequest) (*fgrpc.FileAttr, error) {
+	login, err := caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p, err := ps.resolve(login, req.Path)
+	if err == nil {
+		err = ps.checkInDropbox(p)
+	}
+	var mask uint32
+	if req.SetSize {
+		mask = unix.W_OK
+	}
+	if err == nil {
+		err = ps.checkCreatorAccess(login, p, mask)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rel := ps.nodeAt(p).Path
+
+	if req.SetSize || req.SetMode {
+		oflags := unix.O_RDONLY
+		if req.SetSize {
+			oflags = unix.O_WRONLY
+		}
+		fd, err := ps.ld.open(rel, oflags, 0)
+		if err != nil {
+			return nil, err
+		}
+		err = checkCreatorOpened(fd, login, mask)
+		if err == nil && req.SetSize {
+			err = unix.Ftruncate(fd, int64(req.Size))
+		}
+		if err == nil && req.SetMode {
+			err = unix.Fchmod(fd, uint32(req.Mode.Perm()))
+		}
+		unix.Close(fd)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if req.SetAtime || req.SetMtime {
+		ts := []unix.Timespec{{Nsec: unix.UTIME_OMIT}, {Nsec: unix.UTIME_OMIT}}
+		if req.SetAtime {
+			ts[0] = unix.NsecToTimespec(req.Atime.UnixNano())
+		}
+		if req.SetMtime {
+			ts[1] = unix.NsecToTimespec(req.Mtime.UnixNano())
+		}
+		err = ps.ld.utimes(rel, ts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ps.Stat(ctx, p)
+}
+
+func (ps *PeerServer) WriteTo(ctx context.Context, req *fgrpc.WriteRequest) (int, error) {
+	pf, err := ps.file(ctx, req.FD)
+	if err != nil {
+		return 0, err
+	}
+	if !pf.write {
+		return 0, fuse.Errno(unix.EBADF)
+	}
+	fReq := &fuse.WriteRequest{Offset: req.Offset, Data: req.Data}
+	var resp fuse.WriteResponse
+	err = pf.lf.Write(ctx, fReq, &resp)
+	return resp.Size, err
+}
@@ -0,0 +1,265 @@
+package fscore_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bazil.org/fuse"
+	"github.com/riking/42fs/fs42test"
+	"github.com/riking/42fs/fscore"
+	fgrpc "github.com/riking/42fs/grpc"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	eperm  = fuse.Errno(unix.EPERM)
+	eacces = fuse.Errno(unix.EACCES)
+	erofs  = fuse.Errno(unix.EROFS)
+)
+
+// dropboxFixture is alice's directory with a world-writable dropbox, and
+// connections to it from bob and carol.
+type dropboxFixture struct {
+	alice      *fs42test.User
+	bob, carol *fs42test.DirConn
+}
+
+func newDropboxFixture(t *testing.T) *dropboxFixture {
+	c := fs42test.NewCoordinator()
+	alice := c.AddUser(t, "alice")
+	alice.FS42.Dropbox = "box"
+	alice.Mkdir(t, "box", 0777)
+	alice.WriteFile(t, "box/alice's", "mine", 0666)
+	ps := fscore.NewPeerServer(alice.FS42)
+	return &dropboxFixture{
+		alice: alice,
+		bob:   fs42test.NewDirConn(ps, "bob"),
+		carol: fs42test.NewDirConn(ps, "carol"),
+	}
+}
+
+// create makes p as dc, with contents written through the returned handle.
+func create(t *testing.T, dc *fs42test.DirConn, p string, mode os.FileMode, contents string) {
+	t.Helper()
+	ctx := context.Background()
+	_, fd, err := dc.Create(ctx, p, mode, fgrpc.ToAgnostic(unix.O_WRONLY))
+	if err != nil {
+		t.Fatalf("creating %s: %v", p, err)
+	}
+	defer dc.Close(ctx, fd)
+	_, err = dc.WriteTo(ctx, &fgrpc.WriteRequest{FD: fd, Data: []byte(contents)})
+	if err != nil {
+		t.Fatalf("writing %s: %v", p, err)
+	}
+}
+
+func (f *dropboxFixture) contents(t *testing.T, name string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(filepath.Join(f.alice.Dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestDropboxCreate(t *testing.T) {
+	ctx := context.Background()
+	f := newDropboxFixture(t)
+	f.alice.Mkdir(t, "other", 0777)
+	for p, want := range map[string]error{
+		"/f":             erofs,
+		"/other/f":       erofs,
+		"/box":           erofs,
+		"/box/.42fs-acl": eperm,
+		"/box/.env":      eperm,
+		"/box/.git":      eperm,
+	} {
+		_, fd, err := f.bob.Create(ctx, p, 0644, 0)
+		if err == nil {
+			f.bob.Close(ctx, fd)
+		}
+		if err != want {
+			t.Errorf("create %s returned %v, want %v", p, err, want)
+		}
+		if err := f.bob.Mkdir(ctx, p, 0755); err != want {
+			t.Errorf("mkdir %s returned %v, want %v", p, err, want)
+		}
+	}
+
+	create(t, f.bob, "/box/f", 0644, "from bob")
+	if got := f.contents(t, "box/f"); got != "from bob" {
+		t.Errorf("box/f holds %q", got)
+	}
+	_, _, err := f.carol.Create(ctx, "/box/f", 0644, 0)
+	if err != unix.EEXIST {
+		t.Errorf("creating an existing file returned %v, want EEXIST", err)
+	}
+}
+
+func TestDropboxCreatorDirectory(t *testing.T) {
+	ctx := context.Background()
+	f := newDropboxFixture(t)
+	// only the owner bits are set, which apply to bob as the creator
+	if err := f.bob.Mkdir(ctx, "/box/d", 0700); err != nil {
+		t.Fatal(err)
+	}
+	create(t, f.bob, "/box/d/f", 0644, "x")
+	if err := f.bob.Mkdir(ctx, "/box/d/sub", 0755); err != nil {
+		t.Errorf("mkdir in own directory: %v", err)
+	}
+	if _, _, err := f.carol.Create(ctx, "/box/d/g", 0644, 0); err != eacces {
+		t.Errorf("create in someone else's 0700 directory returned %v, want EACCES", err)
+	}
+
+	// and the other bits to everyone else
+	if err := f.bob.Mkdir(ctx, "/box/ro", 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(f.alice.Dir, "box/ro"), 0577); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := f.bob.Create(ctx, "/box/ro/f", 0644, 0); err != eacces {
+		t.Errorf("create in own read-only directory returned %v, want EACCES", err)
+	}
+	create(t, f.carol, "/box/ro/f", 0644, "x")
+}
+
+func TestDropboxRemove(t *testing.T) {
+	ctx := context.Background()
+	f := newDropboxFixture(t)
+	create(t, f.bob, "/box/f", 0644, "x")
+	if err := f.bob.Mkdir(ctx, "/box/d", 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		p   string
+		dir bool
+	}{{"/box/f", false}, {"/box/d", true}, {"/box/alice's", false}} {
+		if err := f.carol.Remove(ctx, c.p, c.dir); err != eperm {
+			t.Errorf("carol removing %s returned %v, want EPERM", c.p, err)
+		}
+	}
+	if err := f.bob.Remove(ctx, "/box/alice's", false); err != eperm {
+		t.Errorf("bob removing alice's file returned %v, want EPERM", err)
+	}
+	if err := f.bob.Remove(ctx, "/box", true); err != erofs {
+		t.Errorf("removing the dropbox returned %v, want EROFS", err)
+	}
+	if err := f.bob.Remove(ctx, "/box/f", false); err != nil {
+		t.Error(err)
+	}
+	if err := f.bob.Remove(ctx, "/box/d", true); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Lstat(filepath.Join(f.alice.Dir, "box/f")); !os.IsNotExist(err) {
+		t.Errorf("box/f is still there: %v", err)
+	}
+}
+
+func TestDropboxRename(t *testing.T) {
+	ctx := context.Background()
+	f := newDropboxFixture(t)
+	create(t, f.bob, "/box/bob's", 0644, "bob")
+	create(t, f.carol, "/box/carol's", 0644, "carol")
+
+	for _, c := range []struct {
+		from, to string
+		want     error
+	}{
+		// someone else's entry
+		{"/box/carol's", "/box/x", eperm},
+		{"/box/alice's", "/box/x", eperm},
+		// replacing it would remove it
+		{"/box/bob's", "/box/carol's", eperm},
+		{"/box/bob's", "/box/alice's", eperm},
+		// out of the dropbox, or to a name peers may not create
+		{"/box/bob's", "/bob's", erofs},
+		{"/box/bob's", "/box/.42fs-acl", eperm},
+		{"/box/bob's", "/box/.env", eperm},
+	} {
+		if err := f.bob.Rename(ctx, c.from, c.to); err != c.want {
+			t.Errorf("rename %s to %s returned %v, want %v", c.from, c.to, err, c.want)
+		}
+	}
+	if got := f.contents(t, "box/carol's"); got != "carol" {
+		t.Errorf("carol's file holds %q", got)
+	}
+
+	create(t, f.bob, "/box/old", 0644, "old")
+	if err := f.bob.Rename(ctx, "/box/bob's", "/box/old"); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.contents(t, "box/old"); got != "bob" {
+		t.Errorf("renamed file holds %q", got)
+	}
+}
+
+func TestDropboxSetattr(t *testing.T) {
+	ctx := context.Background()
+	f := newDropboxFixture(t)
+	create(t, f.bob, "/box/f", 0644, "contents")
+
+	truncate := &fgrpc.SetattrRequest{Path: "/box/f", SetSize: true, Size: 3}
+	if _, err := f.carol.Setattr(ctx, truncate); err != eperm {
+		t.Errorf("carol truncating bob's file returned %v, want EPERM", err)
+	}
+	if _, err := f.bob.Setattr(ctx, &fgrpc.SetattrRequest{Path: "/box/alice's", SetMode: true, Mode: 0777}); err != eperm {
+		t.Errorf("bob changing alice's file returned %v, want EPERM", err)
+	}
+
+	// changing the mode doesn't depend on it, truncating does
+	a, err := f.bob.Setattr(ctx, &fgrpc.SetattrRequest{Path: "/box/f", SetMode: true, Mode: 0444})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Mode.Perm() != 0444 {
+		t.Errorf("mode is %v after chmod 0444", a.Mode)
+	}
+	if _, err := f.bob.Setattr(ctx, truncate); err != eacces {
+		t.Errorf("truncating a read-only file returned %v, want EACCES", err)
+	}
+	if _, err := f.bob.Setattr(ctx, &fgrpc.SetattrRequest{Path: "/box/f", SetMode: true, Mode: 0644}); err != nil {
+		t.Fatal(err)
+	}
+	a, err = f.bob.Setattr(ctx, truncate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Size != 3 || f.contents(t, "box/f") != "con" {
+		t.Errorf("file is %d bytes after truncating to 3", a.Size)
+	}
+}
+
+func TestDropboxWriteTo(t *testing.T) {
+	ctx := context.Background()
+	f := newDropboxFixture(t)
+	_, fd, err := f.bob.Create(ctx, "/box/f", 0644, fgrpc.ToAgnostic(unix.O_RDWR))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := f.bob.WriteTo(ctx, &fgrpc.WriteRequest{FD: fd, Data: []byte("hello")})
+	if err != nil || n != 5 {
+		t.Errorf("write returned %d, %v", n, err)
+	}
+	if _, err := f.carol.WriteTo(ctx, &fgrpc.WriteRequest{FD: fd, Data: []byte("x")}); err == nil {
+		t.Error("carol wrote through bob's handle")
+	}
+	f.bob.Close(ctx, fd)
+
+	// handles from Open are read-only
+	_, fd, err = f.bob.Open(ctx, "/box/f", false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.bob.Close(ctx, fd)
+	if _, err := f.bob.WriteTo(ctx, &fgrpc.WriteRequest{FD: fd, Data: []byte("x")}); err != fuse.Errno(unix.EBADF) {
+		t.Errorf("write to a read-only handle returned %v, want EBADF", err)
+	}
+	if got := f.contents(t, "box/f"); got != "hello" {
+		t.Errorf("box/f holds %q", got)
+	}
+}
@@ -0,0 +1,95 @@
+package fscore
+
+import (
+	"crypto/tls"
+	"sync"
+
+	"github.com/riking/42fs/auth"
+	fgrpc "github.com/riking/42fs/grpc"
+
+	"bazil.org/fuse"
+	"golang.org/x/sys/unix"
+	"google.golang.org/grpc"
+)
+
+// peerPool keeps one connection per peer daemon.
+type peerPool struct {
+	session *auth.Session
+
+	lock  sync.Mutex
+	conns map[string]*peerConn
+}
+
+type peerConn struct {
+	cc *grpc.ClientConn
+	uc fgrpc.UserConnection
+}
+
+func newPeerPool(session *auth.Session) *peerPool {
+	return &peerPool{
+		session: session,
+		conns:   make(map[string]*peerConn),
+	}
+}
+
+// get returns a connection to login's daemon at addr.
+func (p *peerPool) get(login, addr string) (fgrpc.UserConnection, error) {
+	if addr == "" {
+		// the owner isn't logged in anywhere
+		return nil, fuse.Errno(unix.EHOSTDOWN)
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	key := login + "@" + addr
+	pc, ok := p.conns[key]
+	if ok {
+		return pc.uc, nil
+	}
+	var tlsConfig *tls.Config
+	if p.session.TLSEnabled() {
+		tlsConfig = p.session.PeerClientTLS(login)
+	}
+	cc, err := fgrpc.Dial(addr, tlsConfig, grpc.WithPerRPCCredentials(p.session))
+	if err != nil {
+		return nil, err
+	}
+	pc = &peerConn{cc: cc, uc: fgrpc.NewUserConnectionClient(cc)}
+	p.conns[key] = pc
+	return pc.uc, nil
+}
+
+func (p *peerPool) closeAll() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for key, pc := range p.conns {
+		pc.cc.Close()
+		delete(p.conns, key)
+	}
+}
+
+// userDir returns the UserDir for a login, pointed at the address the
+// coordinator last reported for it, or at the coordinator's snapshot if the
+// owner is offline. An in-process coordinator may hand out a connection for
+// an online owner too.
+func (fs42 *FS42) userDir(info *fgrpc.LoginInfo) (*UserDir, error) {
+	conn := info.Conn
+	if conn == nil {
+		var err error
+		conn, err = fs42.peers.get(info.Login, info.Addr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fs42.userLock.Lock()
+	defer fs42.userLock.Unlock()
+	ud, ok := fs42.userDirs[info.Login]
+	if !ok {
+		ud = NewUserDir(fs42, info)
+		fs42.userDirs[info.Login] = ud
+	}
+	ud.setConn(conn, info.Offline && info.Conn != nil)
+	return ud, nil
+}
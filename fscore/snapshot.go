@@ -0,0 +1,306 @@
+package fscore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/riking/42fs/auth"
+	fgrpc "github.com/riking/42fs/grpc"
+)
+
+// maxSnapshotSize caps the file contents included in a snapshot. Files that
+// would go over it are listed with Omitted set.
+const maxSnapshotSize = 64 << 20
+
+// chunkBatch is how many chunks are sent per PutChunks call.
+const chunkBatch = 32
+
+// syncAttempts bounds how many times a sync starts over because files
+// changed while they were being uploaded.
+const syncAttempts = 3
+
+var errSyncChanging = errors.New("42fs: public directory kept changing during snapshot sync")
+
+// SnapshotUploader is the part of the coordinator a SnapshotSyncer talks
+// to. fgrpc.CoordinatorClient implements it.
+type SnapshotUploader interface {
+	SyncManifest(ctx context.Context, m *fgrpc.Manifest) ([]string, error)
+	PutChunks(ctx context.Context, chunks [][]byte) error
+}
+
+// SnapshotSyncer keeps the coordinator's snapshot of the public directory
+// up to date, for it to serve while this daemon is offline. Each sync sends
+// a manifest of the tree and then only the chunks the coordinator doesn't
+// have yet, so an unchanged file is never sent twice and an interrupted
+// sync picks up where it left off.
+//
+// The tree is read through a PeerServer so the snapshot obeys the same
+// permission checks as live peer access; anything a peer couldn't read is
+// left out.
+type SnapshotSyncer struct {
+	fs42  *FS42
+	coord SnapshotUploader
+
+	lock sync.Mutex
+	// prev holds the entries of the last manifest built, so that files
+	// whose size and mtime haven't changed aren't hashed again.
+	prev map[string]*fgrpc.ManifestEntry
+}
+
+func NewSnapshotSyncer(fs42 *FS42, coord SnapshotUploader) *SnapshotSyncer {
+	return &SnapshotSyncer{
+		fs42:  fs42,
+		coord: coord,
+		prev:  make(map[string]*fgrpc.ManifestEntry),
+	}
+}
+
+// chunkRef says where to read a chunk from when the coordinator asks for it.
+type chunkRef struct {
+	path string
+	off  int64
+}
+
+func (s *SnapshotSyncer) peerContext(ctx context.Context) context.Context {
+	return auth.NewContext(ctx, &auth.Identity{Login: s.fs42.WhoAmI()})
+}
+
+// unchanged reports whether the file at p still looks like it did when it
+// was last hashed.
+func (s *SnapshotSyncer) unchanged(p string, attr *fgrpc.FileAttr) *fgrpc.ManifestEntry {
+	prev, ok := s.prev[p]
+	if !ok || prev.Omitted || !prev.Attr.Mode.IsRegular() {
+		return nil
+	}
+	if prev.Attr.Size != attr.Size || !prev.Attr.Mtime.Equal(attr.Mtime) || !prev.Attr.Ctime.Equal(attr.Ctime) {
+		return nil
+	}
+	return prev
+}
+
+// BuildManifest walks the public directory and hashes the files that
+// changed since the last call.
+func (s *SnapshotSyncer) BuildManifest(ctx context.Context) (*fgrpc.Manifest, error) {
+	m, _, err := s.build(s.peerContext(ctx), NewPeerServer(s.fs42))
+	return m, err
+}
+
+func (s *SnapshotSyncer) build(ctx context.Context, ps *PeerServer) (*fgrpc.Manifest, map[string]chunkRef, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	rootAttr, err := ps.Stat(ctx, "/")
+	if err != nil {
+		return nil, nil, err
+	}
+	m := &fgrpc.Manifest{
+		Login:   s.fs42.WhoAmI(),
+		Taken:   time.Now(),
+		Entries: []fgrpc.ManifestEntry{{Path: "/", Attr: *rootAttr}},
+	}
+	refs := make(map[string]chunkRef)
+	budget := uint64(maxSnapshotSize)
+	dirs := []string{"/"}
+	// seen holds the device and inode numbers of the directories walked.
+	// With SymlinksResolve, a directory can be reached through several
+	// symlinks, or through one inside itself; only the first path to it
+	// gets its contents, the others are listed as empty.
+	seen := map[[2]uint64]bool{{rootAttr.Dev, rootAttr.INode}: true}
+	for len(dirs) > 0 {
+		dir := dirs[0]
+		dirs = dirs[1:]
+		ents, err := snapshotReadDir(ctx, ps, dir)
+		if err != nil {
+			continue
+		}
+		for _, ent := range ents {
+			e := fgrpc.ManifestEntry{Path: path.Join(dir, ent.Name)}
+			attr, err := ps.Stat(ctx, e.Path)
+			if err != nil {
+				continue
+			}
+			e.Attr = *attr
+			switch {
+			case attr.Mode.IsDir():
+				id := [2]uint64{attr.Dev, attr.INode}
+				if !seen[id] {
+					seen[id] = true
+					dirs = append(dirs, e.Path)
+				}
+			case attr.Mode&os.ModeSymlink != 0:
+				e.Target, err = ps.Readlink(ctx, e.Path)
+			case attr.Mode.IsRegular():
+				if attr.Size > budget {
+					e.Omitted = true
+					break
+				}
+				if prev := s.unchanged(e.Path, attr); prev != nil {
+					e.Chunks = prev.Chunks
+				} else {
+					e.Attr.Size, e.Chunks, err = snapshotHashFile(ctx, ps, e.Path, budget)
+				}
+				if e.Attr.Size > budget {
+					// the file grew while it was being read
+					budget = 0
+				} else {
+					budget -= e.Attr.Size
+				}
+			default:
+				// devices, sockets and fifos are not worth keeping
+				continue
+			}
+			if err != nil {
+				continue
+			}
+			for i, h := range e.Chunks {
+				refs[h] = chunkRef{path: e.Path, off: int64(i) * fgrpc.ChunkSize}
+			}
+			m.Entries = append(m.Entries, e)
+		}
+	}
+
+	s.prev = make(map[string]*fgrpc.ManifestEntry, len(m.Entries))
+	for i := range m.Entries {
+		s.prev[m.Entries[i].Path] = &m.Entries[i]
+	}
+	return m, refs, nil
+}
+
+// forget makes the next build hash p again.
+func (s *SnapshotSyncer) forget(p string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.prev, p)
+}
+
+func snapshotReadDir(ctx context.Context, ps *PeerServer, p string) ([]fgrpc.Dirent, error) {
+	_, fd, err := ps.Open(ctx, p, true, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer ps.Close(ctx, fd)
+	return ps.ReadDir(ctx, fd)
+}
+
+// snapshotHashFile hashes every chunk of the file at p, reading at most
+// limit bytes. The returned size is how much was actually read, which
+// differs from the stat result if the file is being written to.
+func snapshotHashFile(ctx context.Context, ps *PeerServer, p string, limit uint64) (uint64, []string, error) {
+	_, fd, err := ps.Open(ctx, p, false, 0)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer ps.Close(ctx, fd)
+
+	var size uint64
+	var chunks []string
+	for size < limit {
+		b, err := ps.ReadFrom(ctx, &fgrpc.ReadRequest{
+			FD:     fd,
+			Offset: int64(size),
+			Size:   fgrpc.ChunkSize,
+		})
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(b) == 0 {
+			break
+		}
+		chunks = append(chunks, fgrpc.ChunkHash(b))
+		size += uint64(len(b))
+		if len(b) < fgrpc.ChunkSize {
+			break
+		}
+	}
+	return size, chunks, nil
+}
+
+func snapshotReadChunk(ctx context.Context, ps *PeerServer, ref chunkRef) ([]byte, error) {
+	_, fd, err := ps.Open(ctx, ref.path, false, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer ps.Close(ctx, fd)
+	return ps.ReadFrom(ctx, &fgrpc.ReadRequest{
+		FD:     fd,
+		Offset: ref.off,
+		Size:   fgrpc.ChunkSize,
+	})
+}
+
+// upload sends the missing chunks. Chunks whose file changed since it was
+// hashed are skipped, and the file is hashed again by the next build.
+func (s *SnapshotSyncer) upload(ctx context.Context, ps *PeerServer, refs map[string]chunkRef, missing []string) error {
+	var batch [][]byte
+	for _, h := range missing {
+		ref, ok := refs[h]
+		if !ok {
+			continue
+		}
+		b, err := snapshotReadChunk(ctx, ps, ref)
+		if err != nil || fgrpc.ChunkHash(b) != h {
+			s.forget(ref.path)
+			continue
+		}
+		batch = append(batch, b)
+		if len(batch) == chunkBatch {
+			err = s.coord.PutChunks(ctx, batch)
+			if err != nil {
+				return err
+			}
+			batch = nil
+		}
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	return s.coord.PutChunks(ctx, batch)
+}
+
+// Sync brings the coordinator's snapshot up to date.
+func (s *SnapshotSyncer) Sync(ctx context.Context) error {
+	ctx = s.peerContext(ctx)
+	ps := NewPeerServer(s.fs42)
+	for attempt := 0; attempt < syncAttempts; attempt++ {
+		m, refs, err := s.build(ctx, ps)
+		if err != nil {
+			return err
+		}
+		missing, err := s.coord.SyncManifest(ctx, m)
+		if err != nil || len(missing) == 0 {
+			return err
+		}
+		err = s.upload(ctx, ps, refs, missing)
+		if err != nil {
+			return err
+		}
+		missing, err = s.coord.SyncManifest(ctx, m)
+		if err != nil || len(missing) == 0 {
+			return err
+		}
+		// some files changed while they were uploaded
+	}
+	return errSyncChanging
+}
+
+// Run syncs right away and then every interval until ctx is done.
+func (s *SnapshotSyncer) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		err := s.Sync(ctx)
+		if err != nil {
+			log.Println("syncing snapshot with the coordinator failed:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
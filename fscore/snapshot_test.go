@@ -0,0 +1,266 @@
+package fscore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/riking/42fs/auth"
+	fgrpc "github.com/riking/42fs/grpc"
+)
+
+var errPutFailed = errors.New("connection lost")
+
+// fakeUploader keeps chunks in memory like the coordinator's snapshot store,
+// and records what it is sent.
+type fakeUploader struct {
+	chunks map[string][]byte
+	// stored is the last manifest accepted
+	stored *fgrpc.Manifest
+	// uploaded lists the hash of every chunk sent, in order
+	uploaded []string
+	puts     int
+	// failPut makes that PutChunks call, counting from 1, fail without
+	// storing anything
+	failPut int
+	// beforeSync is called at the start of every SyncManifest
+	beforeSync func(call int)
+	syncs      int
+}
+
+func newFakeUploader() *fakeUploader {
+	return &fakeUploader{chunks: make(map[string][]byte)}
+}
+
+func (u *fakeUploader) SyncManifest(ctx context.Context, m *fgrpc.Manifest) ([]string, error) {
+	u.syncs++
+	if u.beforeSync != nil {
+		u.beforeSync(u.syncs)
+	}
+	var missing []string
+	seen := make(map[string]bool)
+	for _, e := range m.Entries {
+		for _, h := range e.Chunks {
+			if _, ok := u.chunks[h]; !ok && !seen[h] {
+				seen[h] = true
+				missing = append(missing, h)
+			}
+		}
+	}
+	if len(missing) == 0 {
+		u.stored = m
+	}
+	return missing, nil
+}
+
+func (u *fakeUploader) PutChunks(ctx context.Context, chunks [][]byte) error {
+	u.puts++
+	if u.puts == u.failPut {
+		return errPutFailed
+	}
+	for _, b := range chunks {
+		h := fgrpc.ChunkHash(b)
+		u.chunks[h] = b
+		u.uploaded = append(u.uploaded, h)
+	}
+	return nil
+}
+
+// contents reassembles the file at p from the stored manifest.
+func (u *fakeUploader) contents(t *testing.T, p string) []byte {
+	t.Helper()
+	if u.stored == nil {
+		t.Fatal("no manifest was stored")
+	}
+	for _, e := range u.stored.Entries {
+		if e.Path != p {
+			continue
+		}
+		var data []byte
+		for _, h := range e.Chunks {
+			b, ok := u.chunks[h]
+			if !ok {
+				t.Fatalf("%s: chunk %s was never uploaded", p, h)
+			}
+			data = append(data, b...)
+		}
+		return data
+	}
+	t.Fatalf("%s is not in the manifest", p)
+	return nil
+}
+
+// testOwner is a user with a public directory and no coordinator.
+type testOwner struct {
+	dir  string
+	fs42 *FS42
+}
+
+func newTestOwner(t *testing.T) *testOwner {
+	dir, err := ioutil.TempDir("", "42fs-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if err := os.Chmod(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	fs42 := NewFS42(nil, auth.NewUnverifiedSession("alice"), dir)
+	t.Cleanup(func() { fs42.Close() })
+	return &testOwner{dir: dir, fs42: fs42}
+}
+
+func (o *testOwner) writeFile(t *testing.T, name, contents string) {
+	if err := ioutil.WriteFile(filepath.Join(o.dir, name), []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (o *testOwner) mkdir(t *testing.T, name string) {
+	if err := os.Mkdir(filepath.Join(o.dir, name), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// chunks returns n chunks of data that all hash differently.
+func chunks(n int, seed byte) string {
+	var b bytes.Buffer
+	for i := 0; i < n; i++ {
+		b.Write(bytes.Repeat([]byte{seed + byte(i)}, fgrpc.ChunkSize))
+	}
+	return b.String()
+}
+
+func TestSnapshotUploadsOnlyMissingChunks(t *testing.T) {
+	ctx := context.Background()
+	alice := newTestOwner(t)
+	alice.writeFile(t, "a.txt", "hello")
+	alice.writeFile(t, "big", chunks(3, 'A'))
+	alice.mkdir(t, "sub")
+	alice.writeFile(t, "sub/c.txt", "old")
+
+	u := newFakeUploader()
+	s := NewSnapshotSyncer(alice.fs42, u)
+	if err := s.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(u.uploaded) != 5 {
+		t.Errorf("first sync uploaded %d chunks, want 5", len(u.uploaded))
+	}
+
+	// a copy of a file already sent costs nothing, a changed file only its
+	// new chunk
+	u.uploaded = nil
+	alice.writeFile(t, "copy", "hello")
+	alice.writeFile(t, "sub/c.txt", "new contents")
+	if err := s.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{fgrpc.ChunkHash([]byte("new contents"))}
+	if strings.Join(u.uploaded, " ") != strings.Join(want, " ") {
+		t.Errorf("second sync uploaded %v, want %v", u.uploaded, want)
+	}
+	for p, want := range map[string]string{"/copy": "hello", "/sub/c.txt": "new contents", "/big": chunks(3, 'A')} {
+		if got := u.contents(t, p); string(got) != want {
+			t.Errorf("%s is %d bytes in the snapshot, want %d", p, len(got), len(want))
+		}
+	}
+}
+
+func TestSnapshotResumesAfterFailedPut(t *testing.T) {
+	ctx := context.Background()
+	alice := newTestOwner(t)
+	// more chunks than fit in one PutChunks call
+	const n = 50
+	alice.writeFile(t, "big", chunks(n, 0))
+
+	u := newFakeUploader()
+	u.failPut = 2
+	s := NewSnapshotSyncer(alice.fs42, u)
+	if err := s.Sync(ctx); err != errPutFailed {
+		t.Fatalf("sync with a failing upload returned %v, want %v", err, errPutFailed)
+	}
+	sent := len(u.uploaded)
+	if sent == 0 || sent == n {
+		t.Fatalf("%d of %d chunks were stored before the failure", sent, n)
+	}
+	if u.stored != nil {
+		t.Fatal("a manifest was stored before all of its chunks")
+	}
+
+	u.failPut = 0
+	if err := s.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, h := range u.uploaded {
+		if seen[h] {
+			t.Errorf("chunk %s was uploaded twice", h)
+		}
+		seen[h] = true
+	}
+	if len(u.uploaded) != n {
+		t.Errorf("%d chunks uploaded in all, want %d", len(u.uploaded), n)
+	}
+	if got := u.contents(t, "/big"); string(got) != chunks(n, 0) {
+		t.Error("the snapshot of big doesn't match the file")
+	}
+}
+
+func TestSnapshotFileChangesDuringSync(t *testing.T) {
+	ctx := context.Background()
+	alice := newTestOwner(t)
+	alice.writeFile(t, "log", "first version")
+
+	u := newFakeUploader()
+	u.beforeSync = func(call int) {
+		if call == 1 {
+			// after the manifest was built, before the chunks are read
+			alice.writeFile(t, "log", "second, longer version")
+		}
+	}
+	s := NewSnapshotSyncer(alice.fs42, u)
+	if err := s.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := u.contents(t, "/log"); string(got) != "second, longer version" {
+		t.Errorf("snapshot of log is %q", got)
+	}
+	old := fgrpc.ChunkHash([]byte("first version"))
+	for _, h := range u.uploaded {
+		if h == old {
+			t.Error("the chunk of the old version was uploaded")
+		}
+	}
+}
+
+func TestSnapshotSymlinkLoops(t *testing.T) {
+	alice := newTestOwner(t)
+	alice.fs42.Symlinks = SymlinksResolve
+	alice.mkdir(t, "d")
+	alice.writeFile(t, "d/f", "x")
+	for link, target := range map[string]string{"loop": ".", "d/up": "..", "d/self": "."} {
+		if err := os.Symlink(target, filepath.Join(alice.dir, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err := NewSnapshotSyncer(alice.fs42, nil).BuildManifest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the links are listed, as the directories they resolve to, but only
+	// walked once
+	var paths []string
+	for _, e := range m.Entries {
+		paths = append(paths, e.Path)
+	}
+	if len(paths) != 6 {
+		t.Errorf("manifest lists %v, want /, /loop, /d, /d/up, /d/self and /d/f", paths)
+	}
+}
@@ -0,0 +1,180 @@
+package fscore
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// SymlinkPolicy chooses how symlinks in the public directory are shown,
+// both to peers and in the owner's own directory under the mount. Whatever
+// the policy, 42fs never follows a symlink out of the public directory.
+type SymlinkPolicy int
+
+const (
+	// SymlinksHide leaves symlinks out, as if they weren't there. It is the
+	// default, as a symlink can say more than its owner meant to share.
+	SymlinksHide SymlinkPolicy = iota
+	// SymlinksResolve shows a symlink as what it leads to, if that is in
+	// the public directory. The others are hidden.
+	SymlinksResolve
+	// SymlinksServe shows symlinks as they are, for the kernel of whoever
+	// reads them to follow. Their targets are sent as they are too, and
+	// point into the reader's own filesystem.
+	SymlinksServe
+)
+
+var symlinkPolicyNames = []string{
+	SymlinksHide:    "hide",
+	SymlinksResolve: "resolve",
+	SymlinksServe:   "serve",
+}
+
+func (p SymlinkPolicy) String() string {
+	if p < 0 || int(p) >= len(symlinkPolicyNames) {
+		return fmt.Sprintf("SymlinkPolicy(%d)", int(p))
+	}
+	return symlinkPolicyNames[p]
+}
+
+func (p SymlinkPolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText parses a policy from its String.
+func (p *SymlinkPolicy) UnmarshalText(b []byte) error {
+	for i, name := range symlinkPolicyNames {
+		if string(b) == name {
+			*p = SymlinkPolicy(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("symlink policy must be one of %s, got %q", strings.Join(symlinkPolicyNames, ", "), b)
+}
+
+// symlinkPolicyFile is the name of the control file, in the public
+// directory itself, that overrides FS42.Symlinks. It holds the name of a
+// policy; lines starting with # are comments. If it can't be parsed,
+// symlinks are hidden.
+const symlinkPolicyFile = ".42fs-symlinks"
+
+// maxSymlinkHops bounds how many symlinks are followed to resolve a path,
+// like the kernel's MAXSYMLINKS.
+const maxSymlinkHops = 40
+
+func parseSymlinkPolicy(r io.Reader) interface{} {
+	words := controlWords(r)
+	var p SymlinkPolicy
+	if len(words) != 1 {
+		log.Printf("%s: expected one of %s; hiding symlinks", symlinkPolicyFile, strings.Join(symlinkPolicyNames, ", "))
+		return SymlinksHide
+	}
+	err := p.UnmarshalText([]byte(words[0]))
+	if err != nil {
+		log.Printf("%s: %v; hiding symlinks", symlinkPolicyFile, err)
+		return SymlinksHide
+	}
+	return p
+}
+
+// symlinkPolicy returns the policy in force: the one in symlinkPolicyFile,
+// or else FS42.Symlinks.
+func (md *LocalDir) symlinkPolicy() SymlinkPolicy {
+	p, ok := md.control.load(md, symlinkPolicyFile, parseSymlinkPolicy).(SymlinkPolicy)
+	if !ok {
+		p = md.fs42.Symlinks
+	}
+	return p
+}
+
+// relPath turns names back into a path relative to Root, like the paths of
+// LocalNodes.
+func relPath(names []string) string {
+	if len(names) == 0 {
+		return "."
+	}
+	return "./" + strings.Join(names, "/")
+}
+
+// linkTarget returns the names, from Root, that the symlink target in the
+// directory at dir leads to. Targets outside Root fail with ENOENT, as if
+// the symlink weren't there.
+func (md *LocalDir) linkTarget(dir []string, target string) ([]string, error) {
+	names := append([]string(nil), dir...)
+	if path.IsAbs(target) {
+		root := path.Clean(md.Root)
+		if target != root && !strings.HasPrefix(target, root+"/") {
+			return nil, unix.ENOENT
+		}
+		target = target[len(root):]
+		names = names[:0]
+	}
+	for _, name := range strings.Split(target, "/") {
+		switch name {
+		case "", ".":
+		case "..":
+			if len(names) == 0 {
+				return nil, unix.ENOENT
+			}
+			names = names[:len(names)-1]
+		default:
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// resolve applies policy to the symlinks on the way to rel, a path relative
+// to Root, and returns the path of what it leads to. Under SymlinksServe,
+// that is rel; otherwise it is a path without symlinks, or ENOENT if a
+// symlink is hidden. If check is not nil, it is called with the path of
+// every entry before it is looked at, and may refuse to go on.
+func (md *LocalDir) resolve(rel string, policy SymlinkPolicy, check func(rel string) error) (string, error) {
+	names, err := splitRel(rel)
+	if err != nil || policy == SymlinksServe {
+		return rel, err
+	}
+	var done []string
+	hops := 0
+	for len(names) > 0 {
+		cur := append(done, names[0])
+		if check != nil {
+			err := check(relPath(cur))
+			if err != nil {
+				return "", err
+			}
+		}
+		var st unix.Stat_t
+		err := md.lstat(relPath(cur), &st)
+		if err != nil {
+			return "", err
+		}
+		if st.Mode&unix.S_IFMT != unix.S_IFLNK {
+			done, names = cur, names[1:]
+			continue
+		}
+		if policy == SymlinksHide {
+			return "", unix.ENOENT
+		}
+		hops++
+		if hops > maxSymlinkHops {
+			return "", unix.ELOOP
+		}
+		target, err := md.readlink(relPath(cur))
+		if err != nil {
+			return "", err
+		}
+		tnames, err := md.linkTarget(done, target)
+		if err != nil {
+			return "", err
+		}
+		// the target may hold symlinks too, so it is walked again
+		names = append(tnames, names[1:]...)
+		done = nil
+	}
+	return relPath(done), nil
+}
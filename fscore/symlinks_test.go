@@ -0,0 +1,182 @@
+package fscore_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/riking/42fs/fs42test"
+	"github.com/riking/42fs/fscore"
+)
+
+// newSymlinkFixture is newEscapeFixture with a symlink of every kind that
+// stays in the public directory as well.
+func newSymlinkFixture(t *testing.T) *escapeFixture {
+	f := newEscapeFixture(t)
+	alice := f.alice
+	alice.Mkdir(t, "private", 0700)
+	alice.WriteFile(t, "private/p", "private", 0644)
+	links := map[string]string{
+		"relfile":  "real/f",
+		"reldir":   "real",
+		"absfile":  filepath.Join(alice.Dir, "real/f"),
+		"privlink": "private/p",
+		"loop":     "loop",
+		"dangle":   "dangling",
+	}
+	for name, target := range links {
+		err := os.Symlink(target, filepath.Join(alice.Dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f
+}
+
+// listing returns the sorted names in alice's directory, as user sees it.
+func listing(t *testing.T, user *fs42test.User) string {
+	ents, err := user.VFS(t).ReadDir("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range ents {
+		names = append(names, e.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, " ")
+}
+
+func TestSymlinksHide(t *testing.T) {
+	f := newSymlinkFixture(t)
+	f.alice.FS42.Symlinks = fscore.SymlinksHide
+	peer := f.reader(t)
+	if got, want := listing(t, peer), "box private real"; got != want {
+		t.Errorf("a peer sees %q, want %q", got, want)
+	}
+	if got, want := listing(t, f.alice), "box private real"; got != want {
+		t.Errorf("alice sees %q, want %q", got, want)
+	}
+	for _, p := range []string{"relfile", "reldir/f", "absfile", "privlink", "loop", "dangle"} {
+		if _, err := peer.VFS(t).Stat("alice/" + p); err == nil {
+			t.Errorf("a peer found %s", p)
+		}
+		if _, err := f.alice.VFS(t).Stat("alice/" + p); err == nil {
+			t.Errorf("alice found %s", p)
+		}
+	}
+}
+
+func TestSymlinksResolve(t *testing.T) {
+	f := newSymlinkFixture(t)
+	f.alice.FS42.Symlinks = fscore.SymlinksResolve
+	peer := f.reader(t)
+	// privlink is only shown to whoever can read private
+	if got, want := listing(t, peer), "absfile box private real reldir relfile"; got != want {
+		t.Errorf("a peer sees %q, want %q", got, want)
+	}
+	if got, want := listing(t, f.alice), "absfile box private privlink real reldir relfile"; got != want {
+		t.Errorf("alice sees %q, want %q", got, want)
+	}
+	for _, user := range []*fs42test.User{peer, f.alice} {
+		v := user.VFS(t)
+		for _, p := range []string{"relfile", "reldir/f", "absfile"} {
+			b, err := v.ReadFile("alice/" + p)
+			if err != nil || string(b) != "inside" {
+				t.Errorf("%s read %q from %s: %v", user.Login, b, p, err)
+			}
+		}
+		a, err := v.Stat("alice/relfile")
+		if err != nil || !a.Mode.IsRegular() {
+			t.Errorf("%s sees relfile as %v: %v", user.Login, a.Mode, err)
+		}
+		for _, p := range []string{"loop", "dangle"} {
+			if _, err := v.Stat("alice/" + p); err == nil {
+				t.Errorf("%s found %s", user.Login, p)
+			}
+		}
+	}
+	if b, err := f.alice.VFS(t).ReadFile("alice/privlink"); err != nil || string(b) != "private" {
+		t.Errorf("alice read %q from privlink: %v", b, err)
+	}
+	if _, err := peer.VFS(t).ReadFile("alice/privlink"); err == nil {
+		t.Error("a peer read private/p through privlink")
+	}
+}
+
+func TestSymlinksServe(t *testing.T) {
+	f := newSymlinkFixture(t)
+	f.alice.FS42.Symlinks = fscore.SymlinksServe
+	peer := f.reader(t)
+	want := "abs absdir absfile box dangle loop private privlink real reldir relfile up updir"
+	if got := listing(t, peer); got != want {
+		t.Errorf("a peer sees %q, want %q", got, want)
+	}
+	if got := listing(t, f.alice); got != want {
+		t.Errorf("alice sees %q, want %q", got, want)
+	}
+	for _, user := range []*fs42test.User{peer, f.alice} {
+		v := user.VFS(t)
+		a, err := v.Stat("alice/relfile")
+		if err != nil || a.Mode&os.ModeSymlink == 0 {
+			t.Errorf("%s sees relfile as %v: %v", user.Login, a.Mode, err)
+		}
+		// following them is up to the kernel reading them
+		if _, err := v.ReadFile("alice/relfile"); err == nil {
+			t.Errorf("%s read through relfile", user.Login)
+		}
+		if _, err := v.Stat("alice/reldir/f"); err == nil {
+			t.Errorf("%s looked through reldir", user.Login)
+		}
+	}
+	ctx := context.Background()
+	dc := fs42test.NewDirConn(fscore.NewPeerServer(f.alice.FS42), "bob")
+	for name, want := range map[string]string{"relfile": "real/f", "abs": filepath.Join(f.outside, "key")} {
+		target, err := dc.Readlink(ctx, "/"+name)
+		if err != nil || target != want {
+			t.Errorf("%s reads as %q, want %q: %v", name, target, want, err)
+		}
+	}
+}
+
+func TestSymlinkPolicyFile(t *testing.T) {
+	f := newSymlinkFixture(t)
+	f.alice.FS42.Symlinks = fscore.SymlinksServe
+	for _, tc := range []struct {
+		contents string
+		want     fscore.SymlinkPolicy
+	}{
+		{"# resolve within the public directory\nresolve\n", fscore.SymlinksResolve},
+		{"hide", fscore.SymlinksHide},
+		{"resolve serve", fscore.SymlinksHide},
+		{"follow everything", fscore.SymlinksHide},
+	} {
+		f.alice.WriteFile(t, ".42fs-symlinks", tc.contents, 0644)
+		peer := f.reader(t)
+		a, err := peer.VFS(t).Stat("alice/relfile")
+		var got fscore.SymlinkPolicy
+		switch {
+		case err != nil:
+			got = fscore.SymlinksHide
+		case a.Mode.IsRegular():
+			got = fscore.SymlinksResolve
+		default:
+			got = fscore.SymlinksServe
+		}
+		if got != tc.want {
+			t.Errorf("with %q, symlinks are %v, want %v", tc.contents, got, tc.want)
+		}
+		if strings.Contains(listing(t, peer), ".42fs-symlinks") {
+			t.Error("the policy file is shown to peers")
+		}
+	}
+	if err := os.Remove(filepath.Join(f.alice.Dir, ".42fs-symlinks")); err != nil {
+		t.Fatal(err)
+	}
+	if a, err := f.reader(t).VFS(t).Stat("alice/relfile"); err != nil || a.Mode&os.ModeSymlink == 0 {
+		t.Errorf("without the policy file, relfile is %v: %v", a.Mode, err)
+	}
+}
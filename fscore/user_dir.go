@@ -2,6 +2,11 @@ package fscore
 
 import (
 	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
 
 	fgrpc "github.com/riking/42fs/grpc"
 
@@ -14,9 +19,17 @@ import (
 type UserDir struct {
 	fs42      *FS42
 	curCon    fgrpc.UserConnection
+	// offline is set when curCon is the coordinator's snapshot
+	offline   bool
 	login     string
+	// inode is the coordinator's number for the directory
+	inode     uint64
+	cache     *metaCache
 	RemoteNode
 
+	// stops the subscription to changes of curCon
+	stopFollow context.CancelFunc
+
 	lock      sync.Mutex
 	pathCache map[string]*RemoteNode
 	openFiles map[uint64]*RemoteFile
@@ -24,9 +37,10 @@ type UserDir struct {
 
 func NewUserDir(fs42 *FS42, login *fgrpc.LoginInfo) *UserDir {
 	d := &UserDir{
-		fs42:   fs42,
-		curCon: nil, // TODO
-		login:  login.Login,
+		fs42:  fs42,
+		login: login.Login,
+		inode: login.INode,
+		cache: newMetaCache(&fs42.Cache),
 	}
 	d.pathCache = make(map[string]*RemoteNode)
 	d.openFiles = make(map[uint64]*RemoteFile)
@@ -41,7 +55,12 @@ func NewUserDir(fs42 *FS42, login *fgrpc.LoginInfo) *UserDir {
 	var _ fs.NodeListxattrer = &d.RemoteNode
 	var _ fs.NodeOpener = &d.RemoteNode
 	var _ fs.NodeReadlinker = &d.RemoteNode
-	var _ fs.NodeStringLookuper = &d.RemoteNode
+	var _ fs.NodeRequestLookuper = &d.RemoteNode
+	var _ fs.NodeCreater = &d.RemoteNode
+	var _ fs.NodeMkdirer = &d.RemoteNode
+	var _ fs.NodeRemover = &d.RemoteNode
+	var _ fs.NodeRenamer = &d.RemoteNode
+	var _ fs.NodeSetattrer = &d.RemoteNode
 	return d
 }
 
@@ -60,9 +79,145 @@ func (ud *UserDir) nodeFor(parent *RemoteNode, name string) *RemoteNode {
 }
 
 func (ud *UserDir) conn() fgrpc.UserConnection {
+	ud.lock.Lock()
+	defer ud.lock.Unlock()
 	return ud.curCon
 }
 
+// setConn points the UserDir at conn. The metadata cache is dropped when
+// the owner's daemon moves or goes on- or offline, as the files may have
+// changed in between.
+func (ud *UserDir) setConn(conn fgrpc.UserConnection, offline bool) {
+	ud.lock.Lock()
+	defer ud.lock.Unlock()
+	if offline == ud.offline && (offline || conn == ud.curCon) {
+		ud.curCon = conn
+		return
+	}
+	ud.cache.reset()
+	ud.curCon = conn
+	ud.offline = offline
+	if ud.stopFollow != nil {
+		ud.stopFollow()
+		ud.stopFollow = nil
+	}
+	if !offline {
+		// snapshots don't change while the owner is offline
+		ctx, cancel := context.WithCancel(context.Background())
+		ud.stopFollow = cancel
+		go ud.follow(ctx, conn)
+	}
+}
+
+func (ud *UserDir) unfollow() {
+	ud.lock.Lock()
+	defer ud.lock.Unlock()
+	if ud.stopFollow != nil {
+		ud.stopFollow()
+		ud.stopFollow = nil
+	}
+}
+
+// follow subscribes to changes of the owner's directory until ctx is done,
+// subscribing again if the subscription fails.
+func (ud *UserDir) follow(ctx context.Context, conn fgrpc.UserConnection) {
+	events := make(chan fgrpc.ChangeEvent, 16)
+	go func() {
+		for {
+			select {
+			case ev := <-events:
+				ud.changed(ev)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	delay := time.Second
+	for {
+		start := time.Now()
+		err := conn.Subscribe(ctx, events)
+		if ctx.Err() != nil {
+			return
+		}
+		if en, ok := err.(fuse.ErrorNumber); ok && en.Errno() == fuse.ENOSYS {
+			return
+		}
+		log.Printf("following changes of %s: %v", ud.login, err)
+		// anything may have changed while we weren't subscribed
+		ud.changed(fgrpc.ChangeEvent{Path: "/", Overflow: true})
+
+		if time.Since(start) > time.Minute {
+			delay = time.Second
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+		if delay < time.Minute {
+			delay *= 2
+		}
+	}
+}
+
+// changed drops what is cached about a change reported by the owner's
+// daemon, both here and in the kernel.
+func (ud *UserDir) changed(ev fgrpc.ChangeEvent) {
+	if ev.Overflow {
+		ud.cache.reset()
+		ud.lock.Lock()
+		paths := make([]string, 0, len(ud.pathCache))
+		for p := range ud.pathCache {
+			paths = append(paths, p)
+		}
+		ud.lock.Unlock()
+		for _, p := range paths {
+			ud.invalidateKernel(p, true)
+		}
+		return
+	}
+	p := ev.Path
+	if p == "/" {
+		p = ""
+	}
+	ud.cache.invalidate(p, ev.Entry)
+	ud.invalidateKernel(p, ev.Entry)
+}
+
+// invalidateKernel tells the kernel to forget the attributes and contents of
+// the node at p and, if entry is set, its directory entry.
+func (ud *UserDir) invalidateKernel(p string, entry bool) {
+	srv := ud.fs42.server
+	if srv == nil {
+		return
+	}
+	i := strings.LastIndex(p, "/")
+	// the kernel may be waiting on a request that needs ud.lock, so it
+	// must not be held while notifying
+	ud.lock.Lock()
+	node := ud.pathCache[p]
+	var parent *RemoteNode
+	if entry && i >= 0 {
+		parent = ud.pathCache[p[:i]]
+	}
+	ud.lock.Unlock()
+
+	if node != nil {
+		logInvalidate(srv.InvalidateNodeData(node))
+	}
+	if parent != nil {
+		logInvalidate(srv.InvalidateEntry(parent, p[i+1:]))
+		logInvalidate(srv.InvalidateNodeData(parent))
+	}
+}
+
+func logInvalidate(err error) {
+	if err != nil && err != fuse.ErrNotCached {
+		log.Println("invalidating kernel cache:", err)
+	}
+}
+
 type RemoteNode struct {
 	ud   *UserDir
 	Path string
@@ -76,13 +231,39 @@ func (d *RemoteNode) Access(ctx context.Context, req *fuse.AccessRequest) error
 	return d.ud.conn().Access(ctx, d.Path, req.Mask)
 }
 
+// stat returns the attributes of the file at p, from the cache if they are
+// fresh enough.
+func (ud *UserDir) stat(ctx context.Context, p string) (*fgrpc.FileAttr, error) {
+	st, ok := ud.cache.attr(p)
+	if ok {
+		return st, nil
+	}
+	st, err := ud.conn().Stat(ctx, p)
+	if isNotExist(err) {
+		ud.cache.putMissing(p)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ud.cache.putAttr(p, st)
+	return st, nil
+}
+
 func (d *RemoteNode) Attr(ctx context.Context, a *fuse.Attr) error {
-	st, err := d.ud.conn().Stat(ctx, d.Path)
+	st, err := d.ud.stat(ctx, d.Path)
 	if err != nil {
 		return err
 	}
+	d.fillAttr(a, st)
+	return nil
+}
 
-	a.Inode = st.INode
+func (d *RemoteNode) fillAttr(a *fuse.Attr, st *fgrpc.FileAttr) {
+	if d == &d.ud.RemoteNode {
+		a.Inode = d.ud.fs42.userRootINode(d.ud.login, d.ud.inode, st.Dev, st.INode)
+	} else {
+		a.Inode = d.ud.fs42.inodes.get(d.ud.login, st.Dev, st.INode)
+	}
 	a.Size = st.Size
 	a.Blocks = st.Blocks
 	a.Atime = st.Mtime
@@ -94,8 +275,7 @@ func (d *RemoteNode) Attr(ctx context.Context, a *fuse.Attr) error {
 	a.Gid = st.Gid
 	a.BlockSize = st.BlockSize
 	a.Mode = st.Mode
-
-	return nil
+	a.Valid = d.ud.fs42.Cache.AttrTTL
 }
 
 func (d *RemoteNode) Forget() {
@@ -136,12 +316,23 @@ func (d *RemoteNode) Listxattr(ctx context.Context, req *fuse.ListxattrRequest,
 	return nil
 }
 
-func (d *RemoteNode) Lookup(ctx context.Context, name string) (fs.Node, error) {
-	err := d.ud.conn().LookupExists(ctx, d.Join(name))
+func (d *RemoteNode) Lookup(ctx context.Context, req *fuse.LookupRequest, resp *fuse.LookupResponse) (fs.Node, error) {
+	if req.Name == ".." {
+		// the kernel never asks, and the peer would take it as the root
+		return nil, errEscape
+	}
+	p := d.Join(req.Name)
+	if d.ud.cache.missing(p) {
+		return nil, fuse.ENOENT
+	}
+	// fetching the attributes now saves a round trip when they are
+	// filled into the response
+	_, err := d.ud.stat(ctx, p)
 	if err != nil {
 		return nil, err
 	}
-	return d.ud.nodeFor(d, name), nil
+	resp.EntryValid = d.ud.fs42.Cache.AttrTTL
+	return d.ud.nodeFor(d, req.Name), nil
 }
 
 func (d *RemoteNode) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
@@ -151,7 +342,12 @@ func (d *RemoteNode) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse
 		return nil, err
 	}
 	resp.Flags = rflags
-	rFile := &RemoteFile{rn: d, fd: fd}
+	rFile := newRemoteFile(d, fd, req.Dir)
+	if !req.Flags.IsReadOnly() || req.Flags&fuse.OpenTruncate != 0 {
+		d.ud.cache.invalidate(d.Path, false)
+	} else if !req.Dir && d.ud.fs42.disk.enabled() {
+		d.openCached(ctx, rFile)
+	}
 
 	d.ud.lock.Lock()
 	defer d.ud.lock.Unlock()
@@ -159,30 +355,187 @@ func (d *RemoteNode) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse
 	return rFile, nil
 }
 
+// openCached looks for a copy of the file in the disk cache, checking
+// first that the file hasn't changed since the copy was made.
+func (d *RemoteNode) openCached(ctx context.Context, f *RemoteFile) {
+	st, err := d.ud.conn().Stat(ctx, d.Path)
+	if err != nil || !st.Mode.IsRegular() {
+		return
+	}
+	d.ud.cache.putAttr(d.Path, st)
+	ver := versionOf(st)
+	f.ver = &ver
+	f.cached = d.ud.fs42.disk.open(d.ud.login, d.Path, ver)
+}
+
 func (d *RemoteNode) Readlink(ctx context.Context, req *fuse.ReadlinkRequest) (string, error) {
 	return d.ud.conn().Readlink(ctx, d.Path)
 }
 
 type RemoteFile struct {
-	rn *RemoteNode
-	fd uint64
+	rn  *RemoteNode
+	fd  uint64
+	dir bool
+
+	reads readState
+	// ver is the version of the file when it was opened, if known
+	ver *fileVersion
+	// cached is the copy in the disk cache, if there is one
+	cached *os.File
+	// readahead is the context of prefetches, which Release cancels
+	readahead     context.Context
+	stopReadahead context.CancelFunc
+}
+
+func newRemoteFile(rn *RemoteNode, fd uint64, dir bool) *RemoteFile {
+	f := &RemoteFile{rn: rn, fd: fd, dir: dir}
+	f.readahead, f.stopReadahead = context.WithCancel(context.Background())
+	return f
 }
 
 func (f *RemoteFile) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
-	cEnts, err := f.rn.ud.conn().ReadDir(ctx, f.fd)
-	if err != nil {
-		return nil, err
+	cEnts, ok := f.rn.ud.cache.dir(f.rn.Path)
+	if !ok {
+		var err error
+		cEnts, err = f.rn.ud.conn().ReadDir(ctx, f.fd)
+		if err != nil {
+			return nil, err
+		}
+		f.rn.ud.cache.putDir(f.rn.Path, cEnts)
 	}
+	inodes := f.rn.ud.fs42.inodes
 	fEnts := make([]fuse.Dirent, len(cEnts))
 	for i := range cEnts {
-		fEnts[i].Inode = cEnts[i].Inode
+		if cEnts[i].Inode != 0 {
+			fEnts[i].Inode = inodes.get(f.rn.ud.login, cEnts[i].Dev, cEnts[i].Inode)
+		}
 		fEnts[i].Name = cEnts[i].Name
 		fEnts[i].Type = fuse.DirentType(cEnts[i].Type)
 	}
 	return fEnts, nil
 }
 
+// Read serves file contents from the block cache, fetching the blocks that
+// are missing and, when the file is read sequentially, the ones after them.
 func (f *RemoteFile) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	ud := f.rn.ud
+	if f.cached != nil && !req.Dir {
+		return f.readCached(req, resp)
+	}
+	if req.Dir || f.dir || !ud.fs42.blocks.enabled() {
+		return f.readDirect(ctx, req, resp)
+	}
+	st, err := ud.stat(ctx, f.rn.Path)
+	if err != nil {
+		return err
+	}
+	ver := versionOf(st)
+	size := int64(st.Size)
+	window := f.reads.advance(req.Offset, req.Size, ud.fs42.Cache.Readahead/blockSize)
+
+	var data []byte
+	off := req.Offset
+	end := req.Offset + int64(req.Size)
+	for off < end && off < size {
+		i := off / blockSize
+		b, err := f.block(ctx, ver, i)
+		if err != nil {
+			return err
+		}
+		start := off - i*blockSize
+		if start >= int64(len(b)) {
+			// the file is shorter than it was when stat was cached
+			break
+		}
+		b = b[start:]
+		if int64(len(b)) > end-off {
+			b = b[:end-off]
+		}
+		data = append(data, b...)
+		off += int64(len(b))
+	}
+	resp.Data = data
+
+	next := (end + blockSize - 1) / blockSize
+	for i := next; i < next+window && i*blockSize < size; i++ {
+		if ud.fs42.blocks.has(f.blockKey(ver, i)) {
+			continue
+		}
+		go func(i int64) {
+			ctx, cancel := context.WithTimeout(f.readahead, readaheadTimeout)
+			defer cancel()
+			f.block(ctx, ver, i)
+		}(i)
+	}
+	return nil
+}
+
+func (f *RemoteFile) blockKey(ver fileVersion, i int64) blockKey {
+	return blockKey{login: f.rn.ud.login, path: f.rn.Path, ver: ver, index: i}
+}
+
+// block returns block i of the file, from the cache if possible. Only the
+// last block of a file is shorter than blockSize.
+func (f *RemoteFile) block(ctx context.Context, ver fileVersion, i int64) ([]byte, error) {
+	return f.rn.ud.fs42.blocks.get(f.blockKey(ver, i), func() ([]byte, error) {
+		// the owner's daemon may return less than asked for
+		var data []byte
+		for len(data) < blockSize {
+			b, err := f.rn.ud.conn().ReadFrom(ctx, &fgrpc.ReadRequest{
+				FD:     f.fd,
+				Offset: i*blockSize + int64(len(data)),
+				Size:   blockSize - len(data),
+			})
+			if err != nil {
+				return nil, err
+			}
+			if len(b) == 0 {
+				break
+			}
+			data = append(data, b...)
+		}
+		return data, nil
+	})
+}
+
+// readCached serves a read from the disk cache. The copy stays the same
+// for as long as the file is open, even if the owner changes the file.
+func (f *RemoteFile) readCached(req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	buf := make([]byte, req.Size)
+	n, err := f.cached.ReadAt(buf, req.Offset)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	resp.Data = buf[:n]
+	return nil
+}
+
+// saveToDisk stores the file in the disk cache if all of it is in the
+// block cache, which is the case once it has been read to the end.
+func (f *RemoteFile) saveToDisk() {
+	ver := *f.ver
+	if ver.size == 0 {
+		return
+	}
+	data := make([]byte, 0, ver.size)
+	for i := int64(0); uint64(len(data)) < ver.size; i++ {
+		b, ok := f.rn.ud.fs42.blocks.peek(f.blockKey(ver, i))
+		if !ok || len(b) == 0 {
+			return
+		}
+		data = append(data, b...)
+	}
+	if uint64(len(data)) != ver.size {
+		return
+	}
+	err := f.rn.ud.fs42.disk.put(f.rn.ud.login, f.rn.Path, ver, data)
+	if err != nil {
+		log.Println("writing disk cache:", err)
+	}
+}
+
+// readDirect forwards a read to the owner's daemon without caching.
+func (f *RemoteFile) readDirect(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
 	var cReq fgrpc.ReadRequest
 	cReq.Dir = req.Dir
 	cReq.FD = f.fd
@@ -198,9 +551,15 @@ func (f *RemoteFile) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse
 }
 
 func (f *RemoteFile) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
+	f.stopReadahead()
 	f.rn.ud.lock.Lock()
 	delete(f.rn.ud.openFiles, f.fd)
 	f.rn.ud.lock.Unlock()
 
+	if f.cached != nil {
+		f.cached.Close()
+	} else if f.ver != nil {
+		go f.saveToDisk()
+	}
 	return f.rn.ud.conn().Close(ctx, f.fd)
 }
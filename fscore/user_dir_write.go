@@ -0,0 +1,121 @@
+package fscore
+
+import (
+	"syscall"
+	"time"
+
+	fgrpc "github.com/riking/42fs/grpc"
+
+	"bazil.org/fuse"
+	"bazil.org/fuse/fs"
+	"golang.org/x/net/context"
+)
+
+// Writes to other users' directories are forwarded to the owner's daemon,
+// which only allows them inside the owner's dropbox. What is cached about
+// the changed paths is dropped here right away; the owner's daemon reports
+// the changes to everyone else.
+
+func (d *RemoteNode) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.CreateResponse) (fs.Node, fs.Handle, error) {
+	p := d.Join(req.Name)
+	oflags := fgrpc.ToAgnostic(req.Flags)
+	rflags, fd, err := d.ud.conn().Create(ctx, p, req.Mode&^req.Umask, oflags)
+	if err != nil {
+		return nil, nil, err
+	}
+	d.ud.cache.invalidate(p, true)
+	rn := d.ud.nodeFor(d, req.Name)
+	rFile := newRemoteFile(rn, fd, false)
+	d.ud.lock.Lock()
+	d.ud.openFiles[fd] = rFile
+	d.ud.lock.Unlock()
+
+	st, err := d.ud.stat(ctx, p)
+	if err != nil {
+		rFile.Release(ctx, &fuse.ReleaseRequest{})
+		return nil, nil, err
+	}
+	rn.fillAttr(&resp.Attr, st)
+	resp.EntryValid = d.ud.fs42.Cache.AttrTTL
+	resp.OpenResponse.Flags = rflags
+	return rn, rFile, nil
+}
+
+func (d *RemoteNode) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, error) {
+	p := d.Join(req.Name)
+	err := d.ud.conn().Mkdir(ctx, p, req.Mode&^req.Umask)
+	if err != nil {
+		return nil, err
+	}
+	d.ud.cache.invalidate(p, true)
+	return d.ud.nodeFor(d, req.Name), nil
+}
+
+func (d *RemoteNode) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
+	p := d.Join(req.Name)
+	err := d.ud.conn().Remove(ctx, p, req.Dir)
+	if err != nil {
+		return err
+	}
+	d.ud.cache.invalidate(p, true)
+	return nil
+}
+
+func (d *RemoteNode) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.Node) error {
+	nd, ok := newDir.(*RemoteNode)
+	if !ok || nd.ud != d.ud {
+		return fuse.Errno(syscall.EXDEV)
+	}
+	oldPath, newPath := d.Join(req.OldName), nd.Join(req.NewName)
+	err := d.ud.conn().Rename(ctx, oldPath, newPath)
+	if err != nil {
+		return err
+	}
+	d.ud.cache.invalidate(oldPath, true)
+	d.ud.cache.invalidate(newPath, true)
+	return nil
+}
+
+func (d *RemoteNode) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	if req.Valid.Uid() || req.Valid.Gid() {
+		return fuse.EPERM
+	}
+	cReq := fgrpc.SetattrRequest{
+		Path:     d.Path,
+		SetSize:  req.Valid.Size(),
+		Size:     req.Size,
+		SetMode:  req.Valid.Mode(),
+		Mode:     req.Mode,
+		SetAtime: req.Valid.Atime() || req.Valid.AtimeNow(),
+		Atime:    req.Atime,
+		SetMtime: req.Valid.Mtime() || req.Valid.MtimeNow(),
+		Mtime:    req.Mtime,
+	}
+	if req.Valid.AtimeNow() {
+		cReq.Atime = time.Now()
+	}
+	if req.Valid.MtimeNow() {
+		cReq.Mtime = time.Now()
+	}
+	st, err := d.ud.conn().Setattr(ctx, &cReq)
+	d.ud.cache.invalidate(d.Path, false)
+	if err != nil {
+		return err
+	}
+	d.ud.cache.putAttr(d.Path, st)
+	d.fillAttr(&resp.Attr, st)
+	return nil
+}
+
+func (f *RemoteFile) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.WriteResponse) error {
+	n, err := f.rn.ud.conn().WriteTo(ctx, &fgrpc.WriteRequest{
+		FD:     f.fd,
+		Offset: req.Offset,
+		Data:   req.Data,
+	})
+	// the size and modification time changed even if only part of the
+	// data was written
+	f.rn.ud.cache.invalidate(f.rn.Path, false)
+	resp.Size = n
+	return err
+}
@@ -0,0 +1,126 @@
+package fscore
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	fgrpc "github.com/riking/42fs/grpc"
+)
+
+// watchDelay is how long changes are collected before being sent to
+// subscribers, so that a file being written produces one event rather than
+// one per write.
+const watchDelay = 100 * time.Millisecond
+
+// subscriberBuffer is how many events a subscriber can fall behind by before
+// they are replaced with an overflow event.
+const subscriberBuffer = 64
+
+// changeFeed watches the public directory on behalf of peers subscribed to
+// it. The watcher only runs while someone is subscribed.
+type changeFeed struct {
+	root string
+
+	lock    sync.Mutex
+	subs    map[*changeSub]bool
+	watcher io.Closer
+	// changes seen since the last flush, with whether they were to an entry
+	pending  map[string]bool
+	overflow bool
+	flushing bool
+}
+
+type changeSub struct {
+	c chan fgrpc.ChangeEvent
+}
+
+func newChangeFeed(root string) *changeFeed {
+	return &changeFeed{
+		root:    root,
+		subs:    make(map[*changeSub]bool),
+		pending: make(map[string]bool),
+	}
+}
+
+func (f *changeFeed) subscribe() (*changeSub, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.watcher == nil {
+		w, err := startWatcher(f.root, f.note)
+		if err != nil {
+			return nil, err
+		}
+		f.watcher = w
+	}
+	sub := &changeSub{c: make(chan fgrpc.ChangeEvent, subscriberBuffer)}
+	f.subs[sub] = true
+	return sub, nil
+}
+
+func (f *changeFeed) unsubscribe(sub *changeSub) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.subs, sub)
+	if len(f.subs) == 0 && f.watcher != nil {
+		f.watcher.Close()
+		f.watcher = nil
+	}
+}
+
+// note records a change reported by the watcher. Changes are sent out after
+// watchDelay, merged by path.
+func (f *changeFeed) note(ev fgrpc.ChangeEvent) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if ev.Overflow || len(f.pending) >= subscriberBuffer {
+		f.overflow = true
+	} else {
+		f.pending[ev.Path] = f.pending[ev.Path] || ev.Entry
+	}
+	if !f.flushing {
+		f.flushing = true
+		time.AfterFunc(watchDelay, f.flush)
+	}
+}
+
+func (f *changeFeed) flush() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	var events []fgrpc.ChangeEvent
+	if f.overflow {
+		events = []fgrpc.ChangeEvent{{Path: "/", Overflow: true}}
+	} else {
+		for p, entry := range f.pending {
+			events = append(events, fgrpc.ChangeEvent{Path: p, Entry: entry})
+		}
+	}
+	f.pending = make(map[string]bool)
+	f.overflow = false
+	f.flushing = false
+
+	for sub := range f.subs {
+		for _, ev := range events {
+			sub.send(ev)
+		}
+	}
+}
+
+// send queues ev without blocking. It must be called with the feed locked.
+func (sub *changeSub) send(ev fgrpc.ChangeEvent) {
+	select {
+	case sub.c <- ev:
+		return
+	default:
+	}
+	// The subscriber fell behind. Everything it hasn't read yet is replaced
+	// with one overflow event; since only the feed adds events, there is
+	// room for it once the channel is drained.
+	for len(sub.c) > 0 {
+		select {
+		case <-sub.c:
+		default:
+		}
+	}
+	sub.c <- fgrpc.ChangeEvent{Path: "/", Overflow: true}
+}
@@ -0,0 +1,14 @@
+package fscore
+
+import (
+	"io"
+
+	"bazil.org/fuse"
+	fgrpc "github.com/riking/42fs/grpc"
+)
+
+// startWatcher is not implemented on macOS yet. Readers fall back to
+// expiring their caches.
+func startWatcher(root string, notify func(fgrpc.ChangeEvent)) (io.Closer, error) {
+	return nil, fuse.ENOSYS
+}
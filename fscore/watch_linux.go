@@ -0,0 +1,167 @@
+package fscore
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	fgrpc "github.com/riking/42fs/grpc"
+	"golang.org/x/sys/unix"
+)
+
+const watchMask = unix.IN_MODIFY | unix.IN_ATTRIB | unix.IN_CREATE | unix.IN_DELETE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_DONT_FOLLOW | unix.IN_ONLYDIR
+
+// inotifyWatcher watches every directory under root with inotify(7), which
+// does not watch subdirectories on its own. Directories are added and
+// removed as they appear and disappear.
+type inotifyWatcher struct {
+	root   string
+	notify func(fgrpc.ChangeEvent)
+	f      *os.File
+	rc     syscall.RawConn
+
+	// only used by run once started
+	paths map[int32]string
+	wds   map[string]int32
+}
+
+// startWatcher reports changes under root to notify until the returned
+// Closer is closed. Paths are relative to root and start with a slash, as
+// in UserConnection calls.
+func startWatcher(root string, notify func(fgrpc.ChangeEvent)) (io.Closer, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	w := &inotifyWatcher{
+		root:   root,
+		notify: notify,
+		// non-blocking, so that reads go through the runtime poller and
+		// Close interrupts them
+		f:     os.NewFile(uintptr(fd), "inotify"),
+		paths: make(map[int32]string),
+		wds:   make(map[string]int32),
+	}
+	w.rc, err = w.f.SyscallConn()
+	if err == nil {
+		err = w.addTree("/")
+	}
+	if err != nil {
+		w.f.Close()
+		return nil, err
+	}
+	go w.run()
+	return w.f, nil
+}
+
+func (w *inotifyWatcher) addWatch(p string) (int32, error) {
+	var wd int
+	var err error
+	cErr := w.rc.Control(func(fd uintptr) {
+		wd, err = unix.InotifyAddWatch(int(fd), w.root+p, watchMask)
+	})
+	if cErr != nil {
+		return 0, cErr
+	}
+	return int32(wd), err
+}
+
+func (w *inotifyWatcher) rmWatch(wd int32) {
+	w.rc.Control(func(fd uintptr) {
+		unix.InotifyRmWatch(int(fd), uint32(wd))
+	})
+}
+
+// addTree watches p and every directory below it. Only a failure to watch
+// p itself is returned; subdirectories that can't be watched (say, because
+// fs.inotify.max_user_watches was reached) just don't report changes, and
+// readers see them once their caches expire.
+func (w *inotifyWatcher) addTree(p string) error {
+	wd, err := w.addWatch(p)
+	if err != nil {
+		return err
+	}
+	w.paths[wd] = p
+	w.wds[p] = wd
+	fis, err := ioutil.ReadDir(w.root + p)
+	if err != nil {
+		return nil
+	}
+	for _, fi := range fis {
+		if fi.IsDir() {
+			w.addTree(path.Join(p, fi.Name()))
+		}
+	}
+	return nil
+}
+
+// removeTree stops watching p and everything below it, after it was moved
+// away.
+func (w *inotifyWatcher) removeTree(p string) {
+	for q, wd := range w.wds {
+		if q == p || strings.HasPrefix(q, p+"/") {
+			w.rmWatch(wd)
+			delete(w.wds, q)
+			delete(w.paths, wd)
+		}
+	}
+}
+
+func (w *inotifyWatcher) run() {
+	buf := make([]byte, 64<<10)
+	for {
+		n, err := w.f.Read(buf)
+		if err != nil {
+			if pe, ok := err.(*os.PathError); !ok || pe.Err != os.ErrClosed {
+				log.Println("watching public directory:", err)
+			}
+			return
+		}
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			off += unix.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[off:off+int(ev.Len)]), "\x00")
+			off += int(ev.Len)
+			w.event(ev.Wd, ev.Mask, name)
+		}
+	}
+}
+
+func (w *inotifyWatcher) event(wd int32, mask uint32, name string) {
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		w.notify(fgrpc.ChangeEvent{Path: "/", Overflow: true})
+		return
+	}
+	dir, ok := w.paths[wd]
+	if !ok {
+		return
+	}
+	if mask&unix.IN_IGNORED != 0 {
+		// the directory is gone
+		delete(w.paths, wd)
+		if w.wds[dir] == wd {
+			delete(w.wds, dir)
+		}
+		return
+	}
+
+	p := dir
+	if name != "" {
+		p = path.Join(dir, name)
+	}
+	entry := mask&(unix.IN_CREATE|unix.IN_DELETE|unix.IN_MOVED_FROM|unix.IN_MOVED_TO) != 0
+	if entry && mask&unix.IN_ISDIR != 0 {
+		if mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+			w.addTree(p)
+		} else {
+			w.removeTree(p)
+		}
+	}
+	w.notify(fgrpc.ChangeEvent{Path: p, Entry: entry})
+}
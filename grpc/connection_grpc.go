@@ -0,0 +1,321 @@
+package coordinator
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+
+	"bazil.org/fuse"
+	pb "github.com/riking/42fs/grpc/coordinatorpb"
+	"google.golang.org/grpc"
+)
+
+type Empty struct{}
+
+// connectionServer serves a UserConnection with the generated stubs.
+type connectionServer struct {
+	pb.UnimplementedUserConnectionServer
+	conn UserConnection
+}
+
+func (s *connectionServer) Access(ctx context.Context, req *pb.AccessRequest) (*pb.Empty, error) {
+	err := s.conn.Access(ctx, req.GetPath(), req.GetMode())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return &pb.Empty{}, nil
+}
+
+func (s *connectionServer) Stat(ctx context.Context, req *pb.PathRequest) (*pb.FileAttr, error) {
+	attr, err := s.conn.Stat(ctx, req.GetPath())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return fileAttrToPB(attr), nil
+}
+
+func (s *connectionServer) Getxattr(ctx context.Context, req *pb.XattrRequest) (*pb.DataResponse, error) {
+	b, err := s.conn.Getxattr(ctx, req.GetPath(), req.GetAttr(), req.GetSize(), req.GetPosition())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return &pb.DataResponse{Data: b}, nil
+}
+
+func (s *connectionServer) Listxattr(ctx context.Context, req *pb.XattrRequest) (*pb.DataResponse, error) {
+	b, err := s.conn.Listxattr(ctx, req.GetPath(), req.GetSize(), req.GetPosition())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return &pb.DataResponse{Data: b}, nil
+}
+
+func (s *connectionServer) Open(ctx context.Context, req *pb.OpenRequest) (*pb.OpenResponse, error) {
+	rflags, fd, err := s.conn.Open(ctx, req.GetPath(), req.GetDir(), AgnosticOpenFlags(req.GetFlags()))
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return &pb.OpenResponse{Flags: uint32(rflags), FD: fd}, nil
+}
+
+func (s *connectionServer) Readlink(ctx context.Context, req *pb.PathRequest) (*pb.ReadlinkResponse, error) {
+	target, err := s.conn.Readlink(ctx, req.GetPath())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return &pb.ReadlinkResponse{Target: target}, nil
+}
+
+func (s *connectionServer) LookupExists(ctx context.Context, req *pb.PathRequest) (*pb.Empty, error) {
+	err := s.conn.LookupExists(ctx, req.GetPath())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return &pb.Empty{}, nil
+}
+
+func (s *connectionServer) ReadDir(ctx context.Context, req *pb.FDRequest) (*pb.DirentList, error) {
+	ents, err := s.conn.ReadDir(ctx, req.GetFD())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return direntsToPB(ents), nil
+}
+
+func (s *connectionServer) ReadFrom(ctx context.Context, req *pb.ReadRequest) (*pb.DataResponse, error) {
+	b, err := s.conn.ReadFrom(ctx, readRequestFromPB(req))
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return &pb.DataResponse{Data: b}, nil
+}
+
+func (s *connectionServer) Close(ctx context.Context, req *pb.FDRequest) (*pb.Empty, error) {
+	err := s.conn.Close(ctx, req.GetFD())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return &pb.Empty{}, nil
+}
+
+func (s *connectionServer) Subscribe(req *pb.Empty, stream pb.UserConnection_SubscribeServer) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	events := make(chan ChangeEvent, 16)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.conn.Subscribe(ctx, events)
+	}()
+	for {
+		select {
+		case ev := <-events:
+			err := stream.Send(&pb.ChangeEvent{Path: ev.Path, Entry: ev.Entry, Overflow: ev.Overflow})
+			if err != nil {
+				return err
+			}
+		case err := <-errc:
+			return toGrpcErr(err)
+		}
+	}
+}
+
+func (s *connectionServer) Create(ctx context.Context, req *pb.CreateRequest) (*pb.OpenResponse, error) {
+	rflags, fd, err := s.conn.Create(ctx, req.GetPath(), os.FileMode(req.GetMode()), AgnosticOpenFlags(req.GetFlags()))
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return &pb.OpenResponse{Flags: uint32(rflags), FD: fd}, nil
+}
+
+func (s *connectionServer) Mkdir(ctx context.Context, req *pb.MkdirRequest) (*pb.Empty, error) {
+	err := s.conn.Mkdir(ctx, req.GetPath(), os.FileMode(req.GetMode()))
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return &pb.Empty{}, nil
+}
+
+func (s *connectionServer) Remove(ctx context.Context, req *pb.RemoveRequest) (*pb.Empty, error) {
+	err := s.conn.Remove(ctx, req.GetPath(), req.GetDir())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return &pb.Empty{}, nil
+}
+
+func (s *connectionServer) Rename(ctx context.Context, req *pb.RenameRequest) (*pb.Empty, error) {
+	err := s.conn.Rename(ctx, req.GetOldPath(), req.GetNewPath())
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return &pb.Empty{}, nil
+}
+
+func (s *connectionServer) Setattr(ctx context.Context, req *pb.SetattrRequest) (*pb.FileAttr, error) {
+	attr, err := s.conn.Setattr(ctx, setattrRequestFromPB(req))
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return fileAttrToPB(attr), nil
+}
+
+func (s *connectionServer) WriteTo(ctx context.Context, req *pb.WriteRequest) (*pb.WriteResponse, error) {
+	n, err := s.conn.WriteTo(ctx, &WriteRequest{FD: req.GetFD(), Offset: req.GetOffset(), Data: req.GetData()})
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return &pb.WriteResponse{Size: int64(n)}, nil
+}
+
+// RegisterUserConnection serves a UserConnection implementation on a gRPC
+// server.
+func RegisterUserConnection(s *grpc.Server, srv UserConnection) {
+	pb.RegisterUserConnectionServer(s, &connectionServer{conn: srv})
+}
+
+// userConnClient implements UserConnection over a gRPC connection to a peer
+// daemon.
+type userConnClient struct {
+	c pb.UserConnectionClient
+	// prefix is prepended to every path. The coordinator serves all the
+	// snapshots it holds as one tree, with one directory per login.
+	prefix string
+}
+
+// NewUserConnectionClient wraps a connection to a peer daemon.
+func NewUserConnectionClient(cc *grpc.ClientConn) UserConnection {
+	return &userConnClient{c: pb.NewUserConnectionClient(cc)}
+}
+
+// NewSnapshotConnectionClient reads the snapshot of login's directory that
+// the coordinator at the other end of cc holds.
+func NewSnapshotConnectionClient(cc *grpc.ClientConn, login string) UserConnection {
+	return &userConnClient{c: pb.NewUserConnectionClient(cc), prefix: "/" + login}
+}
+
+func (c *userConnClient) path(p string) string {
+	if c.prefix == "" {
+		return p
+	}
+	return c.prefix + "/" + strings.TrimPrefix(p, "/")
+}
+
+func (c *userConnClient) Access(ctx context.Context, path string, mode uint32) error {
+	_, err := c.c.Access(ctx, &pb.AccessRequest{Path: c.path(path), Mode: mode})
+	return fromGrpcErr(err)
+}
+
+func (c *userConnClient) Stat(ctx context.Context, path string) (*FileAttr, error) {
+	resp, err := c.c.Stat(ctx, &pb.PathRequest{Path: c.path(path)})
+	if err != nil {
+		return nil, fromGrpcErr(err)
+	}
+	return fileAttrFromPB(resp), nil
+}
+
+func (c *userConnClient) Getxattr(ctx context.Context, path string, attr string, size uint32, position uint32) ([]byte, error) {
+	resp, err := c.c.Getxattr(ctx, &pb.XattrRequest{Path: c.path(path), Attr: attr, Size: size, Position: position})
+	return resp.GetData(), fromGrpcErr(err)
+}
+
+func (c *userConnClient) Listxattr(ctx context.Context, path string, size uint32, position uint32) ([]byte, error) {
+	resp, err := c.c.Listxattr(ctx, &pb.XattrRequest{Path: c.path(path), Size: size, Position: position})
+	return resp.GetData(), fromGrpcErr(err)
+}
+
+func (c *userConnClient) Open(ctx context.Context, path string, dir bool, flags AgnosticOpenFlags) (fuse.OpenResponseFlags, uint64, error) {
+	resp, err := c.c.Open(ctx, &pb.OpenRequest{Path: c.path(path), Dir: dir, Flags: uint32(flags)})
+	return fuse.OpenResponseFlags(resp.GetFlags()), resp.GetFD(), fromGrpcErr(err)
+}
+
+func (c *userConnClient) Readlink(ctx context.Context, path string) (string, error) {
+	resp, err := c.c.Readlink(ctx, &pb.PathRequest{Path: c.path(path)})
+	return resp.GetTarget(), fromGrpcErr(err)
+}
+
+func (c *userConnClient) LookupExists(ctx context.Context, path string) error {
+	_, err := c.c.LookupExists(ctx, &pb.PathRequest{Path: c.path(path)})
+	return fromGrpcErr(err)
+}
+
+func (c *userConnClient) ReadDir(ctx context.Context, fd uint64) ([]Dirent, error) {
+	resp, err := c.c.ReadDir(ctx, &pb.FDRequest{FD: fd})
+	if err != nil {
+		return nil, fromGrpcErr(err)
+	}
+	return direntsFromPB(resp), nil
+}
+
+func (c *userConnClient) ReadFrom(ctx context.Context, req *ReadRequest) ([]byte, error) {
+	resp, err := c.c.ReadFrom(ctx, readRequestToPB(req))
+	return resp.GetData(), fromGrpcErr(err)
+}
+
+func (c *userConnClient) Close(ctx context.Context, fd uint64) error {
+	_, err := c.c.Close(ctx, &pb.FDRequest{FD: fd})
+	return fromGrpcErr(err)
+}
+
+func (c *userConnClient) Create(ctx context.Context, path string, mode os.FileMode, flags AgnosticOpenFlags) (fuse.OpenResponseFlags, uint64, error) {
+	resp, err := c.c.Create(ctx, &pb.CreateRequest{Path: c.path(path), Mode: uint32(mode), Flags: uint32(flags)})
+	return fuse.OpenResponseFlags(resp.GetFlags()), resp.GetFD(), fromGrpcErr(err)
+}
+
+func (c *userConnClient) Mkdir(ctx context.Context, path string, mode os.FileMode) error {
+	_, err := c.c.Mkdir(ctx, &pb.MkdirRequest{Path: c.path(path), Mode: uint32(mode)})
+	return fromGrpcErr(err)
+}
+
+func (c *userConnClient) Remove(ctx context.Context, path string, dir bool) error {
+	_, err := c.c.Remove(ctx, &pb.RemoveRequest{Path: c.path(path), Dir: dir})
+	return fromGrpcErr(err)
+}
+
+func (c *userConnClient) Rename(ctx context.Context, oldPath, newPath string) error {
+	_, err := c.c.Rename(ctx, &pb.RenameRequest{OldPath: c.path(oldPath), NewPath: c.path(newPath)})
+	return fromGrpcErr(err)
+}
+
+func (c *userConnClient) Setattr(ctx context.Context, req *SetattrRequest) (*FileAttr, error) {
+	r := setattrRequestToPB(req)
+	r.Path = c.path(req.Path)
+	resp, err := c.c.Setattr(ctx, r)
+	if err != nil {
+		return nil, fromGrpcErr(err)
+	}
+	return fileAttrFromPB(resp), nil
+}
+
+func (c *userConnClient) WriteTo(ctx context.Context, req *WriteRequest) (int, error) {
+	resp, err := c.c.WriteTo(ctx, &pb.WriteRequest{FD: req.FD, Offset: req.Offset, Data: req.Data})
+	return int(resp.GetSize()), fromGrpcErr(err)
+}
+
+func (c *userConnClient) Subscribe(ctx context.Context, events chan<- ChangeEvent) error {
+	stream, err := c.c.Subscribe(ctx, &pb.Empty{})
+	if err != nil {
+		return fromGrpcErr(err)
+	}
+	for {
+		msg, err := stream.Recv()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		} else if err == io.EOF {
+			// the peer ended the subscription without saying why
+			return fuse.Errno(syscall.ECONNRESET)
+		} else if err != nil {
+			return fromGrpcErr(err)
+		}
+		ev := ChangeEvent{Path: msg.GetPath(), Entry: msg.GetEntry(), Overflow: msg.GetOverflow()}
+		if c.prefix != "" {
+			ev.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(ev.Path, c.prefix), "/")
+		}
+		select {
+		case events <- ev:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -11,6 +11,8 @@ import (
 type CoordinatorServer interface {
 	UserDirInfo(ctx context.Context, login string) (*LoginInfo, error)
 	UserDirStat(ctx context.Context, login string) (*FileAttr, error)
+	// ListUsers returns every login with a public folder, sorted by login.
+	ListUsers(ctx context.Context, onlineOnly bool) ([]LoginInfo, error)
 	MyINode(ctx context.Context) uint64
 }
 
@@ -26,6 +28,20 @@ type UserConnection interface {
 	ReadDir(ctx context.Context, fd uint64) ([]Dirent, error)
 	ReadFrom(ctx context.Context, req *ReadRequest) ([]byte, error)
 	Close(ctx context.Context, fd uint64) error
+
+	// Subscribe sends a ChangeEvent on events for every change to the
+	// directory until ctx is done or the subscription fails. It returns
+	// ENOSYS if changes can't be watched.
+	Subscribe(ctx context.Context, events chan<- ChangeEvent) error
+
+	// The write operations are only allowed where the owner opted in to
+	// them, and fail with EROFS elsewhere.
+	Create(ctx context.Context, path string, mode os.FileMode, flags AgnosticOpenFlags) (rflags fuse.OpenResponseFlags, fd uint64, err error)
+	Mkdir(ctx context.Context, path string, mode os.FileMode) error
+	Remove(ctx context.Context, path string, dir bool) error
+	Rename(ctx context.Context, oldPath, newPath string) error
+	Setattr(ctx context.Context, req *SetattrRequest) (*FileAttr, error)
+	WriteTo(ctx context.Context, req *WriteRequest) (int, error)
 }
 
 const (
@@ -38,6 +54,16 @@ type LoginInfo struct {
 	Login     string
 	Exists    bool
 	WasOnline bool
+	// Addr is the host:port of the user's UserConnection endpoint.
+	Addr  string
+	INode uint64
+	// Offline is set when the owner's daemon is not running but the
+	// coordinator can serve a snapshot of the directory instead.
+	Offline bool
+	// Conn reads the snapshot when Offline is set. It is filled in on the
+	// client side and never sent over the wire. In-process coordinators,
+	// like the one in fs42test, also set it for owners who are online.
+	Conn UserConnection `json:"-"`
 }
 
 type ReadRequest struct {
@@ -48,10 +74,43 @@ type ReadRequest struct {
 	FileFlags fuse.OpenFlags
 }
 
+type WriteRequest struct {
+	FD     uint64
+	Offset int64
+	Data   []byte
+}
+
+// SetattrRequest changes the attributes whose Set field is true.
+type SetattrRequest struct {
+	Path     string
+	SetSize  bool
+	Size     uint64
+	SetMode  bool
+	Mode     os.FileMode
+	SetAtime bool
+	Atime    time.Time
+	SetMtime bool
+	Mtime    time.Time
+}
+
+// Dirent and FileAttr carry the inode numbers and devices of the machine
+// they come from. The receiving daemon maps them to its own numbers.
 type Dirent struct {
 	Inode uint64
 	Type  uint32
 	Name  string
+	Dev   uint64
+}
+
+// ChangeEvent reports that something in a user's directory changed.
+type ChangeEvent struct {
+	Path string
+	// Entry is set when Path was created, removed or renamed, rather than
+	// only its contents or attributes changing.
+	Entry bool
+	// Overflow is set when events were lost. The subscriber has to assume
+	// that anything may have changed.
+	Overflow bool
 }
 
 type FileAttr struct {
@@ -66,4 +125,5 @@ type FileAttr struct {
 	Gid       uint32
 	BlockSize uint32
 	Mode      os.FileMode
+	Dev       uint64
 }
@@ -0,0 +1,265 @@
+package coordinator
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/riking/42fs/grpc/coordinatorpb"
+	"google.golang.org/grpc"
+)
+
+type RegisterRequest struct {
+	Login     string
+	Addr      string
+	RootINode uint64
+
+	// PublicKey is the daemon's login key. The coordinator pins it the
+	// first time a login registers.
+	PublicKey []byte
+	Time      time.Time
+	// Signature covers all the other fields but PublicKey, see
+	// auth.SignLogin.
+	Signature []byte
+	// CSR optionally asks for a TLS certificate, see auth.NewCSR.
+	CSR []byte
+}
+
+type RegisterResponse struct {
+	INode uint64
+	// Token identifies the daemon to its peers until it expires.
+	Token     string
+	IssuerKey []byte
+	// Certificate answers RegisterRequest.CSR.
+	Certificate []byte
+}
+
+type UserDirRequest struct {
+	Login string
+}
+
+type ListUsersRequest struct {
+	// OnlineOnly leaves out logins whose daemon is not running.
+	OnlineOnly bool
+}
+
+type UserList struct {
+	Users []LoginInfo
+}
+
+// CoordinatorService is the server side of the Coordinator service.
+type CoordinatorService interface {
+	Register(ctx context.Context, req *RegisterRequest) (*RegisterResponse, error)
+	Unregister(ctx context.Context, req *Empty) (*Empty, error)
+	UserDirInfo(ctx context.Context, req *UserDirRequest) (*LoginInfo, error)
+	UserDirStat(ctx context.Context, req *UserDirRequest) (*FileAttr, error)
+	ListUsers(ctx context.Context, req *ListUsersRequest) (*UserList, error)
+	SyncManifest(ctx context.Context, m *Manifest) (*SyncResponse, error)
+	PutChunks(ctx context.Context, chunks *ChunkList) (*Empty, error)
+}
+
+// coordinatorServer serves a CoordinatorService with the generated stubs.
+type coordinatorServer struct {
+	pb.UnimplementedCoordinatorServer
+	srv CoordinatorService
+}
+
+func (s *coordinatorServer) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.RegisterResponse, error) {
+	resp, err := s.srv.Register(ctx, registerRequestFromPB(req))
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return &pb.RegisterResponse{
+		INode:       resp.INode,
+		Token:       resp.Token,
+		IssuerKey:   resp.IssuerKey,
+		Certificate: resp.Certificate,
+	}, nil
+}
+
+func (s *coordinatorServer) Unregister(ctx context.Context, req *pb.Empty) (*pb.Empty, error) {
+	_, err := s.srv.Unregister(ctx, &Empty{})
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return &pb.Empty{}, nil
+}
+
+func (s *coordinatorServer) UserDirInfo(ctx context.Context, req *pb.UserDirRequest) (*pb.LoginInfo, error) {
+	info, err := s.srv.UserDirInfo(ctx, &UserDirRequest{Login: req.GetLogin()})
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return loginInfoToPB(info), nil
+}
+
+func (s *coordinatorServer) UserDirStat(ctx context.Context, req *pb.UserDirRequest) (*pb.FileAttr, error) {
+	attr, err := s.srv.UserDirStat(ctx, &UserDirRequest{Login: req.GetLogin()})
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return fileAttrToPB(attr), nil
+}
+
+func (s *coordinatorServer) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.UserList, error) {
+	list, err := s.srv.ListUsers(ctx, &ListUsersRequest{OnlineOnly: req.GetOnlineOnly()})
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	resp := &pb.UserList{Users: make([]*pb.LoginInfo, len(list.Users))}
+	for i := range list.Users {
+		resp.Users[i] = loginInfoToPB(&list.Users[i])
+	}
+	return resp, nil
+}
+
+func (s *coordinatorServer) SyncManifest(ctx context.Context, m *pb.Manifest) (*pb.SyncResponse, error) {
+	resp, err := s.srv.SyncManifest(ctx, manifestFromPB(m))
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return &pb.SyncResponse{Missing: resp.Missing}, nil
+}
+
+func (s *coordinatorServer) PutChunks(ctx context.Context, chunks *pb.ChunkList) (*pb.Empty, error) {
+	_, err := s.srv.PutChunks(ctx, &ChunkList{Chunks: chunks.GetChunks()})
+	if err != nil {
+		return nil, toGrpcErr(err)
+	}
+	return &pb.Empty{}, nil
+}
+
+// RegisterCoordinatorService attaches a coordinator implementation to a gRPC
+// server.
+func RegisterCoordinatorService(s *grpc.Server, srv CoordinatorService) {
+	pb.RegisterCoordinatorServer(s, &coordinatorServer{srv: srv})
+}
+
+// CoordinatorClient is the daemon's view of a remote coordination server. It
+// implements CoordinatorServer.
+type CoordinatorClient struct {
+	cc    *grpc.ClientConn
+	c     pb.CoordinatorClient
+	inode uint64
+}
+
+var _ CoordinatorServer = &CoordinatorClient{}
+
+func NewCoordinatorClient(cc *grpc.ClientConn) *CoordinatorClient {
+	return &CoordinatorClient{cc: cc, c: pb.NewCoordinatorClient(cc)}
+}
+
+// Register announces this daemon to the coordinator. MyINode returns 0 until
+// it has succeeded.
+func (c *CoordinatorClient) Register(ctx context.Context, req *RegisterRequest) (*RegisterResponse, error) {
+	resp, err := c.c.Register(ctx, registerRequestToPB(req))
+	if err != nil {
+		return nil, fromGrpcErr(err)
+	}
+	atomic.StoreUint64(&c.inode, resp.GetINode())
+	return &RegisterResponse{
+		INode:       resp.GetINode(),
+		Token:       resp.GetToken(),
+		IssuerKey:   resp.GetIssuerKey(),
+		Certificate: resp.GetCertificate(),
+	}, nil
+}
+
+// KeepRegistered renews the registration every interval until ctx is done,
+// handing each response to onRegistered. The coordinator considers a daemon
+// offline if it stops renewing, and its token expires shortly after.
+func (c *CoordinatorClient) KeepRegistered(ctx context.Context, newReq func() (*RegisterRequest, error), interval time.Duration, onRegistered func(*RegisterResponse) error) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		req, err := newReq()
+		if err == nil {
+			var resp *RegisterResponse
+			resp, err = c.Register(ctx, req)
+			if err == nil {
+				err = onRegistered(resp)
+			}
+		}
+		if err != nil {
+			log.Println("coordinator registration failed:", err)
+		}
+	}
+}
+
+// Unregister tells the coordinator that this daemon is going away, so that
+// readers are sent to its snapshot right away instead of once the
+// registration times out. KeepRegistered must have been stopped first.
+func (c *CoordinatorClient) Unregister(ctx context.Context) error {
+	_, err := c.c.Unregister(ctx, &pb.Empty{})
+	return fromGrpcErr(err)
+}
+
+// UserDirInfo looks up a login. If the owner is offline but left a snapshot
+// behind, the result carries a connection that reads it from the
+// coordinator.
+func (c *CoordinatorClient) UserDirInfo(ctx context.Context, login string) (*LoginInfo, error) {
+	r, err := c.c.UserDirInfo(ctx, &pb.UserDirRequest{Login: login})
+	if err != nil {
+		return nil, fromGrpcErr(err)
+	}
+	resp := loginInfoFromPB(r)
+	if resp.Offline {
+		resp.Conn = NewSnapshotConnectionClient(c.cc, login)
+	}
+	return &resp, nil
+}
+
+func (c *CoordinatorClient) UserDirStat(ctx context.Context, login string) (*FileAttr, error) {
+	resp, err := c.c.UserDirStat(ctx, &pb.UserDirRequest{Login: login})
+	if err != nil {
+		return nil, fromGrpcErr(err)
+	}
+	return fileAttrFromPB(resp), nil
+}
+
+func (c *CoordinatorClient) ListUsers(ctx context.Context, onlineOnly bool) ([]LoginInfo, error) {
+	resp, err := c.c.ListUsers(ctx, &pb.ListUsersRequest{OnlineOnly: onlineOnly})
+	if err != nil {
+		return nil, fromGrpcErr(err)
+	}
+	users := make([]LoginInfo, len(resp.GetUsers()))
+	for i, u := range resp.GetUsers() {
+		users[i] = loginInfoFromPB(u)
+		if users[i].Offline {
+			users[i].Conn = NewSnapshotConnectionClient(c.cc, users[i].Login)
+		}
+	}
+	return users, nil
+}
+
+// SyncManifest offers a new snapshot manifest for this daemon's directory
+// and returns the chunks that must be sent with PutChunks before the
+// coordinator accepts it. The manifest replaces the previous one once
+// nothing is missing.
+func (c *CoordinatorClient) SyncManifest(ctx context.Context, m *Manifest) ([]string, error) {
+	resp, err := c.c.SyncManifest(ctx, manifestToPB(m))
+	if err != nil {
+		return nil, fromGrpcErr(err)
+	}
+	return resp.GetMissing(), nil
+}
+
+// PutChunks uploads file contents for a manifest.
+func (c *CoordinatorClient) PutChunks(ctx context.Context, chunks [][]byte) error {
+	_, err := c.c.PutChunks(ctx, &pb.ChunkList{Chunks: chunks})
+	return fromGrpcErr(err)
+}
+
+func (c *CoordinatorClient) MyINode(ctx context.Context) uint64 {
+	return atomic.LoadUint64(&c.inode)
+}
+
+func (c *CoordinatorClient) Close() error {
+	return c.cc.Close()
+}
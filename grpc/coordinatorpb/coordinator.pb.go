@@ -0,0 +1,2262 @@
+// Wire schema for 42fs.
+//
+// The Go code in coordinatorpb is generated from this file; run go generate
+// in this directory after changing it. The rest of the package converts
+// between those messages and the types fscore and coordserver use.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v3.21.12
+// source: coordinator.proto
+
+package coordinatorpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Empty struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Empty) Reset() {
+	*x = Empty{}
+	mi := &file_coordinator_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Empty) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Empty) ProtoMessage() {}
+
+func (x *Empty) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Empty.ProtoReflect.Descriptor instead.
+func (*Empty) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{0}
+}
+
+type RegisterRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Login string                 `protobuf:"bytes,1,opt,name=Login,proto3" json:"Login,omitempty"`
+	// host:port of the daemon's UserConnection endpoint
+	Addr      string `protobuf:"bytes,2,opt,name=Addr,proto3" json:"Addr,omitempty"`
+	RootINode uint64 `protobuf:"varint,3,opt,name=RootINode,proto3" json:"RootINode,omitempty"`
+	// DER public half of the daemon's login key, pinned on first use
+	PublicKey []byte                 `protobuf:"bytes,4,opt,name=PublicKey,proto3" json:"PublicKey,omitempty"`
+	Time      *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=Time,proto3" json:"Time,omitempty"`
+	// ECDSA signature over Login, Addr, RootINode, the SHA-256 of CSR and Time
+	Signature []byte `protobuf:"bytes,6,opt,name=Signature,proto3" json:"Signature,omitempty"`
+	// optional DER certificate request for the daemon's TLS key
+	CSR           []byte `protobuf:"bytes,7,opt,name=CSR,proto3" json:"CSR,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RegisterRequest) Reset() {
+	*x = RegisterRequest{}
+	mi := &file_coordinator_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RegisterRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterRequest) ProtoMessage() {}
+
+func (x *RegisterRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterRequest.ProtoReflect.Descriptor instead.
+func (*RegisterRequest) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RegisterRequest) GetLogin() string {
+	if x != nil {
+		return x.Login
+	}
+	return ""
+}
+
+func (x *RegisterRequest) GetAddr() string {
+	if x != nil {
+		return x.Addr
+	}
+	return ""
+}
+
+func (x *RegisterRequest) GetRootINode() uint64 {
+	if x != nil {
+		return x.RootINode
+	}
+	return 0
+}
+
+func (x *RegisterRequest) GetPublicKey() []byte {
+	if x != nil {
+		return x.PublicKey
+	}
+	return nil
+}
+
+func (x *RegisterRequest) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *RegisterRequest) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *RegisterRequest) GetCSR() []byte {
+	if x != nil {
+		return x.CSR
+	}
+	return nil
+}
+
+type RegisterResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// inode number the coordinator assigned to the login's directory
+	INode uint64 `protobuf:"varint,1,opt,name=INode,proto3" json:"INode,omitempty"`
+	// short-lived token sent to peers in the x-42fs-token metadata
+	Token string `protobuf:"bytes,2,opt,name=Token,proto3" json:"Token,omitempty"`
+	// DER public key that signs tokens
+	IssuerKey []byte `protobuf:"bytes,3,opt,name=IssuerKey,proto3" json:"IssuerKey,omitempty"`
+	// DER certificate naming the login, if a CSR was sent
+	Certificate   []byte `protobuf:"bytes,4,opt,name=Certificate,proto3" json:"Certificate,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RegisterResponse) Reset() {
+	*x = RegisterResponse{}
+	mi := &file_coordinator_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RegisterResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterResponse) ProtoMessage() {}
+
+func (x *RegisterResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterResponse.ProtoReflect.Descriptor instead.
+func (*RegisterResponse) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RegisterResponse) GetINode() uint64 {
+	if x != nil {
+		return x.INode
+	}
+	return 0
+}
+
+func (x *RegisterResponse) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *RegisterResponse) GetIssuerKey() []byte {
+	if x != nil {
+		return x.IssuerKey
+	}
+	return nil
+}
+
+func (x *RegisterResponse) GetCertificate() []byte {
+	if x != nil {
+		return x.Certificate
+	}
+	return nil
+}
+
+type UserDirRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Login         string                 `protobuf:"bytes,1,opt,name=Login,proto3" json:"Login,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserDirRequest) Reset() {
+	*x = UserDirRequest{}
+	mi := &file_coordinator_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserDirRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserDirRequest) ProtoMessage() {}
+
+func (x *UserDirRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserDirRequest.ProtoReflect.Descriptor instead.
+func (*UserDirRequest) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *UserDirRequest) GetLogin() string {
+	if x != nil {
+		return x.Login
+	}
+	return ""
+}
+
+type ListUsersRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// leave out logins whose daemon is not running
+	OnlineOnly    bool `protobuf:"varint,1,opt,name=OnlineOnly,proto3" json:"OnlineOnly,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListUsersRequest) Reset() {
+	*x = ListUsersRequest{}
+	mi := &file_coordinator_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListUsersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListUsersRequest) ProtoMessage() {}
+
+func (x *ListUsersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListUsersRequest.ProtoReflect.Descriptor instead.
+func (*ListUsersRequest) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListUsersRequest) GetOnlineOnly() bool {
+	if x != nil {
+		return x.OnlineOnly
+	}
+	return false
+}
+
+type UserList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Users         []*LoginInfo           `protobuf:"bytes,1,rep,name=Users,proto3" json:"Users,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserList) Reset() {
+	*x = UserList{}
+	mi := &file_coordinator_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserList) ProtoMessage() {}
+
+func (x *UserList) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserList.ProtoReflect.Descriptor instead.
+func (*UserList) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *UserList) GetUsers() []*LoginInfo {
+	if x != nil {
+		return x.Users
+	}
+	return nil
+}
+
+type LoginInfo struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Login     string                 `protobuf:"bytes,1,opt,name=Login,proto3" json:"Login,omitempty"`
+	Exists    bool                   `protobuf:"varint,2,opt,name=Exists,proto3" json:"Exists,omitempty"`
+	WasOnline bool                   `protobuf:"varint,3,opt,name=WasOnline,proto3" json:"WasOnline,omitempty"`
+	Addr      string                 `protobuf:"bytes,4,opt,name=Addr,proto3" json:"Addr,omitempty"`
+	INode     uint64                 `protobuf:"varint,5,opt,name=INode,proto3" json:"INode,omitempty"`
+	// a snapshot is served by the coordinator's own UserConnection
+	Offline       bool `protobuf:"varint,6,opt,name=Offline,proto3" json:"Offline,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LoginInfo) Reset() {
+	*x = LoginInfo{}
+	mi := &file_coordinator_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LoginInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoginInfo) ProtoMessage() {}
+
+func (x *LoginInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoginInfo.ProtoReflect.Descriptor instead.
+func (*LoginInfo) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *LoginInfo) GetLogin() string {
+	if x != nil {
+		return x.Login
+	}
+	return ""
+}
+
+func (x *LoginInfo) GetExists() bool {
+	if x != nil {
+		return x.Exists
+	}
+	return false
+}
+
+func (x *LoginInfo) GetWasOnline() bool {
+	if x != nil {
+		return x.WasOnline
+	}
+	return false
+}
+
+func (x *LoginInfo) GetAddr() string {
+	if x != nil {
+		return x.Addr
+	}
+	return ""
+}
+
+func (x *LoginInfo) GetINode() uint64 {
+	if x != nil {
+		return x.INode
+	}
+	return 0
+}
+
+func (x *LoginInfo) GetOffline() bool {
+	if x != nil {
+		return x.Offline
+	}
+	return false
+}
+
+type FileAttr struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	INode     uint64                 `protobuf:"varint,1,opt,name=INode,proto3" json:"INode,omitempty"`
+	Size      uint64                 `protobuf:"varint,2,opt,name=Size,proto3" json:"Size,omitempty"`
+	Blocks    uint64                 `protobuf:"varint,3,opt,name=Blocks,proto3" json:"Blocks,omitempty"`
+	Mtime     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=Mtime,proto3" json:"Mtime,omitempty"`
+	Ctime     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=Ctime,proto3" json:"Ctime,omitempty"`
+	BirthTime *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=BirthTime,proto3" json:"BirthTime,omitempty"`
+	Nlink     uint32                 `protobuf:"varint,7,opt,name=Nlink,proto3" json:"Nlink,omitempty"`
+	Uid       uint32                 `protobuf:"varint,8,opt,name=Uid,proto3" json:"Uid,omitempty"`
+	Gid       uint32                 `protobuf:"varint,9,opt,name=Gid,proto3" json:"Gid,omitempty"`
+	BlockSize uint32                 `protobuf:"varint,10,opt,name=BlockSize,proto3" json:"BlockSize,omitempty"`
+	// Go os.FileMode bits
+	Mode uint32 `protobuf:"varint,11,opt,name=Mode,proto3" json:"Mode,omitempty"`
+	// device INode is on, on the machine the attributes come from
+	Dev           uint64 `protobuf:"varint,12,opt,name=Dev,proto3" json:"Dev,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileAttr) Reset() {
+	*x = FileAttr{}
+	mi := &file_coordinator_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileAttr) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileAttr) ProtoMessage() {}
+
+func (x *FileAttr) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileAttr.ProtoReflect.Descriptor instead.
+func (*FileAttr) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *FileAttr) GetINode() uint64 {
+	if x != nil {
+		return x.INode
+	}
+	return 0
+}
+
+func (x *FileAttr) GetSize() uint64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *FileAttr) GetBlocks() uint64 {
+	if x != nil {
+		return x.Blocks
+	}
+	return 0
+}
+
+func (x *FileAttr) GetMtime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Mtime
+	}
+	return nil
+}
+
+func (x *FileAttr) GetCtime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Ctime
+	}
+	return nil
+}
+
+func (x *FileAttr) GetBirthTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.BirthTime
+	}
+	return nil
+}
+
+func (x *FileAttr) GetNlink() uint32 {
+	if x != nil {
+		return x.Nlink
+	}
+	return 0
+}
+
+func (x *FileAttr) GetUid() uint32 {
+	if x != nil {
+		return x.Uid
+	}
+	return 0
+}
+
+func (x *FileAttr) GetGid() uint32 {
+	if x != nil {
+		return x.Gid
+	}
+	return 0
+}
+
+func (x *FileAttr) GetBlockSize() uint32 {
+	if x != nil {
+		return x.BlockSize
+	}
+	return 0
+}
+
+func (x *FileAttr) GetMode() uint32 {
+	if x != nil {
+		return x.Mode
+	}
+	return 0
+}
+
+func (x *FileAttr) GetDev() uint64 {
+	if x != nil {
+		return x.Dev
+	}
+	return 0
+}
+
+type Manifest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Login         string                 `protobuf:"bytes,1,opt,name=Login,proto3" json:"Login,omitempty"`
+	Taken         *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=Taken,proto3" json:"Taken,omitempty"`
+	Entries       []*ManifestEntry       `protobuf:"bytes,3,rep,name=Entries,proto3" json:"Entries,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Manifest) Reset() {
+	*x = Manifest{}
+	mi := &file_coordinator_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Manifest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Manifest) ProtoMessage() {}
+
+func (x *Manifest) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Manifest.ProtoReflect.Descriptor instead.
+func (*Manifest) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Manifest) GetLogin() string {
+	if x != nil {
+		return x.Login
+	}
+	return ""
+}
+
+func (x *Manifest) GetTaken() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Taken
+	}
+	return nil
+}
+
+func (x *Manifest) GetEntries() []*ManifestEntry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+type ManifestEntry struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// rooted at the public directory, which is "/"
+	Path   string    `protobuf:"bytes,1,opt,name=Path,proto3" json:"Path,omitempty"`
+	Attr   *FileAttr `protobuf:"bytes,2,opt,name=Attr,proto3" json:"Attr,omitempty"`
+	Target string    `protobuf:"bytes,3,opt,name=Target,proto3" json:"Target,omitempty"`
+	// hex SHA-256 of each 64 KiB chunk of a regular file
+	Chunks []string `protobuf:"bytes,4,rep,name=Chunks,proto3" json:"Chunks,omitempty"`
+	// set for files too large to include
+	Omitted       bool `protobuf:"varint,5,opt,name=Omitted,proto3" json:"Omitted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ManifestEntry) Reset() {
+	*x = ManifestEntry{}
+	mi := &file_coordinator_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ManifestEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ManifestEntry) ProtoMessage() {}
+
+func (x *ManifestEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ManifestEntry.ProtoReflect.Descriptor instead.
+func (*ManifestEntry) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ManifestEntry) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *ManifestEntry) GetAttr() *FileAttr {
+	if x != nil {
+		return x.Attr
+	}
+	return nil
+}
+
+func (x *ManifestEntry) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *ManifestEntry) GetChunks() []string {
+	if x != nil {
+		return x.Chunks
+	}
+	return nil
+}
+
+func (x *ManifestEntry) GetOmitted() bool {
+	if x != nil {
+		return x.Omitted
+	}
+	return false
+}
+
+type SyncResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Missing       []string               `protobuf:"bytes,1,rep,name=Missing,proto3" json:"Missing,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncResponse) Reset() {
+	*x = SyncResponse{}
+	mi := &file_coordinator_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncResponse) ProtoMessage() {}
+
+func (x *SyncResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncResponse.ProtoReflect.Descriptor instead.
+func (*SyncResponse) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *SyncResponse) GetMissing() []string {
+	if x != nil {
+		return x.Missing
+	}
+	return nil
+}
+
+type ChunkList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Chunks        [][]byte               `protobuf:"bytes,1,rep,name=Chunks,proto3" json:"Chunks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChunkList) Reset() {
+	*x = ChunkList{}
+	mi := &file_coordinator_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChunkList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChunkList) ProtoMessage() {}
+
+func (x *ChunkList) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChunkList.ProtoReflect.Descriptor instead.
+func (*ChunkList) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ChunkList) GetChunks() [][]byte {
+	if x != nil {
+		return x.Chunks
+	}
+	return nil
+}
+
+type PathRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=Path,proto3" json:"Path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PathRequest) Reset() {
+	*x = PathRequest{}
+	mi := &file_coordinator_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PathRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PathRequest) ProtoMessage() {}
+
+func (x *PathRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PathRequest.ProtoReflect.Descriptor instead.
+func (*PathRequest) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *PathRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+type AccessRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=Path,proto3" json:"Path,omitempty"`
+	Mode          uint32                 `protobuf:"varint,2,opt,name=Mode,proto3" json:"Mode,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AccessRequest) Reset() {
+	*x = AccessRequest{}
+	mi := &file_coordinator_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AccessRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AccessRequest) ProtoMessage() {}
+
+func (x *AccessRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AccessRequest.ProtoReflect.Descriptor instead.
+func (*AccessRequest) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *AccessRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *AccessRequest) GetMode() uint32 {
+	if x != nil {
+		return x.Mode
+	}
+	return 0
+}
+
+type XattrRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=Path,proto3" json:"Path,omitempty"`
+	Attr          string                 `protobuf:"bytes,2,opt,name=Attr,proto3" json:"Attr,omitempty"`
+	Size          uint32                 `protobuf:"varint,3,opt,name=Size,proto3" json:"Size,omitempty"`
+	Position      uint32                 `protobuf:"varint,4,opt,name=Position,proto3" json:"Position,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *XattrRequest) Reset() {
+	*x = XattrRequest{}
+	mi := &file_coordinator_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *XattrRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*XattrRequest) ProtoMessage() {}
+
+func (x *XattrRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use XattrRequest.ProtoReflect.Descriptor instead.
+func (*XattrRequest) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *XattrRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *XattrRequest) GetAttr() string {
+	if x != nil {
+		return x.Attr
+	}
+	return ""
+}
+
+func (x *XattrRequest) GetSize() uint32 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *XattrRequest) GetPosition() uint32 {
+	if x != nil {
+		return x.Position
+	}
+	return 0
+}
+
+type DataResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=Data,proto3" json:"Data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DataResponse) Reset() {
+	*x = DataResponse{}
+	mi := &file_coordinator_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DataResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DataResponse) ProtoMessage() {}
+
+func (x *DataResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DataResponse.ProtoReflect.Descriptor instead.
+func (*DataResponse) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *DataResponse) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type OpenRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Path  string                 `protobuf:"bytes,1,opt,name=Path,proto3" json:"Path,omitempty"`
+	Dir   bool                   `protobuf:"varint,2,opt,name=Dir,proto3" json:"Dir,omitempty"`
+	// AgnosticOpenFlags
+	Flags         uint32 `protobuf:"varint,3,opt,name=Flags,proto3" json:"Flags,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OpenRequest) Reset() {
+	*x = OpenRequest{}
+	mi := &file_coordinator_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OpenRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpenRequest) ProtoMessage() {}
+
+func (x *OpenRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpenRequest.ProtoReflect.Descriptor instead.
+func (*OpenRequest) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *OpenRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *OpenRequest) GetDir() bool {
+	if x != nil {
+		return x.Dir
+	}
+	return false
+}
+
+func (x *OpenRequest) GetFlags() uint32 {
+	if x != nil {
+		return x.Flags
+	}
+	return 0
+}
+
+type OpenResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// fuse.OpenResponseFlags
+	Flags         uint32 `protobuf:"varint,1,opt,name=Flags,proto3" json:"Flags,omitempty"`
+	FD            uint64 `protobuf:"varint,2,opt,name=FD,proto3" json:"FD,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OpenResponse) Reset() {
+	*x = OpenResponse{}
+	mi := &file_coordinator_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OpenResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OpenResponse) ProtoMessage() {}
+
+func (x *OpenResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OpenResponse.ProtoReflect.Descriptor instead.
+func (*OpenResponse) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *OpenResponse) GetFlags() uint32 {
+	if x != nil {
+		return x.Flags
+	}
+	return 0
+}
+
+func (x *OpenResponse) GetFD() uint64 {
+	if x != nil {
+		return x.FD
+	}
+	return 0
+}
+
+type ReadlinkResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Target        string                 `protobuf:"bytes,1,opt,name=Target,proto3" json:"Target,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReadlinkResponse) Reset() {
+	*x = ReadlinkResponse{}
+	mi := &file_coordinator_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReadlinkResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReadlinkResponse) ProtoMessage() {}
+
+func (x *ReadlinkResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReadlinkResponse.ProtoReflect.Descriptor instead.
+func (*ReadlinkResponse) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *ReadlinkResponse) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+type FDRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FD            uint64                 `protobuf:"varint,1,opt,name=FD,proto3" json:"FD,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FDRequest) Reset() {
+	*x = FDRequest{}
+	mi := &file_coordinator_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FDRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FDRequest) ProtoMessage() {}
+
+func (x *FDRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FDRequest.ProtoReflect.Descriptor instead.
+func (*FDRequest) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *FDRequest) GetFD() uint64 {
+	if x != nil {
+		return x.FD
+	}
+	return 0
+}
+
+type Dirent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Inode         uint64                 `protobuf:"varint,1,opt,name=Inode,proto3" json:"Inode,omitempty"`
+	Type          uint32                 `protobuf:"varint,2,opt,name=Type,proto3" json:"Type,omitempty"`
+	Name          string                 `protobuf:"bytes,3,opt,name=Name,proto3" json:"Name,omitempty"`
+	Dev           uint64                 `protobuf:"varint,4,opt,name=Dev,proto3" json:"Dev,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Dirent) Reset() {
+	*x = Dirent{}
+	mi := &file_coordinator_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Dirent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Dirent) ProtoMessage() {}
+
+func (x *Dirent) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Dirent.ProtoReflect.Descriptor instead.
+func (*Dirent) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *Dirent) GetInode() uint64 {
+	if x != nil {
+		return x.Inode
+	}
+	return 0
+}
+
+func (x *Dirent) GetType() uint32 {
+	if x != nil {
+		return x.Type
+	}
+	return 0
+}
+
+func (x *Dirent) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Dirent) GetDev() uint64 {
+	if x != nil {
+		return x.Dev
+	}
+	return 0
+}
+
+type ChangeEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Path  string                 `protobuf:"bytes,1,opt,name=Path,proto3" json:"Path,omitempty"`
+	// set when Path was created, removed or renamed
+	Entry bool `protobuf:"varint,2,opt,name=Entry,proto3" json:"Entry,omitempty"`
+	// set when events were lost and anything may have changed
+	Overflow      bool `protobuf:"varint,3,opt,name=Overflow,proto3" json:"Overflow,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChangeEvent) Reset() {
+	*x = ChangeEvent{}
+	mi := &file_coordinator_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChangeEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChangeEvent) ProtoMessage() {}
+
+func (x *ChangeEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChangeEvent.ProtoReflect.Descriptor instead.
+func (*ChangeEvent) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *ChangeEvent) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *ChangeEvent) GetEntry() bool {
+	if x != nil {
+		return x.Entry
+	}
+	return false
+}
+
+func (x *ChangeEvent) GetOverflow() bool {
+	if x != nil {
+		return x.Overflow
+	}
+	return false
+}
+
+type DirentList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entries       []*Dirent              `protobuf:"bytes,1,rep,name=Entries,proto3" json:"Entries,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DirentList) Reset() {
+	*x = DirentList{}
+	mi := &file_coordinator_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DirentList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DirentList) ProtoMessage() {}
+
+func (x *DirentList) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DirentList.ProtoReflect.Descriptor instead.
+func (*DirentList) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *DirentList) GetEntries() []*Dirent {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+type ReadRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	FD     uint64                 `protobuf:"varint,1,opt,name=FD,proto3" json:"FD,omitempty"`
+	Dir    bool                   `protobuf:"varint,2,opt,name=Dir,proto3" json:"Dir,omitempty"`
+	Offset int64                  `protobuf:"varint,3,opt,name=Offset,proto3" json:"Offset,omitempty"`
+	Size   int64                  `protobuf:"varint,4,opt,name=Size,proto3" json:"Size,omitempty"`
+	// fuse.OpenFlags
+	FileFlags     uint32 `protobuf:"varint,5,opt,name=FileFlags,proto3" json:"FileFlags,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReadRequest) Reset() {
+	*x = ReadRequest{}
+	mi := &file_coordinator_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReadRequest) ProtoMessage() {}
+
+func (x *ReadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReadRequest.ProtoReflect.Descriptor instead.
+func (*ReadRequest) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *ReadRequest) GetFD() uint64 {
+	if x != nil {
+		return x.FD
+	}
+	return 0
+}
+
+func (x *ReadRequest) GetDir() bool {
+	if x != nil {
+		return x.Dir
+	}
+	return false
+}
+
+func (x *ReadRequest) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *ReadRequest) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *ReadRequest) GetFileFlags() uint32 {
+	if x != nil {
+		return x.FileFlags
+	}
+	return 0
+}
+
+type CreateRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Path  string                 `protobuf:"bytes,1,opt,name=Path,proto3" json:"Path,omitempty"`
+	// Go os.FileMode bits
+	Mode uint32 `protobuf:"varint,2,opt,name=Mode,proto3" json:"Mode,omitempty"`
+	// AgnosticOpenFlags
+	Flags         uint32 `protobuf:"varint,3,opt,name=Flags,proto3" json:"Flags,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateRequest) Reset() {
+	*x = CreateRequest{}
+	mi := &file_coordinator_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateRequest) ProtoMessage() {}
+
+func (x *CreateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateRequest.ProtoReflect.Descriptor instead.
+func (*CreateRequest) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *CreateRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *CreateRequest) GetMode() uint32 {
+	if x != nil {
+		return x.Mode
+	}
+	return 0
+}
+
+func (x *CreateRequest) GetFlags() uint32 {
+	if x != nil {
+		return x.Flags
+	}
+	return 0
+}
+
+type MkdirRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Path  string                 `protobuf:"bytes,1,opt,name=Path,proto3" json:"Path,omitempty"`
+	// Go os.FileMode bits
+	Mode          uint32 `protobuf:"varint,2,opt,name=Mode,proto3" json:"Mode,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MkdirRequest) Reset() {
+	*x = MkdirRequest{}
+	mi := &file_coordinator_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MkdirRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MkdirRequest) ProtoMessage() {}
+
+func (x *MkdirRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MkdirRequest.ProtoReflect.Descriptor instead.
+func (*MkdirRequest) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *MkdirRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *MkdirRequest) GetMode() uint32 {
+	if x != nil {
+		return x.Mode
+	}
+	return 0
+}
+
+type RemoveRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=Path,proto3" json:"Path,omitempty"`
+	Dir           bool                   `protobuf:"varint,2,opt,name=Dir,proto3" json:"Dir,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RemoveRequest) Reset() {
+	*x = RemoveRequest{}
+	mi := &file_coordinator_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemoveRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveRequest) ProtoMessage() {}
+
+func (x *RemoveRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveRequest.ProtoReflect.Descriptor instead.
+func (*RemoveRequest) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *RemoveRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *RemoveRequest) GetDir() bool {
+	if x != nil {
+		return x.Dir
+	}
+	return false
+}
+
+type RenameRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	OldPath       string                 `protobuf:"bytes,1,opt,name=OldPath,proto3" json:"OldPath,omitempty"`
+	NewPath       string                 `protobuf:"bytes,2,opt,name=NewPath,proto3" json:"NewPath,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RenameRequest) Reset() {
+	*x = RenameRequest{}
+	mi := &file_coordinator_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RenameRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenameRequest) ProtoMessage() {}
+
+func (x *RenameRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenameRequest.ProtoReflect.Descriptor instead.
+func (*RenameRequest) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *RenameRequest) GetOldPath() string {
+	if x != nil {
+		return x.OldPath
+	}
+	return ""
+}
+
+func (x *RenameRequest) GetNewPath() string {
+	if x != nil {
+		return x.NewPath
+	}
+	return ""
+}
+
+// SetattrRequest changes the attributes whose Set field is true.
+type SetattrRequest struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Path    string                 `protobuf:"bytes,1,opt,name=Path,proto3" json:"Path,omitempty"`
+	SetSize bool                   `protobuf:"varint,2,opt,name=SetSize,proto3" json:"SetSize,omitempty"`
+	Size    uint64                 `protobuf:"varint,3,opt,name=Size,proto3" json:"Size,omitempty"`
+	SetMode bool                   `protobuf:"varint,4,opt,name=SetMode,proto3" json:"SetMode,omitempty"`
+	// Go os.FileMode bits
+	Mode          uint32                 `protobuf:"varint,5,opt,name=Mode,proto3" json:"Mode,omitempty"`
+	SetAtime      bool                   `protobuf:"varint,6,opt,name=SetAtime,proto3" json:"SetAtime,omitempty"`
+	Atime         *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=Atime,proto3" json:"Atime,omitempty"`
+	SetMtime      bool                   `protobuf:"varint,8,opt,name=SetMtime,proto3" json:"SetMtime,omitempty"`
+	Mtime         *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=Mtime,proto3" json:"Mtime,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetattrRequest) Reset() {
+	*x = SetattrRequest{}
+	mi := &file_coordinator_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetattrRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetattrRequest) ProtoMessage() {}
+
+func (x *SetattrRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetattrRequest.ProtoReflect.Descriptor instead.
+func (*SetattrRequest) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *SetattrRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *SetattrRequest) GetSetSize() bool {
+	if x != nil {
+		return x.SetSize
+	}
+	return false
+}
+
+func (x *SetattrRequest) GetSize() uint64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *SetattrRequest) GetSetMode() bool {
+	if x != nil {
+		return x.SetMode
+	}
+	return false
+}
+
+func (x *SetattrRequest) GetMode() uint32 {
+	if x != nil {
+		return x.Mode
+	}
+	return 0
+}
+
+func (x *SetattrRequest) GetSetAtime() bool {
+	if x != nil {
+		return x.SetAtime
+	}
+	return false
+}
+
+func (x *SetattrRequest) GetAtime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Atime
+	}
+	return nil
+}
+
+func (x *SetattrRequest) GetSetMtime() bool {
+	if x != nil {
+		return x.SetMtime
+	}
+	return false
+}
+
+func (x *SetattrRequest) GetMtime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Mtime
+	}
+	return nil
+}
+
+type WriteRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FD            uint64                 `protobuf:"varint,1,opt,name=FD,proto3" json:"FD,omitempty"`
+	Offset        int64                  `protobuf:"varint,2,opt,name=Offset,proto3" json:"Offset,omitempty"`
+	Data          []byte                 `protobuf:"bytes,3,opt,name=Data,proto3" json:"Data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WriteRequest) Reset() {
+	*x = WriteRequest{}
+	mi := &file_coordinator_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WriteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WriteRequest) ProtoMessage() {}
+
+func (x *WriteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WriteRequest.ProtoReflect.Descriptor instead.
+func (*WriteRequest) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *WriteRequest) GetFD() uint64 {
+	if x != nil {
+		return x.FD
+	}
+	return 0
+}
+
+func (x *WriteRequest) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *WriteRequest) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type WriteResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Size          int64                  `protobuf:"varint,1,opt,name=Size,proto3" json:"Size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WriteResponse) Reset() {
+	*x = WriteResponse{}
+	mi := &file_coordinator_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WriteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WriteResponse) ProtoMessage() {}
+
+func (x *WriteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WriteResponse.ProtoReflect.Descriptor instead.
+func (*WriteResponse) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *WriteResponse) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+// FS42GrpcErr is attached to the status of failed calls as a detail.
+type FS42GrpcErr struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// errno the call failed with, or 0 if only Msg is known
+	ErrNum        int32  `protobuf:"varint,1,opt,name=ErrNum,proto3" json:"ErrNum,omitempty"`
+	Msg           string `protobuf:"bytes,2,opt,name=Msg,proto3" json:"Msg,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FS42GrpcErr) Reset() {
+	*x = FS42GrpcErr{}
+	mi := &file_coordinator_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FS42GrpcErr) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FS42GrpcErr) ProtoMessage() {}
+
+func (x *FS42GrpcErr) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FS42GrpcErr.ProtoReflect.Descriptor instead.
+func (*FS42GrpcErr) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *FS42GrpcErr) GetErrNum() int32 {
+	if x != nil {
+		return x.ErrNum
+	}
+	return 0
+}
+
+func (x *FS42GrpcErr) GetMsg() string {
+	if x != nil {
+		return x.Msg
+	}
+	return ""
+}
+
+var File_coordinator_proto protoreflect.FileDescriptor
+
+const file_coordinator_proto_rawDesc = "" +
+	"\n" +
+	"\x11coordinator.proto\x12\vcoordinator\x1a\x1fgoogle/protobuf/timestamp.proto\"\a\n" +
+	"\x05Empty\"\xd7\x01\n" +
+	"\x0fRegisterRequest\x12\x14\n" +
+	"\x05Login\x18\x01 \x01(\tR\x05Login\x12\x12\n" +
+	"\x04Addr\x18\x02 \x01(\tR\x04Addr\x12\x1c\n" +
+	"\tRootINode\x18\x03 \x01(\x04R\tRootINode\x12\x1c\n" +
+	"\tPublicKey\x18\x04 \x01(\fR\tPublicKey\x12.\n" +
+	"\x04Time\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\x04Time\x12\x1c\n" +
+	"\tSignature\x18\x06 \x01(\fR\tSignature\x12\x10\n" +
+	"\x03CSR\x18\a \x01(\fR\x03CSR\"~\n" +
+	"\x10RegisterResponse\x12\x14\n" +
+	"\x05INode\x18\x01 \x01(\x04R\x05INode\x12\x14\n" +
+	"\x05Token\x18\x02 \x01(\tR\x05Token\x12\x1c\n" +
+	"\tIssuerKey\x18\x03 \x01(\fR\tIssuerKey\x12 \n" +
+	"\vCertificate\x18\x04 \x01(\fR\vCertificate\"&\n" +
+	"\x0eUserDirRequest\x12\x14\n" +
+	"\x05Login\x18\x01 \x01(\tR\x05Login\"2\n" +
+	"\x10ListUsersRequest\x12\x1e\n" +
+	"\n" +
+	"OnlineOnly\x18\x01 \x01(\bR\n" +
+	"OnlineOnly\"8\n" +
+	"\bUserList\x12,\n" +
+	"\x05Users\x18\x01 \x03(\v2\x16.coordinator.LoginInfoR\x05Users\"\x9b\x01\n" +
+	"\tLoginInfo\x12\x14\n" +
+	"\x05Login\x18\x01 \x01(\tR\x05Login\x12\x16\n" +
+	"\x06Exists\x18\x02 \x01(\bR\x06Exists\x12\x1c\n" +
+	"\tWasOnline\x18\x03 \x01(\bR\tWasOnline\x12\x12\n" +
+	"\x04Addr\x18\x04 \x01(\tR\x04Addr\x12\x14\n" +
+	"\x05INode\x18\x05 \x01(\x04R\x05INode\x12\x18\n" +
+	"\aOffline\x18\x06 \x01(\bR\aOffline\"\xe8\x02\n" +
+	"\bFileAttr\x12\x14\n" +
+	"\x05INode\x18\x01 \x01(\x04R\x05INode\x12\x12\n" +
+	"\x04Size\x18\x02 \x01(\x04R\x04Size\x12\x16\n" +
+	"\x06Blocks\x18\x03 \x01(\x04R\x06Blocks\x120\n" +
+	"\x05Mtime\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x05Mtime\x120\n" +
+	"\x05Ctime\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\x05Ctime\x128\n" +
+	"\tBirthTime\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tBirthTime\x12\x14\n" +
+	"\x05Nlink\x18\a \x01(\rR\x05Nlink\x12\x10\n" +
+	"\x03Uid\x18\b \x01(\rR\x03Uid\x12\x10\n" +
+	"\x03Gid\x18\t \x01(\rR\x03Gid\x12\x1c\n" +
+	"\tBlockSize\x18\n" +
+	" \x01(\rR\tBlockSize\x12\x12\n" +
+	"\x04Mode\x18\v \x01(\rR\x04Mode\x12\x10\n" +
+	"\x03Dev\x18\f \x01(\x04R\x03Dev\"\x88\x01\n" +
+	"\bManifest\x12\x14\n" +
+	"\x05Login\x18\x01 \x01(\tR\x05Login\x120\n" +
+	"\x05Taken\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x05Taken\x124\n" +
+	"\aEntries\x18\x03 \x03(\v2\x1a.coordinator.ManifestEntryR\aEntries\"\x98\x01\n" +
+	"\rManifestEntry\x12\x12\n" +
+	"\x04Path\x18\x01 \x01(\tR\x04Path\x12)\n" +
+	"\x04Attr\x18\x02 \x01(\v2\x15.coordinator.FileAttrR\x04Attr\x12\x16\n" +
+	"\x06Target\x18\x03 \x01(\tR\x06Target\x12\x16\n" +
+	"\x06Chunks\x18\x04 \x03(\tR\x06Chunks\x12\x18\n" +
+	"\aOmitted\x18\x05 \x01(\bR\aOmitted\"(\n" +
+	"\fSyncResponse\x12\x18\n" +
+	"\aMissing\x18\x01 \x03(\tR\aMissing\"#\n" +
+	"\tChunkList\x12\x16\n" +
+	"\x06Chunks\x18\x01 \x03(\fR\x06Chunks\"!\n" +
+	"\vPathRequest\x12\x12\n" +
+	"\x04Path\x18\x01 \x01(\tR\x04Path\"7\n" +
+	"\rAccessRequest\x12\x12\n" +
+	"\x04Path\x18\x01 \x01(\tR\x04Path\x12\x12\n" +
+	"\x04Mode\x18\x02 \x01(\rR\x04Mode\"f\n" +
+	"\fXattrRequest\x12\x12\n" +
+	"\x04Path\x18\x01 \x01(\tR\x04Path\x12\x12\n" +
+	"\x04Attr\x18\x02 \x01(\tR\x04Attr\x12\x12\n" +
+	"\x04Size\x18\x03 \x01(\rR\x04Size\x12\x1a\n" +
+	"\bPosition\x18\x04 \x01(\rR\bPosition\"\"\n" +
+	"\fDataResponse\x12\x12\n" +
+	"\x04Data\x18\x01 \x01(\fR\x04Data\"I\n" +
+	"\vOpenRequest\x12\x12\n" +
+	"\x04Path\x18\x01 \x01(\tR\x04Path\x12\x10\n" +
+	"\x03Dir\x18\x02 \x01(\bR\x03Dir\x12\x14\n" +
+	"\x05Flags\x18\x03 \x01(\rR\x05Flags\"4\n" +
+	"\fOpenResponse\x12\x14\n" +
+	"\x05Flags\x18\x01 \x01(\rR\x05Flags\x12\x0e\n" +
+	"\x02FD\x18\x02 \x01(\x04R\x02FD\"*\n" +
+	"\x10ReadlinkResponse\x12\x16\n" +
+	"\x06Target\x18\x01 \x01(\tR\x06Target\"\x1b\n" +
+	"\tFDRequest\x12\x0e\n" +
+	"\x02FD\x18\x01 \x01(\x04R\x02FD\"X\n" +
+	"\x06Dirent\x12\x14\n" +
+	"\x05Inode\x18\x01 \x01(\x04R\x05Inode\x12\x12\n" +
+	"\x04Type\x18\x02 \x01(\rR\x04Type\x12\x12\n" +
+	"\x04Name\x18\x03 \x01(\tR\x04Name\x12\x10\n" +
+	"\x03Dev\x18\x04 \x01(\x04R\x03Dev\"S\n" +
+	"\vChangeEvent\x12\x12\n" +
+	"\x04Path\x18\x01 \x01(\tR\x04Path\x12\x14\n" +
+	"\x05Entry\x18\x02 \x01(\bR\x05Entry\x12\x1a\n" +
+	"\bOverflow\x18\x03 \x01(\bR\bOverflow\";\n" +
+	"\n" +
+	"DirentList\x12-\n" +
+	"\aEntries\x18\x01 \x03(\v2\x13.coordinator.DirentR\aEntries\"y\n" +
+	"\vReadRequest\x12\x0e\n" +
+	"\x02FD\x18\x01 \x01(\x04R\x02FD\x12\x10\n" +
+	"\x03Dir\x18\x02 \x01(\bR\x03Dir\x12\x16\n" +
+	"\x06Offset\x18\x03 \x01(\x03R\x06Offset\x12\x12\n" +
+	"\x04Size\x18\x04 \x01(\x03R\x04Size\x12\x1c\n" +
+	"\tFileFlags\x18\x05 \x01(\rR\tFileFlags\"M\n" +
+	"\rCreateRequest\x12\x12\n" +
+	"\x04Path\x18\x01 \x01(\tR\x04Path\x12\x12\n" +
+	"\x04Mode\x18\x02 \x01(\rR\x04Mode\x12\x14\n" +
+	"\x05Flags\x18\x03 \x01(\rR\x05Flags\"6\n" +
+	"\fMkdirRequest\x12\x12\n" +
+	"\x04Path\x18\x01 \x01(\tR\x04Path\x12\x12\n" +
+	"\x04Mode\x18\x02 \x01(\rR\x04Mode\"5\n" +
+	"\rRemoveRequest\x12\x12\n" +
+	"\x04Path\x18\x01 \x01(\tR\x04Path\x12\x10\n" +
+	"\x03Dir\x18\x02 \x01(\bR\x03Dir\"C\n" +
+	"\rRenameRequest\x12\x18\n" +
+	"\aOldPath\x18\x01 \x01(\tR\aOldPath\x12\x18\n" +
+	"\aNewPath\x18\x02 \x01(\tR\aNewPath\"\x9c\x02\n" +
+	"\x0eSetattrRequest\x12\x12\n" +
+	"\x04Path\x18\x01 \x01(\tR\x04Path\x12\x18\n" +
+	"\aSetSize\x18\x02 \x01(\bR\aSetSize\x12\x12\n" +
+	"\x04Size\x18\x03 \x01(\x04R\x04Size\x12\x18\n" +
+	"\aSetMode\x18\x04 \x01(\bR\aSetMode\x12\x12\n" +
+	"\x04Mode\x18\x05 \x01(\rR\x04Mode\x12\x1a\n" +
+	"\bSetAtime\x18\x06 \x01(\bR\bSetAtime\x120\n" +
+	"\x05Atime\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\x05Atime\x12\x1a\n" +
+	"\bSetMtime\x18\b \x01(\bR\bSetMtime\x120\n" +
+	"\x05Mtime\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\x05Mtime\"J\n" +
+	"\fWriteRequest\x12\x0e\n" +
+	"\x02FD\x18\x01 \x01(\x04R\x02FD\x12\x16\n" +
+	"\x06Offset\x18\x02 \x01(\x03R\x06Offset\x12\x12\n" +
+	"\x04Data\x18\x03 \x01(\fR\x04Data\"#\n" +
+	"\rWriteResponse\x12\x12\n" +
+	"\x04Size\x18\x01 \x01(\x03R\x04Size\"7\n" +
+	"\vFS42GrpcErr\x12\x16\n" +
+	"\x06ErrNum\x18\x01 \x01(\x05R\x06ErrNum\x12\x10\n" +
+	"\x03Msg\x18\x02 \x01(\tR\x03Msg2\xd1\x03\n" +
+	"\vCoordinator\x12G\n" +
+	"\bRegister\x12\x1c.coordinator.RegisterRequest\x1a\x1d.coordinator.RegisterResponse\x124\n" +
+	"\n" +
+	"Unregister\x12\x12.coordinator.Empty\x1a\x12.coordinator.Empty\x12B\n" +
+	"\vUserDirInfo\x12\x1b.coordinator.UserDirRequest\x1a\x16.coordinator.LoginInfo\x12A\n" +
+	"\vUserDirStat\x12\x1b.coordinator.UserDirRequest\x1a\x15.coordinator.FileAttr\x12A\n" +
+	"\tListUsers\x12\x1d.coordinator.ListUsersRequest\x1a\x15.coordinator.UserList\x12@\n" +
+	"\fSyncManifest\x12\x15.coordinator.Manifest\x1a\x19.coordinator.SyncResponse\x127\n" +
+	"\tPutChunks\x12\x16.coordinator.ChunkList\x1a\x12.coordinator.Empty2\xa5\b\n" +
+	"\x0eUserConnection\x128\n" +
+	"\x06Access\x12\x1a.coordinator.AccessRequest\x1a\x12.coordinator.Empty\x127\n" +
+	"\x04Stat\x12\x18.coordinator.PathRequest\x1a\x15.coordinator.FileAttr\x12@\n" +
+	"\bGetxattr\x12\x19.coordinator.XattrRequest\x1a\x19.coordinator.DataResponse\x12A\n" +
+	"\tListxattr\x12\x19.coordinator.XattrRequest\x1a\x19.coordinator.DataResponse\x12;\n" +
+	"\x04Open\x12\x18.coordinator.OpenRequest\x1a\x19.coordinator.OpenResponse\x12C\n" +
+	"\bReadlink\x12\x18.coordinator.PathRequest\x1a\x1d.coordinator.ReadlinkResponse\x12<\n" +
+	"\fLookupExists\x12\x18.coordinator.PathRequest\x1a\x12.coordinator.Empty\x12:\n" +
+	"\aReadDir\x12\x16.coordinator.FDRequest\x1a\x17.coordinator.DirentList\x12?\n" +
+	"\bReadFrom\x12\x18.coordinator.ReadRequest\x1a\x19.coordinator.DataResponse\x123\n" +
+	"\x05Close\x12\x16.coordinator.FDRequest\x1a\x12.coordinator.Empty\x12;\n" +
+	"\tSubscribe\x12\x12.coordinator.Empty\x1a\x18.coordinator.ChangeEvent0\x01\x12?\n" +
+	"\x06Create\x12\x1a.coordinator.CreateRequest\x1a\x19.coordinator.OpenResponse\x126\n" +
+	"\x05Mkdir\x12\x19.coordinator.MkdirRequest\x1a\x12.coordinator.Empty\x128\n" +
+	"\x06Remove\x12\x1a.coordinator.RemoveRequest\x1a\x12.coordinator.Empty\x128\n" +
+	"\x06Rename\x12\x1a.coordinator.RenameRequest\x1a\x12.coordinator.Empty\x12=\n" +
+	"\aSetattr\x12\x1b.coordinator.SetattrRequest\x1a\x15.coordinator.FileAttr\x12@\n" +
+	"\aWriteTo\x12\x19.coordinator.WriteRequest\x1a\x1a.coordinator.WriteResponseB+Z)github.com/riking/42fs/grpc/coordinatorpbb\x06proto3"
+
+var (
+	file_coordinator_proto_rawDescOnce sync.Once
+	file_coordinator_proto_rawDescData []byte
+)
+
+func file_coordinator_proto_rawDescGZIP() []byte {
+	file_coordinator_proto_rawDescOnce.Do(func() {
+		file_coordinator_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_coordinator_proto_rawDesc), len(file_coordinator_proto_rawDesc)))
+	})
+	return file_coordinator_proto_rawDescData
+}
+
+var file_coordinator_proto_msgTypes = make([]protoimpl.MessageInfo, 32)
+var file_coordinator_proto_goTypes = []any{
+	(*Empty)(nil),                 // 0: coordinator.Empty
+	(*RegisterRequest)(nil),       // 1: coordinator.RegisterRequest
+	(*RegisterResponse)(nil),      // 2: coordinator.RegisterResponse
+	(*UserDirRequest)(nil),        // 3: coordinator.UserDirRequest
+	(*ListUsersRequest)(nil),      // 4: coordinator.ListUsersRequest
+	(*UserList)(nil),              // 5: coordinator.UserList
+	(*LoginInfo)(nil),             // 6: coordinator.LoginInfo
+	(*FileAttr)(nil),              // 7: coordinator.FileAttr
+	(*Manifest)(nil),              // 8: coordinator.Manifest
+	(*ManifestEntry)(nil),         // 9: coordinator.ManifestEntry
+	(*SyncResponse)(nil),          // 10: coordinator.SyncResponse
+	(*ChunkList)(nil),             // 11: coordinator.ChunkList
+	(*PathRequest)(nil),           // 12: coordinator.PathRequest
+	(*AccessRequest)(nil),         // 13: coordinator.AccessRequest
+	(*XattrRequest)(nil),          // 14: coordinator.XattrRequest
+	(*DataResponse)(nil),          // 15: coordinator.DataResponse
+	(*OpenRequest)(nil),           // 16: coordinator.OpenRequest
+	(*OpenResponse)(nil),          // 17: coordinator.OpenResponse
+	(*ReadlinkResponse)(nil),      // 18: coordinator.ReadlinkResponse
+	(*FDRequest)(nil),             // 19: coordinator.FDRequest
+	(*Dirent)(nil),                // 20: coordinator.Dirent
+	(*ChangeEvent)(nil),           // 21: coordinator.ChangeEvent
+	(*DirentList)(nil),            // 22: coordinator.DirentList
+	(*ReadRequest)(nil),           // 23: coordinator.ReadRequest
+	(*CreateRequest)(nil),         // 24: coordinator.CreateRequest
+	(*MkdirRequest)(nil),          // 25: coordinator.MkdirRequest
+	(*RemoveRequest)(nil),         // 26: coordinator.RemoveRequest
+	(*RenameRequest)(nil),         // 27: coordinator.RenameRequest
+	(*SetattrRequest)(nil),        // 28: coordinator.SetattrRequest
+	(*WriteRequest)(nil),          // 29: coordinator.WriteRequest
+	(*WriteResponse)(nil),         // 30: coordinator.WriteResponse
+	(*FS42GrpcErr)(nil),           // 31: coordinator.FS42GrpcErr
+	(*timestamppb.Timestamp)(nil), // 32: google.protobuf.Timestamp
+}
+var file_coordinator_proto_depIdxs = []int32{
+	32, // 0: coordinator.RegisterRequest.Time:type_name -> google.protobuf.Timestamp
+	6,  // 1: coordinator.UserList.Users:type_name -> coordinator.LoginInfo
+	32, // 2: coordinator.FileAttr.Mtime:type_name -> google.protobuf.Timestamp
+	32, // 3: coordinator.FileAttr.Ctime:type_name -> google.protobuf.Timestamp
+	32, // 4: coordinator.FileAttr.BirthTime:type_name -> google.protobuf.Timestamp
+	32, // 5: coordinator.Manifest.Taken:type_name -> google.protobuf.Timestamp
+	9,  // 6: coordinator.Manifest.Entries:type_name -> coordinator.ManifestEntry
+	7,  // 7: coordinator.ManifestEntry.Attr:type_name -> coordinator.FileAttr
+	20, // 8: coordinator.DirentList.Entries:type_name -> coordinator.Dirent
+	32, // 9: coordinator.SetattrRequest.Atime:type_name -> google.protobuf.Timestamp
+	32, // 10: coordinator.SetattrRequest.Mtime:type_name -> google.protobuf.Timestamp
+	1,  // 11: coordinator.Coordinator.Register:input_type -> coordinator.RegisterRequest
+	0,  // 12: coordinator.Coordinator.Unregister:input_type -> coordinator.Empty
+	3,  // 13: coordinator.Coordinator.UserDirInfo:input_type -> coordinator.UserDirRequest
+	3,  // 14: coordinator.Coordinator.UserDirStat:input_type -> coordinator.UserDirRequest
+	4,  // 15: coordinator.Coordinator.ListUsers:input_type -> coordinator.ListUsersRequest
+	8,  // 16: coordinator.Coordinator.SyncManifest:input_type -> coordinator.Manifest
+	11, // 17: coordinator.Coordinator.PutChunks:input_type -> coordinator.ChunkList
+	13, // 18: coordinator.UserConnection.Access:input_type -> coordinator.AccessRequest
+	12, // 19: coordinator.UserConnection.Stat:input_type -> coordinator.PathRequest
+	14, // 20: coordinator.UserConnection.Getxattr:input_type -> coordinator.XattrRequest
+	14, // 21: coordinator.UserConnection.Listxattr:input_type -> coordinator.XattrRequest
+	16, // 22: coordinator.UserConnection.Open:input_type -> coordinator.OpenRequest
+	12, // 23: coordinator.UserConnection.Readlink:input_type -> coordinator.PathRequest
+	12, // 24: coordinator.UserConnection.LookupExists:input_type -> coordinator.PathRequest
+	19, // 25: coordinator.UserConnection.ReadDir:input_type -> coordinator.FDRequest
+	23, // 26: coordinator.UserConnection.ReadFrom:input_type -> coordinator.ReadRequest
+	19, // 27: coordinator.UserConnection.Close:input_type -> coordinator.FDRequest
+	0,  // 28: coordinator.UserConnection.Subscribe:input_type -> coordinator.Empty
+	24, // 29: coordinator.UserConnection.Create:input_type -> coordinator.CreateRequest
+	25, // 30: coordinator.UserConnection.Mkdir:input_type -> coordinator.MkdirRequest
+	26, // 31: coordinator.UserConnection.Remove:input_type -> coordinator.RemoveRequest
+	27, // 32: coordinator.UserConnection.Rename:input_type -> coordinator.RenameRequest
+	28, // 33: coordinator.UserConnection.Setattr:input_type -> coordinator.SetattrRequest
+	29, // 34: coordinator.UserConnection.WriteTo:input_type -> coordinator.WriteRequest
+	2,  // 35: coordinator.Coordinator.Register:output_type -> coordinator.RegisterResponse
+	0,  // 36: coordinator.Coordinator.Unregister:output_type -> coordinator.Empty
+	6,  // 37: coordinator.Coordinator.UserDirInfo:output_type -> coordinator.LoginInfo
+	7,  // 38: coordinator.Coordinator.UserDirStat:output_type -> coordinator.FileAttr
+	5,  // 39: coordinator.Coordinator.ListUsers:output_type -> coordinator.UserList
+	10, // 40: coordinator.Coordinator.SyncManifest:output_type -> coordinator.SyncResponse
+	0,  // 41: coordinator.Coordinator.PutChunks:output_type -> coordinator.Empty
+	0,  // 42: coordinator.UserConnection.Access:output_type -> coordinator.Empty
+	7,  // 43: coordinator.UserConnection.Stat:output_type -> coordinator.FileAttr
+	15, // 44: coordinator.UserConnection.Getxattr:output_type -> coordinator.DataResponse
+	15, // 45: coordinator.UserConnection.Listxattr:output_type -> coordinator.DataResponse
+	17, // 46: coordinator.UserConnection.Open:output_type -> coordinator.OpenResponse
+	18, // 47: coordinator.UserConnection.Readlink:output_type -> coordinator.ReadlinkResponse
+	0,  // 48: coordinator.UserConnection.LookupExists:output_type -> coordinator.Empty
+	22, // 49: coordinator.UserConnection.ReadDir:output_type -> coordinator.DirentList
+	15, // 50: coordinator.UserConnection.ReadFrom:output_type -> coordinator.DataResponse
+	0,  // 51: coordinator.UserConnection.Close:output_type -> coordinator.Empty
+	21, // 52: coordinator.UserConnection.Subscribe:output_type -> coordinator.ChangeEvent
+	17, // 53: coordinator.UserConnection.Create:output_type -> coordinator.OpenResponse
+	0,  // 54: coordinator.UserConnection.Mkdir:output_type -> coordinator.Empty
+	0,  // 55: coordinator.UserConnection.Remove:output_type -> coordinator.Empty
+	0,  // 56: coordinator.UserConnection.Rename:output_type -> coordinator.Empty
+	7,  // 57: coordinator.UserConnection.Setattr:output_type -> coordinator.FileAttr
+	30, // 58: coordinator.UserConnection.WriteTo:output_type -> coordinator.WriteResponse
+	35, // [35:59] is the sub-list for method output_type
+	11, // [11:35] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
+}
+
+func init() { file_coordinator_proto_init() }
+func file_coordinator_proto_init() {
+	if File_coordinator_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_coordinator_proto_rawDesc), len(file_coordinator_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   32,
+			NumExtensions: 0,
+			NumServices:   2,
+		},
+		GoTypes:           file_coordinator_proto_goTypes,
+		DependencyIndexes: file_coordinator_proto_depIdxs,
+		MessageInfos:      file_coordinator_proto_msgTypes,
+	}.Build()
+	File_coordinator_proto = out.File
+	file_coordinator_proto_goTypes = nil
+	file_coordinator_proto_depIdxs = nil
+}
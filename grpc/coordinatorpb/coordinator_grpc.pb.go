@@ -0,0 +1,1101 @@
+// Wire schema for 42fs.
+//
+// The Go code in coordinatorpb is generated from this file; run go generate
+// in this directory after changing it. The rest of the package converts
+// between those messages and the types fscore and coordserver use.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v3.21.12
+// source: coordinator.proto
+
+package coordinatorpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Coordinator_Register_FullMethodName     = "/coordinator.Coordinator/Register"
+	Coordinator_Unregister_FullMethodName   = "/coordinator.Coordinator/Unregister"
+	Coordinator_UserDirInfo_FullMethodName  = "/coordinator.Coordinator/UserDirInfo"
+	Coordinator_UserDirStat_FullMethodName  = "/coordinator.Coordinator/UserDirStat"
+	Coordinator_ListUsers_FullMethodName    = "/coordinator.Coordinator/ListUsers"
+	Coordinator_SyncManifest_FullMethodName = "/coordinator.Coordinator/SyncManifest"
+	Coordinator_PutChunks_FullMethodName    = "/coordinator.Coordinator/PutChunks"
+)
+
+// CoordinatorClient is the client API for Coordinator service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Coordinator is served by the coordination server. Daemons register
+// themselves and look up each other's addresses.
+type CoordinatorClient interface {
+	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error)
+	// Unregister marks the caller offline until it registers again.
+	Unregister(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error)
+	UserDirInfo(ctx context.Context, in *UserDirRequest, opts ...grpc.CallOption) (*LoginInfo, error)
+	UserDirStat(ctx context.Context, in *UserDirRequest, opts ...grpc.CallOption) (*FileAttr, error)
+	// ListUsers returns every login with a public folder, sorted by login.
+	ListUsers(ctx context.Context, in *ListUsersRequest, opts ...grpc.CallOption) (*UserList, error)
+	// SyncManifest replaces the caller's snapshot, which the coordinator
+	// serves as UserConnection under /<login> while the caller is offline.
+	// Until every chunk it refers to has been sent with PutChunks, the
+	// manifest is not stored and the missing chunks are returned instead.
+	SyncManifest(ctx context.Context, in *Manifest, opts ...grpc.CallOption) (*SyncResponse, error)
+	PutChunks(ctx context.Context, in *ChunkList, opts ...grpc.CallOption) (*Empty, error)
+}
+
+type coordinatorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCoordinatorClient(cc grpc.ClientConnInterface) CoordinatorClient {
+	return &coordinatorClient{cc}
+}
+
+func (c *coordinatorClient) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RegisterResponse)
+	err := c.cc.Invoke(ctx, Coordinator_Register_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorClient) Unregister(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Coordinator_Unregister_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorClient) UserDirInfo(ctx context.Context, in *UserDirRequest, opts ...grpc.CallOption) (*LoginInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LoginInfo)
+	err := c.cc.Invoke(ctx, Coordinator_UserDirInfo_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorClient) UserDirStat(ctx context.Context, in *UserDirRequest, opts ...grpc.CallOption) (*FileAttr, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FileAttr)
+	err := c.cc.Invoke(ctx, Coordinator_UserDirStat_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorClient) ListUsers(ctx context.Context, in *ListUsersRequest, opts ...grpc.CallOption) (*UserList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UserList)
+	err := c.cc.Invoke(ctx, Coordinator_ListUsers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorClient) SyncManifest(ctx context.Context, in *Manifest, opts ...grpc.CallOption) (*SyncResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SyncResponse)
+	err := c.cc.Invoke(ctx, Coordinator_SyncManifest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coordinatorClient) PutChunks(ctx context.Context, in *ChunkList, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Coordinator_PutChunks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CoordinatorServer is the server API for Coordinator service.
+// All implementations must embed UnimplementedCoordinatorServer
+// for forward compatibility.
+//
+// Coordinator is served by the coordination server. Daemons register
+// themselves and look up each other's addresses.
+type CoordinatorServer interface {
+	Register(context.Context, *RegisterRequest) (*RegisterResponse, error)
+	// Unregister marks the caller offline until it registers again.
+	Unregister(context.Context, *Empty) (*Empty, error)
+	UserDirInfo(context.Context, *UserDirRequest) (*LoginInfo, error)
+	UserDirStat(context.Context, *UserDirRequest) (*FileAttr, error)
+	// ListUsers returns every login with a public folder, sorted by login.
+	ListUsers(context.Context, *ListUsersRequest) (*UserList, error)
+	// SyncManifest replaces the caller's snapshot, which the coordinator
+	// serves as UserConnection under /<login> while the caller is offline.
+	// Until every chunk it refers to has been sent with PutChunks, the
+	// manifest is not stored and the missing chunks are returned instead.
+	SyncManifest(context.Context, *Manifest) (*SyncResponse, error)
+	PutChunks(context.Context, *ChunkList) (*Empty, error)
+	mustEmbedUnimplementedCoordinatorServer()
+}
+
+// UnimplementedCoordinatorServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCoordinatorServer struct{}
+
+func (UnimplementedCoordinatorServer) Register(context.Context, *RegisterRequest) (*RegisterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedCoordinatorServer) Unregister(context.Context, *Empty) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Unregister not implemented")
+}
+func (UnimplementedCoordinatorServer) UserDirInfo(context.Context, *UserDirRequest) (*LoginInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UserDirInfo not implemented")
+}
+func (UnimplementedCoordinatorServer) UserDirStat(context.Context, *UserDirRequest) (*FileAttr, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UserDirStat not implemented")
+}
+func (UnimplementedCoordinatorServer) ListUsers(context.Context, *ListUsersRequest) (*UserList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListUsers not implemented")
+}
+func (UnimplementedCoordinatorServer) SyncManifest(context.Context, *Manifest) (*SyncResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SyncManifest not implemented")
+}
+func (UnimplementedCoordinatorServer) PutChunks(context.Context, *ChunkList) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PutChunks not implemented")
+}
+func (UnimplementedCoordinatorServer) mustEmbedUnimplementedCoordinatorServer() {}
+func (UnimplementedCoordinatorServer) testEmbeddedByValue()                     {}
+
+// UnsafeCoordinatorServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CoordinatorServer will
+// result in compilation errors.
+type UnsafeCoordinatorServer interface {
+	mustEmbedUnimplementedCoordinatorServer()
+}
+
+func RegisterCoordinatorServer(s grpc.ServiceRegistrar, srv CoordinatorServer) {
+	// If the following call pancis, it indicates UnimplementedCoordinatorServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Coordinator_ServiceDesc, srv)
+}
+
+func _Coordinator_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Coordinator_Register_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServer).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Coordinator_Unregister_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServer).Unregister(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Coordinator_Unregister_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServer).Unregister(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Coordinator_UserDirInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserDirRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServer).UserDirInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Coordinator_UserDirInfo_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServer).UserDirInfo(ctx, req.(*UserDirRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Coordinator_UserDirStat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserDirRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServer).UserDirStat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Coordinator_UserDirStat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServer).UserDirStat(ctx, req.(*UserDirRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Coordinator_ListUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListUsersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServer).ListUsers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Coordinator_ListUsers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServer).ListUsers(ctx, req.(*ListUsersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Coordinator_SyncManifest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Manifest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServer).SyncManifest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Coordinator_SyncManifest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServer).SyncManifest(ctx, req.(*Manifest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Coordinator_PutChunks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChunkList)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoordinatorServer).PutChunks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Coordinator_PutChunks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoordinatorServer).PutChunks(ctx, req.(*ChunkList))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Coordinator_ServiceDesc is the grpc.ServiceDesc for Coordinator service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Coordinator_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "coordinator.Coordinator",
+	HandlerType: (*CoordinatorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Register",
+			Handler:    _Coordinator_Register_Handler,
+		},
+		{
+			MethodName: "Unregister",
+			Handler:    _Coordinator_Unregister_Handler,
+		},
+		{
+			MethodName: "UserDirInfo",
+			Handler:    _Coordinator_UserDirInfo_Handler,
+		},
+		{
+			MethodName: "UserDirStat",
+			Handler:    _Coordinator_UserDirStat_Handler,
+		},
+		{
+			MethodName: "ListUsers",
+			Handler:    _Coordinator_ListUsers_Handler,
+		},
+		{
+			MethodName: "SyncManifest",
+			Handler:    _Coordinator_SyncManifest_Handler,
+		},
+		{
+			MethodName: "PutChunks",
+			Handler:    _Coordinator_PutChunks_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "coordinator.proto",
+}
+
+const (
+	UserConnection_Access_FullMethodName       = "/coordinator.UserConnection/Access"
+	UserConnection_Stat_FullMethodName         = "/coordinator.UserConnection/Stat"
+	UserConnection_Getxattr_FullMethodName     = "/coordinator.UserConnection/Getxattr"
+	UserConnection_Listxattr_FullMethodName    = "/coordinator.UserConnection/Listxattr"
+	UserConnection_Open_FullMethodName         = "/coordinator.UserConnection/Open"
+	UserConnection_Readlink_FullMethodName     = "/coordinator.UserConnection/Readlink"
+	UserConnection_LookupExists_FullMethodName = "/coordinator.UserConnection/LookupExists"
+	UserConnection_ReadDir_FullMethodName      = "/coordinator.UserConnection/ReadDir"
+	UserConnection_ReadFrom_FullMethodName     = "/coordinator.UserConnection/ReadFrom"
+	UserConnection_Close_FullMethodName        = "/coordinator.UserConnection/Close"
+	UserConnection_Subscribe_FullMethodName    = "/coordinator.UserConnection/Subscribe"
+	UserConnection_Create_FullMethodName       = "/coordinator.UserConnection/Create"
+	UserConnection_Mkdir_FullMethodName        = "/coordinator.UserConnection/Mkdir"
+	UserConnection_Remove_FullMethodName       = "/coordinator.UserConnection/Remove"
+	UserConnection_Rename_FullMethodName       = "/coordinator.UserConnection/Rename"
+	UserConnection_Setattr_FullMethodName      = "/coordinator.UserConnection/Setattr"
+	UserConnection_WriteTo_FullMethodName      = "/coordinator.UserConnection/WriteTo"
+)
+
+// UserConnectionClient is the client API for UserConnection service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// UserConnection is served by every FUSE daemon and gives peers access to
+// the owner's public directory. Every call must carry the caller's token.
+type UserConnectionClient interface {
+	Access(ctx context.Context, in *AccessRequest, opts ...grpc.CallOption) (*Empty, error)
+	Stat(ctx context.Context, in *PathRequest, opts ...grpc.CallOption) (*FileAttr, error)
+	Getxattr(ctx context.Context, in *XattrRequest, opts ...grpc.CallOption) (*DataResponse, error)
+	Listxattr(ctx context.Context, in *XattrRequest, opts ...grpc.CallOption) (*DataResponse, error)
+	Open(ctx context.Context, in *OpenRequest, opts ...grpc.CallOption) (*OpenResponse, error)
+	Readlink(ctx context.Context, in *PathRequest, opts ...grpc.CallOption) (*ReadlinkResponse, error)
+	LookupExists(ctx context.Context, in *PathRequest, opts ...grpc.CallOption) (*Empty, error)
+	ReadDir(ctx context.Context, in *FDRequest, opts ...grpc.CallOption) (*DirentList, error)
+	ReadFrom(ctx context.Context, in *ReadRequest, opts ...grpc.CallOption) (*DataResponse, error)
+	Close(ctx context.Context, in *FDRequest, opts ...grpc.CallOption) (*Empty, error)
+	// Subscribe streams changes to the directory until the caller hangs
+	// up. The coordinator doesn't implement it for snapshots.
+	Subscribe(ctx context.Context, in *Empty, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ChangeEvent], error)
+	// Write operations fail with EROFS outside of the directory the owner
+	// opened for writing, and always on snapshots.
+	Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*OpenResponse, error)
+	Mkdir(ctx context.Context, in *MkdirRequest, opts ...grpc.CallOption) (*Empty, error)
+	Remove(ctx context.Context, in *RemoveRequest, opts ...grpc.CallOption) (*Empty, error)
+	Rename(ctx context.Context, in *RenameRequest, opts ...grpc.CallOption) (*Empty, error)
+	Setattr(ctx context.Context, in *SetattrRequest, opts ...grpc.CallOption) (*FileAttr, error)
+	WriteTo(ctx context.Context, in *WriteRequest, opts ...grpc.CallOption) (*WriteResponse, error)
+}
+
+type userConnectionClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewUserConnectionClient(cc grpc.ClientConnInterface) UserConnectionClient {
+	return &userConnectionClient{cc}
+}
+
+func (c *userConnectionClient) Access(ctx context.Context, in *AccessRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, UserConnection_Access_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userConnectionClient) Stat(ctx context.Context, in *PathRequest, opts ...grpc.CallOption) (*FileAttr, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FileAttr)
+	err := c.cc.Invoke(ctx, UserConnection_Stat_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userConnectionClient) Getxattr(ctx context.Context, in *XattrRequest, opts ...grpc.CallOption) (*DataResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DataResponse)
+	err := c.cc.Invoke(ctx, UserConnection_Getxattr_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userConnectionClient) Listxattr(ctx context.Context, in *XattrRequest, opts ...grpc.CallOption) (*DataResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DataResponse)
+	err := c.cc.Invoke(ctx, UserConnection_Listxattr_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userConnectionClient) Open(ctx context.Context, in *OpenRequest, opts ...grpc.CallOption) (*OpenResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OpenResponse)
+	err := c.cc.Invoke(ctx, UserConnection_Open_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userConnectionClient) Readlink(ctx context.Context, in *PathRequest, opts ...grpc.CallOption) (*ReadlinkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReadlinkResponse)
+	err := c.cc.Invoke(ctx, UserConnection_Readlink_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userConnectionClient) LookupExists(ctx context.Context, in *PathRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, UserConnection_LookupExists_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userConnectionClient) ReadDir(ctx context.Context, in *FDRequest, opts ...grpc.CallOption) (*DirentList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DirentList)
+	err := c.cc.Invoke(ctx, UserConnection_ReadDir_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userConnectionClient) ReadFrom(ctx context.Context, in *ReadRequest, opts ...grpc.CallOption) (*DataResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DataResponse)
+	err := c.cc.Invoke(ctx, UserConnection_ReadFrom_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userConnectionClient) Close(ctx context.Context, in *FDRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, UserConnection_Close_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userConnectionClient) Subscribe(ctx context.Context, in *Empty, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ChangeEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &UserConnection_ServiceDesc.Streams[0], UserConnection_Subscribe_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[Empty, ChangeEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type UserConnection_SubscribeClient = grpc.ServerStreamingClient[ChangeEvent]
+
+func (c *userConnectionClient) Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*OpenResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OpenResponse)
+	err := c.cc.Invoke(ctx, UserConnection_Create_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userConnectionClient) Mkdir(ctx context.Context, in *MkdirRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, UserConnection_Mkdir_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userConnectionClient) Remove(ctx context.Context, in *RemoveRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, UserConnection_Remove_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userConnectionClient) Rename(ctx context.Context, in *RenameRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, UserConnection_Rename_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userConnectionClient) Setattr(ctx context.Context, in *SetattrRequest, opts ...grpc.CallOption) (*FileAttr, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FileAttr)
+	err := c.cc.Invoke(ctx, UserConnection_Setattr_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userConnectionClient) WriteTo(ctx context.Context, in *WriteRequest, opts ...grpc.CallOption) (*WriteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WriteResponse)
+	err := c.cc.Invoke(ctx, UserConnection_WriteTo_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UserConnectionServer is the server API for UserConnection service.
+// All implementations must embed UnimplementedUserConnectionServer
+// for forward compatibility.
+//
+// UserConnection is served by every FUSE daemon and gives peers access to
+// the owner's public directory. Every call must carry the caller's token.
+type UserConnectionServer interface {
+	Access(context.Context, *AccessRequest) (*Empty, error)
+	Stat(context.Context, *PathRequest) (*FileAttr, error)
+	Getxattr(context.Context, *XattrRequest) (*DataResponse, error)
+	Listxattr(context.Context, *XattrRequest) (*DataResponse, error)
+	Open(context.Context, *OpenRequest) (*OpenResponse, error)
+	Readlink(context.Context, *PathRequest) (*ReadlinkResponse, error)
+	LookupExists(context.Context, *PathRequest) (*Empty, error)
+	ReadDir(context.Context, *FDRequest) (*DirentList, error)
+	ReadFrom(context.Context, *ReadRequest) (*DataResponse, error)
+	Close(context.Context, *FDRequest) (*Empty, error)
+	// Subscribe streams changes to the directory until the caller hangs
+	// up. The coordinator doesn't implement it for snapshots.
+	Subscribe(*Empty, grpc.ServerStreamingServer[ChangeEvent]) error
+	// Write operations fail with EROFS outside of the directory the owner
+	// opened for writing, and always on snapshots.
+	Create(context.Context, *CreateRequest) (*OpenResponse, error)
+	Mkdir(context.Context, *MkdirRequest) (*Empty, error)
+	Remove(context.Context, *RemoveRequest) (*Empty, error)
+	Rename(context.Context, *RenameRequest) (*Empty, error)
+	Setattr(context.Context, *SetattrRequest) (*FileAttr, error)
+	WriteTo(context.Context, *WriteRequest) (*WriteResponse, error)
+	mustEmbedUnimplementedUserConnectionServer()
+}
+
+// UnimplementedUserConnectionServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedUserConnectionServer struct{}
+
+func (UnimplementedUserConnectionServer) Access(context.Context, *AccessRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Access not implemented")
+}
+func (UnimplementedUserConnectionServer) Stat(context.Context, *PathRequest) (*FileAttr, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stat not implemented")
+}
+func (UnimplementedUserConnectionServer) Getxattr(context.Context, *XattrRequest) (*DataResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Getxattr not implemented")
+}
+func (UnimplementedUserConnectionServer) Listxattr(context.Context, *XattrRequest) (*DataResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Listxattr not implemented")
+}
+func (UnimplementedUserConnectionServer) Open(context.Context, *OpenRequest) (*OpenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Open not implemented")
+}
+func (UnimplementedUserConnectionServer) Readlink(context.Context, *PathRequest) (*ReadlinkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Readlink not implemented")
+}
+func (UnimplementedUserConnectionServer) LookupExists(context.Context, *PathRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LookupExists not implemented")
+}
+func (UnimplementedUserConnectionServer) ReadDir(context.Context, *FDRequest) (*DirentList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReadDir not implemented")
+}
+func (UnimplementedUserConnectionServer) ReadFrom(context.Context, *ReadRequest) (*DataResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReadFrom not implemented")
+}
+func (UnimplementedUserConnectionServer) Close(context.Context, *FDRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Close not implemented")
+}
+func (UnimplementedUserConnectionServer) Subscribe(*Empty, grpc.ServerStreamingServer[ChangeEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedUserConnectionServer) Create(context.Context, *CreateRequest) (*OpenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
+}
+func (UnimplementedUserConnectionServer) Mkdir(context.Context, *MkdirRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Mkdir not implemented")
+}
+func (UnimplementedUserConnectionServer) Remove(context.Context, *RemoveRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Remove not implemented")
+}
+func (UnimplementedUserConnectionServer) Rename(context.Context, *RenameRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rename not implemented")
+}
+func (UnimplementedUserConnectionServer) Setattr(context.Context, *SetattrRequest) (*FileAttr, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Setattr not implemented")
+}
+func (UnimplementedUserConnectionServer) WriteTo(context.Context, *WriteRequest) (*WriteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WriteTo not implemented")
+}
+func (UnimplementedUserConnectionServer) mustEmbedUnimplementedUserConnectionServer() {}
+func (UnimplementedUserConnectionServer) testEmbeddedByValue()                        {}
+
+// UnsafeUserConnectionServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to UserConnectionServer will
+// result in compilation errors.
+type UnsafeUserConnectionServer interface {
+	mustEmbedUnimplementedUserConnectionServer()
+}
+
+func RegisterUserConnectionServer(s grpc.ServiceRegistrar, srv UserConnectionServer) {
+	// If the following call pancis, it indicates UnimplementedUserConnectionServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&UserConnection_ServiceDesc, srv)
+}
+
+func _UserConnection_Access_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AccessRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserConnectionServer).Access(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserConnection_Access_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserConnectionServer).Access(ctx, req.(*AccessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserConnection_Stat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PathRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserConnectionServer).Stat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserConnection_Stat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserConnectionServer).Stat(ctx, req.(*PathRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserConnection_Getxattr_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(XattrRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserConnectionServer).Getxattr(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserConnection_Getxattr_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserConnectionServer).Getxattr(ctx, req.(*XattrRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserConnection_Listxattr_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(XattrRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserConnectionServer).Listxattr(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserConnection_Listxattr_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserConnectionServer).Listxattr(ctx, req.(*XattrRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserConnection_Open_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OpenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserConnectionServer).Open(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserConnection_Open_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserConnectionServer).Open(ctx, req.(*OpenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserConnection_Readlink_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PathRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserConnectionServer).Readlink(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserConnection_Readlink_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserConnectionServer).Readlink(ctx, req.(*PathRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserConnection_LookupExists_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PathRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserConnectionServer).LookupExists(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserConnection_LookupExists_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserConnectionServer).LookupExists(ctx, req.(*PathRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserConnection_ReadDir_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserConnectionServer).ReadDir(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserConnection_ReadDir_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserConnectionServer).ReadDir(ctx, req.(*FDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserConnection_ReadFrom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserConnectionServer).ReadFrom(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserConnection_ReadFrom_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserConnectionServer).ReadFrom(ctx, req.(*ReadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserConnection_Close_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserConnectionServer).Close(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserConnection_Close_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserConnectionServer).Close(ctx, req.(*FDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserConnection_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(UserConnectionServer).Subscribe(m, &grpc.GenericServerStream[Empty, ChangeEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type UserConnection_SubscribeServer = grpc.ServerStreamingServer[ChangeEvent]
+
+func _UserConnection_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserConnectionServer).Create(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserConnection_Create_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserConnectionServer).Create(ctx, req.(*CreateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserConnection_Mkdir_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MkdirRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserConnectionServer).Mkdir(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserConnection_Mkdir_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserConnectionServer).Mkdir(ctx, req.(*MkdirRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserConnection_Remove_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserConnectionServer).Remove(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserConnection_Remove_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserConnectionServer).Remove(ctx, req.(*RemoveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserConnection_Rename_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserConnectionServer).Rename(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserConnection_Rename_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserConnectionServer).Rename(ctx, req.(*RenameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserConnection_Setattr_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetattrRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserConnectionServer).Setattr(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserConnection_Setattr_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserConnectionServer).Setattr(ctx, req.(*SetattrRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserConnection_WriteTo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WriteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserConnectionServer).WriteTo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserConnection_WriteTo_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserConnectionServer).WriteTo(ctx, req.(*WriteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// UserConnection_ServiceDesc is the grpc.ServiceDesc for UserConnection service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var UserConnection_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "coordinator.UserConnection",
+	HandlerType: (*UserConnectionServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Access",
+			Handler:    _UserConnection_Access_Handler,
+		},
+		{
+			MethodName: "Stat",
+			Handler:    _UserConnection_Stat_Handler,
+		},
+		{
+			MethodName: "Getxattr",
+			Handler:    _UserConnection_Getxattr_Handler,
+		},
+		{
+			MethodName: "Listxattr",
+			Handler:    _UserConnection_Listxattr_Handler,
+		},
+		{
+			MethodName: "Open",
+			Handler:    _UserConnection_Open_Handler,
+		},
+		{
+			MethodName: "Readlink",
+			Handler:    _UserConnection_Readlink_Handler,
+		},
+		{
+			MethodName: "LookupExists",
+			Handler:    _UserConnection_LookupExists_Handler,
+		},
+		{
+			MethodName: "ReadDir",
+			Handler:    _UserConnection_ReadDir_Handler,
+		},
+		{
+			MethodName: "ReadFrom",
+			Handler:    _UserConnection_ReadFrom_Handler,
+		},
+		{
+			MethodName: "Close",
+			Handler:    _UserConnection_Close_Handler,
+		},
+		{
+			MethodName: "Create",
+			Handler:    _UserConnection_Create_Handler,
+		},
+		{
+			MethodName: "Mkdir",
+			Handler:    _UserConnection_Mkdir_Handler,
+		},
+		{
+			MethodName: "Remove",
+			Handler:    _UserConnection_Remove_Handler,
+		},
+		{
+			MethodName: "Rename",
+			Handler:    _UserConnection_Rename_Handler,
+		},
+		{
+			MethodName: "Setattr",
+			Handler:    _UserConnection_Setattr_Handler,
+		},
+		{
+			MethodName: "WriteTo",
+			Handler:    _UserConnection_WriteTo_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _UserConnection_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "coordinator.proto",
+}
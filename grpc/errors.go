@@ -4,6 +4,9 @@ import (
 	"syscall"
 
 	"bazil.org/fuse"
+	pb "github.com/riking/42fs/grpc/coordinatorpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type FS42GrpcErr struct {
@@ -21,5 +24,57 @@ func (e FS42GrpcErr) Error() string {
 }
 
 func (e FS42GrpcErr) Errno() fuse.Errno {
+	if e.ErrNum == 0 {
+		// message-only errors still have to fail the request
+		return fuse.EIO
+	}
 	return fuse.Errno(syscall.Errno(e.ErrNum))
 }
+
+// toGrpcErr packs an error returned by a service implementation into a gRPC
+// status, preserving the errno so the calling FUSE daemon can return it to
+// the kernel unchanged.
+func toGrpcErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	var e FS42GrpcErr
+	switch v := err.(type) {
+	case FS42GrpcErr:
+		e = v
+	case syscall.Errno:
+		e.ErrNum = int32(v)
+	case fuse.ErrorNumber:
+		e.ErrNum = int32(v.Errno())
+	default:
+		e.Msg = err.Error()
+	}
+	st, dErr := status.New(codes.Unknown, e.Error()).WithDetails(&pb.FS42GrpcErr{ErrNum: e.ErrNum, Msg: e.Msg})
+	if dErr != nil {
+		return status.Errorf(codes.Internal, "%s", err.Error())
+	}
+	return st.Err()
+}
+
+// fromGrpcErr is the inverse of toGrpcErr. Calls rejected for lack of
+// credentials are reported as EACCES, unreachable peers as EHOSTDOWN and
+// other transport failures as EIO.
+func fromGrpcErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	st := status.Convert(err)
+	switch st.Code() {
+	case codes.Unknown:
+		for _, d := range st.Details() {
+			if d, ok := d.(*pb.FS42GrpcErr); ok {
+				return FS42GrpcErr{ErrNum: d.ErrNum, Msg: d.Msg}
+			}
+		}
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return FS42GrpcErr{ErrNum: int32(syscall.EACCES), Msg: err.Error()}
+	case codes.Unavailable:
+		return FS42GrpcErr{ErrNum: int32(syscall.EHOSTDOWN), Msg: err.Error()}
+	}
+	return FS42GrpcErr{ErrNum: int32(syscall.EIO), Msg: err.Error()}
+}
@@ -0,0 +1,55 @@
+package coordinator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// SnapshotTimeXattr is set on every file served from a snapshot. Its value
+// is the RFC 3339 time the owner's daemon took the snapshot.
+const SnapshotTimeXattr = "user.42fs.snapshot_time"
+
+// ChunkSize is the size of the pieces file contents are split into when the
+// coordinator stores them. Only the last chunk of a file may be shorter.
+const ChunkSize = 64 << 10
+
+// ChunkHash names a chunk by its contents.
+func ChunkHash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Manifest describes a snapshot of a user's public directory, which the
+// coordinator serves while the owner's daemon is offline. File contents are
+// referred to by the hashes of their chunks, which are uploaded separately
+// and shared between snapshots.
+type Manifest struct {
+	Login   string
+	Taken   time.Time
+	Entries []ManifestEntry
+}
+
+type ManifestEntry struct {
+	// Path is slash-separated and rooted at the public directory, which is
+	// "/" itself.
+	Path string
+	Attr FileAttr
+	// Target is the contents of a symlink.
+	Target string
+	// Chunks lists the ChunkHash of every ChunkSize piece of a regular
+	// file, in order.
+	Chunks []string
+	// Omitted is set for files left out to keep the snapshot small.
+	Omitted bool
+}
+
+type SyncResponse struct {
+	// Missing lists the chunks the coordinator needs before it can accept
+	// the manifest. The manifest was stored if it is empty.
+	Missing []string
+}
+
+type ChunkList struct {
+	Chunks [][]byte
+}
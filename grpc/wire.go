@@ -0,0 +1,213 @@
+package coordinator
+
+//go:generate protoc --go_out=coordinatorpb --go_opt=paths=source_relative --go-grpc_out=coordinatorpb --go-grpc_opt=paths=source_relative coordinator.proto
+
+import (
+	"os"
+	"time"
+
+	"bazil.org/fuse"
+	pb "github.com/riking/42fs/grpc/coordinatorpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// The functions below convert between the messages generated from
+// coordinator.proto and the types the rest of 42fs works with. The ones
+// reading messages use the generated getters, which treat a missing message
+// as an empty one.
+
+// timeToPB sends the zero time as an unset field.
+func timeToPB(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func timeFromPB(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+func fileAttrToPB(a *FileAttr) *pb.FileAttr {
+	return &pb.FileAttr{
+		INode:     a.INode,
+		Size:      a.Size,
+		Blocks:    a.Blocks,
+		Mtime:     timeToPB(a.Mtime),
+		Ctime:     timeToPB(a.Ctime),
+		BirthTime: timeToPB(a.BirthTime),
+		Nlink:     a.Nlink,
+		Uid:       a.Uid,
+		Gid:       a.Gid,
+		BlockSize: a.BlockSize,
+		Mode:      uint32(a.Mode),
+		Dev:       a.Dev,
+	}
+}
+
+func fileAttrFromPB(a *pb.FileAttr) *FileAttr {
+	return &FileAttr{
+		INode:     a.GetINode(),
+		Size:      a.GetSize(),
+		Blocks:    a.GetBlocks(),
+		Mtime:     timeFromPB(a.GetMtime()),
+		Ctime:     timeFromPB(a.GetCtime()),
+		BirthTime: timeFromPB(a.GetBirthTime()),
+		Nlink:     a.GetNlink(),
+		Uid:       a.GetUid(),
+		Gid:       a.GetGid(),
+		BlockSize: a.GetBlockSize(),
+		Mode:      os.FileMode(a.GetMode()),
+		Dev:       a.GetDev(),
+	}
+}
+
+func loginInfoToPB(info *LoginInfo) *pb.LoginInfo {
+	return &pb.LoginInfo{
+		Login:     info.Login,
+		Exists:    info.Exists,
+		WasOnline: info.WasOnline,
+		Addr:      info.Addr,
+		INode:     info.INode,
+		Offline:   info.Offline,
+	}
+}
+
+func loginInfoFromPB(info *pb.LoginInfo) LoginInfo {
+	return LoginInfo{
+		Login:     info.GetLogin(),
+		Exists:    info.GetExists(),
+		WasOnline: info.GetWasOnline(),
+		Addr:      info.GetAddr(),
+		INode:     info.GetINode(),
+		Offline:   info.GetOffline(),
+	}
+}
+
+func registerRequestToPB(req *RegisterRequest) *pb.RegisterRequest {
+	return &pb.RegisterRequest{
+		Login:     req.Login,
+		Addr:      req.Addr,
+		RootINode: req.RootINode,
+		PublicKey: req.PublicKey,
+		Time:      timeToPB(req.Time),
+		Signature: req.Signature,
+		CSR:       req.CSR,
+	}
+}
+
+func registerRequestFromPB(req *pb.RegisterRequest) *RegisterRequest {
+	return &RegisterRequest{
+		Login:     req.GetLogin(),
+		Addr:      req.GetAddr(),
+		RootINode: req.GetRootINode(),
+		PublicKey: req.GetPublicKey(),
+		Time:      timeFromPB(req.GetTime()),
+		Signature: req.GetSignature(),
+		CSR:       req.GetCSR(),
+	}
+}
+
+func manifestToPB(m *Manifest) *pb.Manifest {
+	out := &pb.Manifest{
+		Login:   m.Login,
+		Taken:   timeToPB(m.Taken),
+		Entries: make([]*pb.ManifestEntry, len(m.Entries)),
+	}
+	for i := range m.Entries {
+		e := &m.Entries[i]
+		out.Entries[i] = &pb.ManifestEntry{
+			Path:    e.Path,
+			Attr:    fileAttrToPB(&e.Attr),
+			Target:  e.Target,
+			Chunks:  e.Chunks,
+			Omitted: e.Omitted,
+		}
+	}
+	return out
+}
+
+func manifestFromPB(m *pb.Manifest) *Manifest {
+	out := &Manifest{
+		Login:   m.GetLogin(),
+		Taken:   timeFromPB(m.GetTaken()),
+		Entries: make([]ManifestEntry, len(m.GetEntries())),
+	}
+	for i, e := range m.GetEntries() {
+		out.Entries[i] = ManifestEntry{
+			Path:    e.GetPath(),
+			Attr:    *fileAttrFromPB(e.GetAttr()),
+			Target:  e.GetTarget(),
+			Chunks:  e.GetChunks(),
+			Omitted: e.GetOmitted(),
+		}
+	}
+	return out
+}
+
+func direntsToPB(ents []Dirent) *pb.DirentList {
+	out := &pb.DirentList{Entries: make([]*pb.Dirent, len(ents))}
+	for i, e := range ents {
+		out.Entries[i] = &pb.Dirent{Inode: e.Inode, Type: e.Type, Name: e.Name, Dev: e.Dev}
+	}
+	return out
+}
+
+func direntsFromPB(l *pb.DirentList) []Dirent {
+	out := make([]Dirent, len(l.GetEntries()))
+	for i, e := range l.GetEntries() {
+		out[i] = Dirent{Inode: e.GetInode(), Type: e.GetType(), Name: e.GetName(), Dev: e.GetDev()}
+	}
+	return out
+}
+
+func readRequestToPB(req *ReadRequest) *pb.ReadRequest {
+	return &pb.ReadRequest{
+		FD:        req.FD,
+		Dir:       req.Dir,
+		Offset:    req.Offset,
+		Size:      int64(req.Size),
+		FileFlags: uint32(req.FileFlags),
+	}
+}
+
+func readRequestFromPB(req *pb.ReadRequest) *ReadRequest {
+	return &ReadRequest{
+		FD:        req.GetFD(),
+		Dir:       req.GetDir(),
+		Offset:    req.GetOffset(),
+		Size:      int(req.GetSize()),
+		FileFlags: fuse.OpenFlags(req.GetFileFlags()),
+	}
+}
+
+func setattrRequestToPB(req *SetattrRequest) *pb.SetattrRequest {
+	return &pb.SetattrRequest{
+		Path:     req.Path,
+		SetSize:  req.SetSize,
+		Size:     req.Size,
+		SetMode:  req.SetMode,
+		Mode:     uint32(req.Mode),
+		SetAtime: req.SetAtime,
+		Atime:    timeToPB(req.Atime),
+		SetMtime: req.SetMtime,
+		Mtime:    timeToPB(req.Mtime),
+	}
+}
+
+func setattrRequestFromPB(req *pb.SetattrRequest) *SetattrRequest {
+	return &SetattrRequest{
+		Path:     req.GetPath(),
+		SetSize:  req.GetSetSize(),
+		Size:     req.GetSize(),
+		SetMode:  req.GetSetMode(),
+		Mode:     os.FileMode(req.GetMode()),
+		SetAtime: req.GetSetAtime(),
+		Atime:    timeFromPB(req.GetAtime()),
+		SetMtime: req.GetSetMtime(),
+		Mtime:    timeFromPB(req.GetMtime()),
+	}
+}
@@ -0,0 +1,154 @@
+package coordinator
+
+import (
+	"context"
+	"net"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// serve starts a server with register and returns a connection to it.
+func serve(t *testing.T, register func(s *grpc.Server)) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	register(s)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	cc, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return cc
+}
+
+var testAttr = FileAttr{
+	INode: 7,
+	Size:  100,
+	Mtime: time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC),
+	Nlink: 1,
+	Mode:  os.ModeDir | 0755,
+}
+
+// fakeConn implements the calls the tests make; the others panic.
+type fakeConn struct {
+	UserConnection
+	read *ReadRequest
+}
+
+func (c *fakeConn) Stat(ctx context.Context, path string) (*FileAttr, error) {
+	if path != "/snap/d" {
+		return nil, syscall.ENOENT
+	}
+	a := testAttr
+	return &a, nil
+}
+
+func (c *fakeConn) ReadFrom(ctx context.Context, req *ReadRequest) ([]byte, error) {
+	c.read = req
+	return []byte("data"), nil
+}
+
+func (c *fakeConn) Readlink(ctx context.Context, path string) (string, error) {
+	return "", FS42GrpcErr{Msg: "no reason"}
+}
+
+func TestUserConnectionRoundTrip(t *testing.T) {
+	srv := &fakeConn{}
+	cc := serve(t, func(s *grpc.Server) { RegisterUserConnection(s, srv) })
+	conn := NewSnapshotConnectionClient(cc, "snap")
+	ctx := context.Background()
+
+	a, err := conn.Stat(ctx, "/d")
+	if err != nil || !reflect.DeepEqual(*a, testAttr) {
+		t.Errorf("Stat = %+v, %v; want %+v", a, err, testAttr)
+	}
+	_, err = conn.Stat(ctx, "/missing")
+	if e, ok := err.(FS42GrpcErr); !ok || e.ErrNum != int32(syscall.ENOENT) {
+		t.Errorf("Stat of a missing file: %#v", err)
+	}
+	if _, err := conn.Readlink(ctx, "/d"); err == nil || err.Error() != "no reason" {
+		t.Errorf("Readlink: %v", err)
+	}
+
+	req := &ReadRequest{FD: 3, Offset: 1 << 40, Size: 4096, FileFlags: 2}
+	b, err := conn.ReadFrom(ctx, req)
+	if err != nil || string(b) != "data" {
+		t.Errorf("ReadFrom = %q, %v", b, err)
+	}
+	if !reflect.DeepEqual(srv.read, req) {
+		t.Errorf("server got %+v, want %+v", srv.read, req)
+	}
+}
+
+type fakeCoordinator struct {
+	CoordinatorService
+	synced *Manifest
+}
+
+func (c *fakeCoordinator) Register(ctx context.Context, req *RegisterRequest) (*RegisterResponse, error) {
+	return &RegisterResponse{INode: req.RootINode + 1, Token: req.Login}, nil
+}
+
+func (c *fakeCoordinator) SyncManifest(ctx context.Context, m *Manifest) (*SyncResponse, error) {
+	c.synced = m
+	return &SyncResponse{Missing: m.Entries[0].Chunks}, nil
+}
+
+func (c *fakeCoordinator) ListUsers(ctx context.Context, req *ListUsersRequest) (*UserList, error) {
+	return &UserList{Users: []LoginInfo{
+		{Login: "alice", Exists: true, WasOnline: true, Addr: "alice:4242", INode: 16},
+		{Login: "bob", Exists: true, INode: 17, Offline: !req.OnlineOnly},
+	}}, nil
+}
+
+func TestCoordinatorRoundTrip(t *testing.T) {
+	srv := &fakeCoordinator{}
+	cc := serve(t, func(s *grpc.Server) { RegisterCoordinatorService(s, srv) })
+	c := NewCoordinatorClient(cc)
+	ctx := context.Background()
+
+	resp, err := c.Register(ctx, &RegisterRequest{Login: "alice", RootINode: 1, Time: time.Now()})
+	if err != nil || resp.INode != 2 || resp.Token != "alice" {
+		t.Errorf("Register = %+v, %v", resp, err)
+	}
+	if c.MyINode(ctx) != 2 {
+		t.Errorf("MyINode = %d", c.MyINode(ctx))
+	}
+
+	m := &Manifest{
+		Login: "alice",
+		Taken: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Entries: []ManifestEntry{
+			{Path: "/f", Attr: testAttr, Chunks: []string{"aa", "bb"}},
+			{Path: "/l", Target: "f"},
+		},
+	}
+	missing, err := c.SyncManifest(ctx, m)
+	if err != nil || !reflect.DeepEqual(missing, []string{"aa", "bb"}) {
+		t.Errorf("SyncManifest = %v, %v", missing, err)
+	}
+	if !reflect.DeepEqual(srv.synced, m) {
+		t.Errorf("server got %+v, want %+v", srv.synced, m)
+	}
+
+	users, err := c.ListUsers(ctx, false)
+	if err != nil || len(users) != 2 {
+		t.Fatalf("ListUsers = %v, %v", users, err)
+	}
+	if users[0].Addr != "alice:4242" || users[0].Conn != nil {
+		t.Errorf("online user: %+v", users[0])
+	}
+	if !users[1].Offline || users[1].Conn == nil {
+		t.Errorf("offline user has no snapshot connection: %+v", users[1])
+	}
+}
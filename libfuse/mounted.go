@@ -0,0 +1,30 @@
+package libfuse
+
+import (
+	"errors"
+	"path/filepath"
+	"syscall"
+)
+
+// ErrStaleMount is returned by Mounted when a filesystem is still mounted on
+// the directory but the daemon that served it is gone.
+var ErrStaleMount = errors.New("mount point is mounted but not served by a running daemon")
+
+// Mounted reports whether a filesystem is mounted on m.Dir(), by comparing
+// its device with the one of its parent directory.
+func Mounted(m Mounter) (bool, error) {
+	dir := m.Dir()
+	var st, parent syscall.Stat_t
+	err := syscall.Stat(dir, &st)
+	if err == syscall.ENOTCONN || err == syscall.ENXIO {
+		// Linux and macOS, respectively, once the daemon died
+		return true, ErrStaleMount
+	} else if err != nil {
+		return false, err
+	}
+	err = syscall.Stat(filepath.Dir(filepath.Clean(dir)), &parent)
+	if err != nil {
+		return false, err
+	}
+	return st.Dev != parent.Dev, nil
+}